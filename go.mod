@@ -3,14 +3,14 @@ module example/data-access
 go 1.25.6
 
 require (
+	github.com/go-sql-driver/mysql v1.9.3
 	github.com/gorilla/websocket v1.5.0
 	github.com/joho/godotenv v1.5.1
+	github.com/mattn/go-sqlite3 v1.14.33
+	go.uber.org/zap v1.27.1
 )
 
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
-	github.com/go-sql-driver/mysql v1.9.3 // indirect
-	github.com/mattn/go-sqlite3 v1.14.33 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
-	go.uber.org/zap v1.27.1 // indirect
 )
@@ -0,0 +1,453 @@
+// Package config loads server settings from defaults, an optional JSON
+// config file, environment variables and command-line flags, in increasing
+// order of precedence.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"example/data-access/internal/constants"
+)
+
+// TLS modes understood by the MySQL driver
+var validTLSModes = []string{"false", "true", "skip-verify", "preferred"}
+
+// Duration is a time.Duration that reads "5s"-style strings from JSON
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string such as "30s" or "5m"
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5s\": %v", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Database holds the connection settings for the selected backend.
+// ConnectTimeout only bounds opening the connection at startup; queries are
+// limited by constants.DBTimeout or the server's action timeouts.
+type Database struct {
+	Driver          string   `json:"driver"`
+	Host            string   `json:"host"`
+	Port            int      `json:"port"`
+	Name            string   `json:"name"`
+	User            string   `json:"user"`
+	Password        string   `json:"password"`
+	TLS             string   `json:"tls"`
+	ConnectTimeout  Duration `json:"connect_timeout"`
+	MaxOpenConns    int      `json:"max_open_conns"`
+	MaxIdleConns    int      `json:"max_idle_conns"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime"`
+	SQLitePath      string   `json:"sqlite_path"`
+
+	// AutoMigrate applies pending migrations on startup; when unset it
+	// defaults to true for SQLite and false for MySQL
+	AutoMigrate *bool `json:"auto_migrate"`
+}
+
+// Server holds the HTTP listener settings
+type Server struct {
+	Addr       string `json:"addr"`
+	UnixSocket string `json:"unix_socket"`
+
+	TLSCertFile string `json:"tls_cert_file"`
+	TLSKeyFile  string `json:"tls_key_file"`
+
+	// TLSSelfSigned serves TLS with a certificate generated at startup; for development only
+	TLSSelfSigned bool `json:"tls_self_signed"`
+
+	// ShutdownGrace is how long in-flight messages may run after SIGINT/SIGTERM
+	ShutdownGrace Duration `json:"shutdown_grace"`
+
+	// MaxConcurrentMessages bounds how many messages one connection processes at once
+	MaxConcurrentMessages int `json:"max_concurrent_messages"`
+
+	// MaxBatchSize is the most messages one batch may hold
+	MaxBatchSize int `json:"max_batch_size"`
+
+	// BatchConcurrency bounds how many items of a read-only batch run at once
+	BatchConcurrency int `json:"batch_concurrency"`
+
+	// ActionTimeouts overrides the database timeout for individual actions,
+	// e.g. {"getAllUsersPurchaseSummary": "1m"}; file only
+	ActionTimeouts map[string]Duration `json:"action_timeouts"`
+}
+
+// Config is the complete server configuration
+type Config struct {
+	Server   Server   `json:"server"`
+	Database Database `json:"database"`
+}
+
+// Default returns the configuration used when nothing else is specified
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:                  ":8080",
+			ShutdownGrace:         Duration{15 * time.Second},
+			MaxConcurrentMessages: constants.DefaultMaxConcurrentMessages,
+			MaxBatchSize:          constants.DefaultMaxBatchSize,
+			BatchConcurrency:      constants.DefaultBatchConcurrency,
+		},
+		Database: Database{
+			Driver:          constants.DriverMySQL,
+			Host:            "127.0.0.1",
+			Port:            3306,
+			Name:            "recordings",
+			TLS:             "false",
+			ConnectTimeout:  Duration{10 * time.Second},
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: Duration{5 * time.Minute},
+			SQLitePath:      constants.DefaultSQLitePath,
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the config file named by
+// -config or CONFIG_FILE, environment variables and the flags in args.
+// It returns the arguments left after flag parsing (e.g. a subcommand).
+func Load(args []string) (Config, []string, error) {
+	cfg := Default()
+
+	path := configFileFromArgs(args)
+	if path == "" {
+		path = os.Getenv(constants.EnvConfigFile)
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return cfg, nil, err
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return cfg, nil, err
+	}
+
+	fs := newFlagSet(&cfg)
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, nil, err
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// Validate reports every invalid setting at once
+func (c Config) Validate() error {
+	var errs []error
+	srv := c.Server
+
+	if srv.UnixSocket == "" && srv.Addr == "" {
+		errs = append(errs, errors.New("listen address or unix socket must be set"))
+	}
+	if (srv.TLSCertFile == "") != (srv.TLSKeyFile == "") {
+		errs = append(errs, errors.New("tls cert file and key file must be set together"))
+	}
+	if srv.TLSSelfSigned && srv.TLSCertFile != "" {
+		errs = append(errs, errors.New("tls self-signed mode cannot be combined with a cert file"))
+	}
+	if srv.ShutdownGrace.Duration < 0 {
+		errs = append(errs, errors.New("shutdown grace period must be 0 or greater"))
+	}
+	if srv.MaxConcurrentMessages < 1 {
+		errs = append(errs, errors.New("max concurrent messages must be at least 1"))
+	}
+	if srv.MaxBatchSize < 1 {
+		errs = append(errs, errors.New("max batch size must be at least 1"))
+	}
+	if srv.BatchConcurrency < 1 {
+		errs = append(errs, errors.New("batch concurrency must be at least 1"))
+	}
+	for action, timeout := range srv.ActionTimeouts {
+		if timeout.Duration <= 0 {
+			errs = append(errs, fmt.Errorf("timeout for action %q must be greater than 0", action))
+		}
+	}
+
+	d := c.Database
+
+	switch d.Driver {
+	case constants.DriverMySQL:
+		if d.Host == "" {
+			errs = append(errs, errors.New("database host must not be empty"))
+		}
+		if d.Port <= 0 || d.Port > 65535 {
+			errs = append(errs, fmt.Errorf("database port %d out of range 1-65535", d.Port))
+		}
+		if d.Name == "" {
+			errs = append(errs, errors.New("database name must not be empty"))
+		}
+		if !slices.Contains(validTLSModes, d.TLS) {
+			errs = append(errs, fmt.Errorf("database tls mode %q must be one of %s", d.TLS, strings.Join(validTLSModes, ", ")))
+		}
+	case constants.DriverSQLite:
+		if d.SQLitePath == "" {
+			errs = append(errs, errors.New("sqlite path must not be empty"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unsupported database driver %q", d.Driver))
+	}
+
+	if d.ConnectTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("database connect timeout must be greater than 0"))
+	}
+	if d.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("database max open conns must be 0 (unlimited) or greater"))
+	}
+	if d.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database max idle conns must be 0 or greater"))
+	}
+	if d.MaxOpenConns > 0 && d.MaxIdleConns > d.MaxOpenConns {
+		errs = append(errs, fmt.Errorf("database max idle conns %d exceeds max open conns %d", d.MaxIdleConns, d.MaxOpenConns))
+	}
+	if d.ConnMaxLifetime.Duration < 0 {
+		errs = append(errs, errors.New("database conn max lifetime must be 0 (unlimited) or greater"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// ShouldAutoMigrate reports whether migrations run on startup
+func (d Database) ShouldAutoMigrate() bool {
+	if d.AutoMigrate != nil {
+		return *d.AutoMigrate
+	}
+	return d.Driver == constants.DriverSQLite
+}
+
+// SummaryFields returns key/value pairs describing the database settings for
+// logging, with the password redacted
+func (d Database) SummaryFields() []interface{} {
+	if d.Driver == constants.DriverSQLite {
+		return []interface{}{
+			"driver", d.Driver,
+			"path", d.SQLitePath,
+			"max_open_conns", d.MaxOpenConns,
+			"max_idle_conns", d.MaxIdleConns,
+			"conn_max_lifetime", d.ConnMaxLifetime.String(),
+			"auto_migrate", d.ShouldAutoMigrate(),
+		}
+	}
+
+	password := ""
+	if d.Password != "" {
+		password = "[REDACTED]"
+	}
+	return []interface{}{
+		"driver", d.Driver,
+		"host", d.Host,
+		"port", d.Port,
+		"database", d.Name,
+		"user", d.User,
+		"password", password,
+		"tls", d.TLS,
+		"connect_timeout", d.ConnectTimeout.String(),
+		"max_open_conns", d.MaxOpenConns,
+		"max_idle_conns", d.MaxIdleConns,
+		"conn_max_lifetime", d.ConnMaxLifetime.String(),
+		"auto_migrate", d.ShouldAutoMigrate(),
+	}
+}
+
+// TLSEnabled reports whether the listener serves TLS
+func (s Server) TLSEnabled() bool {
+	return s.TLSCertFile != "" || s.TLSSelfSigned
+}
+
+// loadFile merges a JSON config file over cfg; keys missing from the file keep their value
+func loadFile(path string, cfg *Config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %v", err)
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("parse config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// applyEnv overrides cfg with any environment variables that are set
+func applyEnv(cfg *Config) error {
+	srv := &cfg.Server
+	d := &cfg.Database
+	var errs []error
+
+	envString(constants.EnvListenAddr, &srv.Addr)
+	envString(constants.EnvUnixSocket, &srv.UnixSocket)
+	envString(constants.EnvTLSCertFile, &srv.TLSCertFile)
+	envString(constants.EnvTLSKeyFile, &srv.TLSKeyFile)
+	errs = append(errs, envBool(constants.EnvTLSSelfSigned, &srv.TLSSelfSigned))
+	errs = append(errs, envDuration(constants.EnvShutdownGrace, &srv.ShutdownGrace.Duration))
+	errs = append(errs, envInt(constants.EnvMaxConcurrentMessages, &srv.MaxConcurrentMessages))
+	errs = append(errs, envInt(constants.EnvMaxBatchSize, &srv.MaxBatchSize))
+	errs = append(errs, envInt(constants.EnvBatchConcurrency, &srv.BatchConcurrency))
+
+	envString(constants.EnvDBDriver, &d.Driver)
+	envString(constants.EnvDBHost, &d.Host)
+	errs = append(errs, envInt(constants.EnvDBPort, &d.Port))
+	envString(constants.EnvDBName, &d.Name)
+	envString(constants.EnvDBUser, &d.User)
+	envString(constants.EnvDBPass, &d.Password)
+	envString(constants.EnvDBTLS, &d.TLS)
+	errs = append(errs, envDuration(constants.EnvDBConnectTimeout, &d.ConnectTimeout.Duration))
+	errs = append(errs, envInt(constants.EnvDBMaxOpen, &d.MaxOpenConns))
+	errs = append(errs, envInt(constants.EnvDBMaxIdle, &d.MaxIdleConns))
+	errs = append(errs, envDuration(constants.EnvDBMaxLifetime, &d.ConnMaxLifetime.Duration))
+	envString(constants.EnvSQLitePath, &d.SQLitePath)
+	errs = append(errs, envBoolPtr(constants.EnvDBAutoMigrate, &d.AutoMigrate))
+
+	return errors.Join(errs...)
+}
+
+// newFlagSet binds command-line flags to cfg, using the current values as defaults
+func newFlagSet(cfg *Config) *flag.FlagSet {
+	srv := &cfg.Server
+	d := &cfg.Database
+	fs := flag.NewFlagSet("data-access", flag.ContinueOnError)
+
+	fs.String("config", "", "path to a JSON config file (env "+constants.EnvConfigFile+")")
+	fs.StringVar(&srv.Addr, "addr", srv.Addr, "TCP listen address")
+	fs.StringVar(&srv.UnixSocket, "unix-socket", srv.UnixSocket, "listen on this Unix domain socket instead of -addr")
+	fs.StringVar(&srv.TLSCertFile, "tls-cert", srv.TLSCertFile, "TLS certificate file (PEM)")
+	fs.StringVar(&srv.TLSKeyFile, "tls-key", srv.TLSKeyFile, "TLS private key file (PEM)")
+	fs.BoolVar(&srv.TLSSelfSigned, "tls-self-signed", srv.TLSSelfSigned, "serve TLS with a generated self-signed certificate (development only)")
+	fs.DurationVar(&srv.ShutdownGrace.Duration, "shutdown-grace", srv.ShutdownGrace.Duration, "time in-flight messages may finish after SIGINT/SIGTERM")
+	fs.IntVar(&srv.MaxConcurrentMessages, "max-concurrent-messages", srv.MaxConcurrentMessages, "messages processed at once per connection")
+	fs.IntVar(&srv.MaxBatchSize, "max-batch-size", srv.MaxBatchSize, "most messages allowed in one batch")
+	fs.IntVar(&srv.BatchConcurrency, "batch-concurrency", srv.BatchConcurrency, "items of a read-only batch processed at once")
+	fs.StringVar(&d.Driver, "db-driver", d.Driver, "database driver: mysql or sqlite")
+	fs.StringVar(&d.Host, "db-host", d.Host, "MySQL host")
+	fs.IntVar(&d.Port, "db-port", d.Port, "MySQL port")
+	fs.StringVar(&d.Name, "db-name", d.Name, "MySQL database name")
+	fs.StringVar(&d.User, "db-user", d.User, "MySQL user")
+	fs.Func("db-password", "MySQL password (prefer env "+constants.EnvDBPass+")", func(s string) error {
+		d.Password = s
+		return nil
+	})
+	fs.StringVar(&d.TLS, "db-tls", d.TLS, "MySQL TLS mode: "+strings.Join(validTLSModes, ", "))
+	fs.DurationVar(&d.ConnectTimeout.Duration, "db-connect-timeout", d.ConnectTimeout.Duration, "database connect timeout")
+	fs.IntVar(&d.MaxOpenConns, "db-max-open-conns", d.MaxOpenConns, "maximum open connections (0 = unlimited)")
+	fs.IntVar(&d.MaxIdleConns, "db-max-idle-conns", d.MaxIdleConns, "maximum idle connections")
+	fs.DurationVar(&d.ConnMaxLifetime.Duration, "db-conn-max-lifetime", d.ConnMaxLifetime.Duration, "maximum connection lifetime (0 = unlimited)")
+	fs.StringVar(&d.SQLitePath, "sqlite-path", d.SQLitePath, "SQLite database file or :memory:")
+	fs.Func("db-auto-migrate", "apply pending migrations on startup (true/false)", func(s string) error {
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		d.AutoMigrate = &v
+		return nil
+	})
+
+	return fs
+}
+
+// configFileFromArgs finds the -config flag before the full flag set is built
+func configFileFromArgs(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		if value, ok := strings.CutPrefix(name, "config="); ok {
+			return value
+		}
+		if name == "config" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func envString(name string, target *string) {
+	if value, ok := os.LookupEnv(name); ok && value != "" {
+		*target = value
+	}
+}
+
+func envInt(name string, target *int) error {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	*target = n
+	return nil
+}
+
+func envDuration(name string, target *time.Duration) error {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	*target = d
+	return nil
+}
+
+func envBool(name string, target *bool) error {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	*target = b
+	return nil
+}
+
+func envBoolPtr(name string, target **bool) error {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	*target = &b
+	return nil
+}
@@ -4,7 +4,75 @@ import "time"
 
 // Database Configuration
 const (
-	DBTimeout = 5 * time.Second
+	DBTimeout        = 5 * time.Second
+	MigrationTimeout = 60 * time.Second
+
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite"
+
+	DefaultSQLitePath = "recordings.db"
+	SQLiteMemoryPath  = ":memory:"
+)
+
+// Server Configuration
+const (
+	// CloseHandshakeTimeout bounds how long shutdown waits for clients to answer a close frame
+	CloseHandshakeTimeout = 2 * time.Second
+
+	DefaultMaxConcurrentMessages = 8
+	DefaultMaxBatchSize          = 100
+	DefaultBatchConcurrency      = 4
+
+	// MaxOrderLines is the most line items one placeOrder request may hold
+	MaxOrderLines = 100
+
+	// DefaultPageLimit and MaxPageLimit bound how many records one list action returns
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+
+	// MaxSearchQueryLength is the longest searchCatalog query accepted, in bytes
+	MaxSearchQueryLength = 200
+
+	// DefaultReportLimit and MaxReportLimit bound how many rows the top albums
+	// and revenue by artist reports return
+	DefaultReportLimit = 10
+	MaxReportLimit     = 100
+
+	// MaxSubscribedAlbums is the most album IDs one connection may subscribe to
+	MaxSubscribedAlbums = 1000
+
+	// EventQueueSize is how many pushed events may wait for a slow client
+	// before further events for it are dropped
+	EventQueueSize = 256
+)
+
+// Environment Variables
+const (
+	EnvConfigFile       = "CONFIG_FILE"
+	EnvDBDriver         = "DBDRIVER"
+	EnvDBHost           = "DBHOST"
+	EnvDBPort           = "DBPORT"
+	EnvDBName           = "DBNAME"
+	EnvDBUser           = "DBUSER"
+	EnvDBPass           = "DBPASS"
+	EnvDBTLS            = "DBTLS"
+	EnvDBConnectTimeout = "DB_CONNECT_TIMEOUT"
+	EnvDBMaxOpen        = "DBMAXOPEN"
+	EnvDBMaxIdle        = "DBMAXIDLE"
+	EnvDBMaxLifetime    = "DBMAXLIFETIME"
+	EnvSQLitePath       = "SQLITE_PATH"
+	EnvDBAutoMigrate    = "DBAUTOMIGRATE"
+
+	EnvListenAddr    = "LISTEN_ADDR"
+	EnvUnixSocket    = "UNIX_SOCKET"
+	EnvTLSCertFile   = "TLS_CERT_FILE"
+	EnvTLSKeyFile    = "TLS_KEY_FILE"
+	EnvTLSSelfSigned = "TLS_SELF_SIGNED"
+	EnvShutdownGrace = "SHUTDOWN_GRACE"
+
+	EnvMaxConcurrentMessages = "MAX_CONCURRENT_MESSAGES"
+	EnvMaxBatchSize          = "MAX_BATCH_SIZE"
+	EnvBatchConcurrency      = "BATCH_CONCURRENCY"
 )
 
 // WebSocket Actions
@@ -13,19 +81,40 @@ const (
 	ActionGetAlbums        = "getAlbums"
 	ActionGetAlbumByID     = "getAlbumByID"
 	ActionGetAlbumByArtist = "getAlbumByArtist"
+	ActionSearchAlbums     = "searchAlbums"
+	ActionSearchCatalog    = "searchCatalog"
 	ActionAddAlbum         = "addAlbum"
+	ActionUpdateAlbum      = "updateAlbum"
+	ActionRestockAlbum     = "restockAlbum"
+	ActionDeleteAlbum      = "deleteAlbum"
 
 	// User Actions
 	ActionGetUsers    = "getUsers"
 	ActionGetUserByID = "getUserByID"
 	ActionAddUser     = "addUser"
+	ActionUpdateUser  = "updateUser"
+	ActionDeleteUser  = "deleteUser"
 
 	// Purchase Actions
-	ActionGetPurchases               = "getPurchases"
-	ActionGetPurchasesByUserID       = "getPurchasesByUserID"
-	ActionAddPurchase                = "addPurchase"
+	ActionGetPurchases         = "getPurchases"
+	ActionGetPurchasesByUserID = "getPurchasesByUserID"
+	ActionAddPurchase          = "addPurchase"
+	ActionCancelPurchase       = "cancelPurchase"
+
+	// Order Actions
+	ActionPlaceOrder                 = "placeOrder"
 	ActionGetUserPurchaseSummary     = "getUserPurchaseSummary"
 	ActionGetAllUsersPurchaseSummary = "getAllUsersPurchaseSummary"
+
+	// Subscription Actions
+	ActionSubscribe   = "subscribe"
+	ActionUnsubscribe = "unsubscribe"
+
+	// Report Actions
+	ActionGetTopSellingAlbums  = "getTopSellingAlbums"
+	ActionGetRevenueByArtist   = "getRevenueByArtist"
+	ActionGetSalesByPeriod     = "getSalesByPeriod"
+	ActionGetAverageOrderValue = "getAverageOrderValue"
 )
 
 // Database Table Names
@@ -71,12 +160,54 @@ const (
 	JSONFieldAlbumID  = "album_id"
 	JSONFieldQuantity = "quantity"
 	JSONFieldID       = "id"
+	JSONFieldReason   = "reason"
+	JSONFieldDeleted  = "deleted"
+
+	// JSONFieldPurchases is the number of purchases a deleted user had
+	JSONFieldPurchases = "purchases"
+
+	// JSONFieldLines holds the line items of a placeOrder request
+	JSONFieldLines = "lines"
+
+	// JSONFieldOnPurchases selects what deleteAlbum and deleteUser do when purchases reference the record
+	JSONFieldOnPurchases = "on_purchases"
+
+	// searchAlbums filters and sorting
+	JSONFieldMinPrice = "min_price"
+	JSONFieldMaxPrice = "max_price"
+	JSONFieldInStock  = "in_stock"
+	JSONFieldSort     = "sort"
+	JSONFieldOrder    = "order"
+
+	// JSONFieldFrom and JSONFieldTo bound purchase queries by creation time
+	JSONFieldFrom = "from"
+	JSONFieldTo   = "to"
+
+	// JSONFieldAlbumIDs and JSONFieldCatalog select what subscribe and unsubscribe apply to
+	JSONFieldAlbumIDs = "album_ids"
+	JSONFieldCatalog  = "catalog"
+
+	// JSONFieldPeriod selects the day, week or month grouping of getSalesByPeriod
+	JSONFieldPeriod = "period"
+
+	// JSONFieldQuery is the free text of a searchCatalog request
+	JSONFieldQuery = "query"
+
+	// JSONFieldLimit and JSONFieldCursor page through list actions
+	JSONFieldLimit  = "limit"
+	JSONFieldCursor = "cursor"
+
+	// JSONFieldRequestID is the optional client-supplied ID on WSMessage
+	JSONFieldRequestID = "id"
+	JSONFieldMessages  = "messages"
 )
 
 // Error Messages
 const (
 	ErrInvalidMessageFormat          = "invalid message format"
 	ErrUnknownAction                 = "unknown action"
+	ErrInvalidRequestID              = "invalid id: must be a string or number"
+	ErrServerShuttingDown            = "server is shutting down"
 	ErrArtistNameEmpty               = "artist name cannot be empty"
 	ErrArtistNameNotString           = "invalid artist name: must be a string"
 	ErrIDMustBePositive              = "ID must be greater than 0"
@@ -84,16 +215,132 @@ const (
 	ErrUserIDNotNumber               = "invalid user ID: must be a number"
 	ErrInvalidOrMissingTitle         = "invalid or missing title"
 	ErrInvalidOrMissingArtist        = "invalid or missing artist"
-	ErrPriceMustBePositive           = "price must be greater than 0"
-	ErrStockMustBeNonNegative        = "stock must be 0 or greater"
+	ErrPriceMustBePositive           = "price must be greater than 0 and at most 99999999.99, with at most 2 decimal places"
+	ErrStockMustBeNonNegative        = "stock must be a whole number, 0 or greater"
 	ErrInvalidAlbumData              = "invalid album data: must be an object"
 	ErrInvalidOrMissingUsername      = "invalid or missing username"
 	ErrInvalidOrMissingEmail         = "invalid or missing email"
 	ErrInvalidUserData               = "invalid user data: must be an object"
 	ErrInvalidUserIDMustBePositive   = "invalid or missing user_id: must be greater than 0"
 	ErrInvalidAlbumIDMustBePositive  = "invalid or missing album_id: must be greater than 0"
-	ErrInvalidQuantityMustBePositive = "invalid quantity: must be greater than 0"
+	ErrInvalidQuantityMustBePositive = "invalid quantity: must be a whole number greater than 0"
 	ErrInvalidPurchaseData           = "invalid purchase data: must be an object"
+	ErrInvalidIDField                = "invalid or missing id: must be greater than 0"
+	ErrNoAlbumFieldsToUpdate         = "no fields to update: set title, artist, price or stock"
+	ErrNoUserFieldsToUpdate          = "no fields to update: set username or email"
+	ErrInvalidRestockData            = "invalid restock data: must be an object"
+	ErrInvalidOrMissingReason        = "invalid or missing reason"
+	ErrInvalidDeleteData             = "invalid delete data: must be an object"
+	ErrInvalidOnPurchases            = "invalid on_purchases: must be \"refuse\" or \"soft_delete\""
+	ErrInvalidUserOnPurchases        = "invalid on_purchases: must be \"block\", \"anonymize\" or \"cascade\""
+	ErrInvalidCancelData             = "invalid cancel data: must be an object"
+	ErrInvalidCancelQuantity         = "invalid quantity: must be a whole number greater than 0"
+	ErrInvalidOrderData              = "invalid order data: must be an object"
+	ErrInvalidOrderLines             = "invalid lines: must be a non-empty array"
+	ErrTooManyOrderLines             = "too many order lines"
+	ErrInvalidOrderLine              = "invalid order line: must be an object"
+	ErrInvalidPageData               = "invalid page data: must be an object"
+	ErrInvalidPageLimit              = "invalid limit: must be a whole number from 1 to 500"
+	ErrInvalidCursor                 = "invalid cursor: use next_cursor from the previous page"
+	ErrInvalidSearchData             = "invalid search data: must be an object"
+	ErrInvalidTitleFilter            = "invalid title: must be a string"
+	ErrInvalidArtistFilter           = "invalid artist: must be a string"
+	ErrInvalidPriceFilter            = "invalid price filter: must be 0 or greater with at most 2 decimal places"
+	ErrPriceRangeReversed            = "min_price must not be greater than max_price"
+	ErrInvalidInStock                = "invalid in_stock: must be true or false"
+	ErrInvalidSort                   = "invalid sort: must be \"id\", \"title\", \"artist\", \"price\" or \"stock\""
+	ErrInvalidSearchQuery            = "invalid or missing query: must contain a letter or digit"
+	ErrSearchQueryTooLong            = "query is too long"
+	ErrInvalidSortOrder              = "invalid order: must be \"asc\" or \"desc\""
+	ErrInvalidPurchasesByUserData    = "invalid data: must be a user ID or an object with user_id"
+	ErrInvalidFromTime               = "invalid from: must be an RFC 3339 time or a YYYY-MM-DD date"
+	ErrInvalidToTime                 = "invalid to: must be an RFC 3339 time or a YYYY-MM-DD date"
+	ErrTimeRangeReversed             = "from must be before to"
+	ErrInvalidReportData             = "invalid report data: must be an object"
+	ErrInvalidReportLimit            = "invalid limit: must be a whole number from 1 to 100"
+	ErrInvalidSubscribeData          = "invalid subscribe data: set album_ids or catalog"
+	ErrInvalidSubscribedAlbumIDs     = "invalid album_ids: must be an array of IDs greater than 0"
+	ErrInvalidCatalogFlag            = "invalid catalog: must be true or false"
+	ErrTooManySubscribedAlbums       = "too many subscribed albums"
+	ErrInvalidReportPeriod           = "invalid or missing period: must be \"day\", \"week\" or \"month\""
+
+	// Store failures; driver errors are never sent to clients
+	ErrRecordNotFound    = "record not found"
+	ErrInsufficientStock = "insufficient stock for purchase"
+	ErrDuplicateRecord   = "record already exists"
+	ErrInvalidReference  = "referenced user or album does not exist"
+	ErrRecordInUse       = "record is referenced by purchases"
+	ErrNotCancellable    = "quantity exceeds the units left to cancel"
+	ErrRequestTimedOut   = "request timed out"
+	ErrInternal          = "internal server error"
+
+	ErrBatchEmpty      = "batch must contain at least one message"
+	ErrBatchTooLarge   = "batch has too many messages"
+	ErrBatchRolledBack = "batch rolled back: an item failed"
+	ErrBatchNotAtomic  = "subscribe and unsubscribe cannot run in an atomic batch"
+	ErrItemRolledBack  = "rolled back because another item in the batch failed"
+	ErrItemNotExecuted = "not executed because an earlier item in the batch failed"
+)
+
+// Search Options
+const (
+	SortByID     = "id"
+	SortByTitle  = "title"
+	SortByArtist = "artist"
+	SortByPrice  = "price"
+	SortByStock  = "stock"
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// Pushed Events
+const (
+	EventStockChanged = "stockChanged"
+	EventAlbumAdded   = "albumAdded"
+	EventAlbumUpdated = "albumUpdated"
+	EventAlbumDeleted = "albumDeleted"
+)
+
+// Report Options
+const (
+	ReportPeriodDay   = "day"
+	ReportPeriodWeek  = "week"
+	ReportPeriodMonth = "month"
+)
+
+// Delete Options
+const (
+	OnPurchasesRefuse     = "refuse"
+	OnPurchasesSoftDelete = "soft_delete"
+
+	// DeletedHard, DeletedSoft and DeletedAnonymized report how a record was deleted
+	DeletedHard       = "hard"
+	DeletedSoft       = "soft"
+	DeletedAnonymized = "anonymized"
+)
+
+// Error Codes
+const (
+	CodeValidationFailed   = "VALIDATION_FAILED"
+	CodeInvalidMessage     = "INVALID_MESSAGE"
+	CodeUnknownAction      = "UNKNOWN_ACTION"
+	CodeNotFound           = "NOT_FOUND"
+	CodeInsufficientStock  = "INSUFFICIENT_STOCK"
+	CodeAlreadyExists      = "ALREADY_EXISTS"
+	CodeInvalidReference   = "INVALID_REFERENCE"
+	CodeInUse              = "IN_USE"
+	CodeNotCancellable     = "NOT_CANCELLABLE"
+	CodeTimeout            = "TIMEOUT"
+	CodeServerShuttingDown = "SERVER_SHUTTING_DOWN"
+	CodeInternal           = "INTERNAL"
+
+	// Atomic batches: the envelope fails with CodeBatchRolledBack and every
+	// item other than the failing one reports why it did not take effect
+	CodeBatchRolledBack = "BATCH_ROLLED_BACK"
+	CodeRolledBack      = "ROLLED_BACK"
+	CodeNotExecuted     = "NOT_EXECUTED"
+	CodeBatchTooLarge   = "BATCH_TOO_LARGE"
 )
 
 // Log Messages
@@ -102,17 +349,34 @@ const (
 	LogInvalidRequest                     = "Invalid request"
 	LogFailedToGetAlbums                  = "Failed to get albums"
 	LogFailedToGetAlbumsByArtist          = "Failed to get albums by artist"
+	LogFailedToSearchAlbums               = "Failed to search albums"
+	LogFailedToSearchCatalog              = "Failed to search catalog"
 	LogAlbumNotFound                      = "Album not found"
 	LogFailedToAddAlbum                   = "Failed to add album"
+	LogFailedToUpdateAlbum                = "Failed to update album"
+	LogFailedToRestockAlbum               = "Failed to restock album"
+	LogFailedToDeleteAlbum                = "Failed to delete album"
 	LogFailedToGetUsers                   = "Failed to get users"
 	LogUserNotFound                       = "User not found"
 	LogFailedToAddUser                    = "Failed to add user"
+	LogFailedToUpdateUser                 = "Failed to update user"
+	LogFailedToDeleteUser                 = "Failed to delete user"
 	LogFailedToGetPurchases               = "Failed to get purchases"
 	LogFailedToGetPurchasesByUser         = "Failed to get purchases by user"
 	LogAttemptingPurchase                 = "Attempting purchase"
 	LogPurchaseFailed                     = "Purchase failed"
 	LogPurchaseSuccessful                 = "Purchase successful"
+	LogFailedToCancelPurchase             = "Failed to cancel purchase"
+	LogOrderFailed                        = "Order failed"
+	LogOrderPlaced                        = "Order placed"
 	LogFailedToGetUserPurchaseSummary     = "Failed to get user purchase summary"
 	LogFailedToGetAllUsersPurchaseSummary = "Failed to get all users purchase summary"
+	LogFailedToGetTopSellingAlbums        = "Failed to get top selling albums"
+	LogFailedToGetRevenueByArtist         = "Failed to get revenue by artist"
+	LogFailedToGetSalesByPeriod           = "Failed to get sales by period"
+	LogFailedToGetAverageOrderValue       = "Failed to get average order value"
 	LogUnknownAction                      = "Unknown action"
+	LogEventDropped                       = "Event dropped for slow client"
+	LogBatchCommitted                     = "Atomic batch committed"
+	LogBatchRolledBack                    = "Atomic batch rolled back"
 )
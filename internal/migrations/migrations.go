@@ -0,0 +1,307 @@
+// Package migrations embeds the versioned schema and stored procedure
+// migrations for every supported database driver and applies them.
+//
+// Migration files live in a directory named after the driver and are named
+// <version>_<name>.up.sql / <version>_<name>.down.sql. Statements inside a
+// file are separated by a line containing only "-- statement-break", which
+// keeps procedure bodies intact without needing DELIMITER.
+//
+// Migrations that only some builds can apply live in a directory of their
+// own, registered as an extension of the driver by the build that needs it.
+// They share the driver's version numbers.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"example/data-access/internal/constants"
+	"example/data-access/internal/logger"
+)
+
+//go:embed mysql/*.sql sqlite/*.sql sqlite_fts5/*.sql
+var files embed.FS
+
+// extensions maps a driver to the directories of the build-specific
+// migrations applied along with its own
+var extensions = map[string][]string{}
+
+const statementBreak = "-- statement-break"
+
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// Migration is one versioned schema change with its rollback
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied to the database
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt string
+}
+
+// Load returns the embedded migrations for a driver, including those of its
+// extensions in this build, ordered by version
+func Load(driver string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, driver)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %q: %v", driver, err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	if err := loadDir(driver, entries, byVersion); err != nil {
+		return nil, err
+	}
+	for _, dir := range extensions[driver] {
+		entries, err := fs.ReadDir(files, dir)
+		if err != nil {
+			return nil, fmt.Errorf("no migrations for extension %q: %v", dir, err)
+		}
+		if err := loadDir(dir, entries, byVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// loadDir adds the migration files among entries of dir to byVersion. A
+// version already loaded from another directory is an error.
+func loadDir(dir string, entries []fs.DirEntry, byVersion map[int]*Migration) error {
+	own := make(map[int]bool)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		versionPart, rest, ok := strings.Cut(name, "_")
+		if !ok {
+			return fmt.Errorf("invalid migration file name %q", name)
+		}
+		version, err := strconv.Atoi(versionPart)
+		if err != nil {
+			return fmt.Errorf("invalid migration version in %q: %v", name, err)
+		}
+
+		content, err := files.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("read migration %q: %v", name, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: strings.TrimSuffix(rest, "."+direction+".sql")}
+			byVersion[version] = m
+			own[version] = true
+		} else if !own[version] {
+			return fmt.Errorf("migration %q reuses version %04d", path.Join(dir, name), version)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	return nil
+}
+
+// Up applies every pending migration in version order and returns how many were applied
+func Up(db *sql.DB, driver string) (int, error) {
+	migrations, err := Load(driver)
+	if err != nil {
+		return 0, err
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		logger.Log.Infow("Applying migration", "version", m.Version, "name", m.Name, "driver", driver)
+		if err := run(db, m.Up, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name); err != nil {
+			return count, fmt.Errorf("migration %04d_%s up: %v", m.Version, m.Name, err)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// Down rolls back the most recently applied migrations, at most steps of them,
+// and returns how many were rolled back
+func Down(db *sql.DB, driver string, steps int) (int, error) {
+	migrations, err := Load(driver)
+	if err != nil {
+		return 0, err
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == "" {
+			return count, fmt.Errorf("migration %04d_%s has no down file", m.Version, m.Name)
+		}
+
+		logger.Log.Infow("Rolling back migration", "version", m.Version, "name", m.Name, "driver", driver)
+		if err := run(db, m.Down, "DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+			return count, fmt.Errorf("migration %04d_%s down: %v", m.Version, m.Name, err)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// GetStatus lists every known migration and whether it has been applied
+func GetStatus(db *sql.DB, driver string) ([]Status, error) {
+	migrations, err := Load(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.Version]
+		statuses = append(statuses, Status{Version: m.Version, Name: m.Name, Applied: ok, AppliedAt: appliedAt})
+	}
+
+	return statuses, nil
+}
+
+// appliedVersions creates the schema_migrations table if needed and returns
+// the applied versions mapped to their applied_at time
+func appliedVersions(db *sql.DB) (map[int]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), constants.DBTimeout)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, createMigrationsTable); err != nil {
+		logger.Log.Errorw("Failed to create schema_migrations table", "error", err)
+		return nil, fmt.Errorf("create schema_migrations: %v", err)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("read schema_migrations: %v", err)
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// run executes every statement of a migration script followed by the
+// bookkeeping statement in one transaction. MySQL commits DDL implicitly, so
+// there the transaction only protects the bookkeeping row.
+func run(db *sql.DB, script string, bookkeeping string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), constants.MigrationTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(script) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// splitStatements splits a script on statement-break lines, dropping empty
+// statements and trailing semicolons
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	flush := func() {
+		stmt := strings.TrimSpace(current.String())
+		stmt = strings.TrimSpace(strings.TrimSuffix(stmt, ";"))
+		if stmt != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.Split(script, "\n") {
+		if strings.TrimSpace(line) == statementBreak {
+			flush()
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+	}
+	flush()
+
+	return statements
+}
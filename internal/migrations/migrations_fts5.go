@@ -0,0 +1,9 @@
+//go:build sqlite_fts5
+
+package migrations
+
+// The catalog search index needs the FTS5 module, which go-sqlite3 only
+// includes with the sqlite_fts5 tag
+func init() {
+	extensions["sqlite"] = append(extensions["sqlite"], "sqlite_fts5")
+}
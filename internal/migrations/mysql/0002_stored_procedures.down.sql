@@ -0,0 +1,23 @@
+DROP PROCEDURE IF EXISTS sp_get_all_users;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_user_by_id;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_add_user;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_albums;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_album_by_id;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_albums_by_artist;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_add_album;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_purchases;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_purchases_by_user_id;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_add_purchase;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_user_purchase_summary;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_users_purchase_summary;
@@ -0,0 +1,125 @@
+DROP PROCEDURE IF EXISTS sp_get_all_users;
+-- statement-break
+CREATE PROCEDURE sp_get_all_users()
+BEGIN
+    SELECT id, username, email FROM user;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_user_by_id;
+-- statement-break
+CREATE PROCEDURE sp_get_user_by_id(IN p_user_id INT)
+BEGIN
+    SELECT id, username, email FROM user WHERE id = p_user_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_add_user;
+-- statement-break
+CREATE PROCEDURE sp_add_user(IN p_username VARCHAR(255), IN p_email VARCHAR(255))
+BEGIN
+    INSERT INTO user (username, email) VALUES (p_username, p_email);
+    SELECT LAST_INSERT_ID();
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_albums;
+-- statement-break
+CREATE PROCEDURE sp_get_all_albums()
+BEGIN
+    SELECT id, title, artist, price, stock FROM album;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_album_by_id;
+-- statement-break
+CREATE PROCEDURE sp_get_album_by_id(IN p_album_id INT)
+BEGIN
+    SELECT id, title, artist, price, stock FROM album WHERE id = p_album_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_albums_by_artist;
+-- statement-break
+CREATE PROCEDURE sp_get_albums_by_artist(IN p_artist VARCHAR(255))
+BEGIN
+    SELECT id, title, artist, price, stock FROM album WHERE artist = p_artist;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_add_album;
+-- statement-break
+CREATE PROCEDURE sp_add_album(IN p_title VARCHAR(255), IN p_artist VARCHAR(255), IN p_price DECIMAL(10, 2), IN p_stock INT)
+BEGIN
+    INSERT INTO album (title, artist, price, stock) VALUES (p_title, p_artist, p_price, p_stock);
+    SELECT LAST_INSERT_ID();
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_purchases;
+-- statement-break
+CREATE PROCEDURE sp_get_all_purchases()
+BEGIN
+    SELECT id, user_id, album_id, quantity FROM purchase;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_purchases_by_user_id;
+-- statement-break
+CREATE PROCEDURE sp_get_purchases_by_user_id(IN p_user_id INT)
+BEGIN
+    SELECT id, user_id, album_id, quantity FROM purchase WHERE user_id = p_user_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_add_purchase;
+-- statement-break
+CREATE PROCEDURE sp_add_purchase(IN p_user_id INT, IN p_album_id INT, IN p_quantity INT)
+BEGIN
+    DECLARE v_stock INT;
+    DECLARE v_purchase_id INT;
+
+    START TRANSACTION;
+
+    -- Check current stock
+    SELECT stock INTO v_stock FROM album WHERE id = p_album_id FOR UPDATE;
+    
+    IF v_stock IS NULL THEN
+        ROLLBACK;
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Album not found';
+    END IF;
+
+    IF v_stock < p_quantity THEN
+        ROLLBACK;
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Insufficient stock for purchase';
+    END IF;
+
+    -- Insert purchase
+    INSERT INTO purchase (user_id, album_id, quantity) VALUES (p_user_id, p_album_id, p_quantity);
+    SET v_purchase_id = LAST_INSERT_ID();
+
+    -- Decrement stock
+    UPDATE album SET stock = stock - p_quantity WHERE id = p_album_id;
+
+    COMMIT;
+    
+    -- Return the purchase ID
+    SELECT v_purchase_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_user_purchase_summary;
+-- statement-break
+CREATE PROCEDURE sp_get_user_purchase_summary(IN p_user_id INT)
+BEGIN
+    -- Get user info
+    SELECT id, username, email FROM user WHERE id = p_user_id;
+    
+    -- Get purchase details with album info
+    SELECT p.id, p.album_id, a.title, a.artist, a.price, p.quantity
+    FROM purchase p
+    JOIN album a ON p.album_id = a.id
+    WHERE p.user_id = p_user_id
+    ORDER BY p.id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_users_purchase_summary;
+-- statement-break
+CREATE PROCEDURE sp_get_all_users_purchase_summary()
+BEGIN
+    SELECT u.id, u.username, u.email, p.id, p.album_id, a.title, a.artist, a.price, p.quantity
+    FROM user u
+    LEFT JOIN purchase p ON u.id = p.user_id
+    LEFT JOIN album a ON p.album_id = a.id
+    ORDER BY u.id, p.id;
+END;
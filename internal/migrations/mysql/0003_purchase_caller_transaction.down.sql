@@ -0,0 +1,35 @@
+-- Restore the self-committing sp_add_purchase from 0002
+DROP PROCEDURE IF EXISTS sp_add_purchase;
+-- statement-break
+CREATE PROCEDURE sp_add_purchase(IN p_user_id INT, IN p_album_id INT, IN p_quantity INT)
+BEGIN
+    DECLARE v_stock INT;
+    DECLARE v_purchase_id INT;
+
+    START TRANSACTION;
+
+    -- Check current stock
+    SELECT stock INTO v_stock FROM album WHERE id = p_album_id FOR UPDATE;
+    
+    IF v_stock IS NULL THEN
+        ROLLBACK;
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Album not found';
+    END IF;
+
+    IF v_stock < p_quantity THEN
+        ROLLBACK;
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Insufficient stock for purchase';
+    END IF;
+
+    -- Insert purchase
+    INSERT INTO purchase (user_id, album_id, quantity) VALUES (p_user_id, p_album_id, p_quantity);
+    SET v_purchase_id = LAST_INSERT_ID();
+
+    -- Decrement stock
+    UPDATE album SET stock = stock - p_quantity WHERE id = p_album_id;
+
+    COMMIT;
+    
+    -- Return the purchase ID
+    SELECT v_purchase_id;
+END;
@@ -0,0 +1,59 @@
+DROP PROCEDURE IF EXISTS sp_delete_album;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_restock_album;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_update_album;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_albums;
+-- statement-break
+CREATE PROCEDURE sp_get_all_albums()
+BEGIN
+    SELECT id, title, artist, price, stock FROM album;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_album_by_id;
+-- statement-break
+CREATE PROCEDURE sp_get_album_by_id(IN p_album_id INT)
+BEGIN
+    SELECT id, title, artist, price, stock FROM album WHERE id = p_album_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_albums_by_artist;
+-- statement-break
+CREATE PROCEDURE sp_get_albums_by_artist(IN p_artist VARCHAR(255))
+BEGIN
+    SELECT id, title, artist, price, stock FROM album WHERE artist = p_artist;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_add_purchase;
+-- statement-break
+CREATE PROCEDURE sp_add_purchase(IN p_user_id INT, IN p_album_id INT, IN p_quantity INT)
+BEGIN
+    DECLARE v_stock INT;
+    DECLARE v_purchase_id INT;
+
+    -- Check current stock
+    SELECT stock INTO v_stock FROM album WHERE id = p_album_id FOR UPDATE;
+
+    IF v_stock IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Album not found';
+    END IF;
+
+    IF v_stock < p_quantity THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Insufficient stock for purchase';
+    END IF;
+
+    -- Insert purchase
+    INSERT INTO purchase (user_id, album_id, quantity) VALUES (p_user_id, p_album_id, p_quantity);
+    SET v_purchase_id = LAST_INSERT_ID();
+
+    -- Decrement stock
+    UPDATE album SET stock = stock - p_quantity WHERE id = p_album_id;
+
+    -- Return the purchase ID
+    SELECT v_purchase_id;
+END;
+-- statement-break
+DROP TABLE IF EXISTS album_restock;
+-- statement-break
+ALTER TABLE album DROP COLUMN deleted_at;
@@ -0,0 +1,130 @@
+-- Soft-deleted albums keep their row so purchases still resolve, but are
+-- hidden from album queries and cannot be bought
+ALTER TABLE album ADD COLUMN deleted_at DATETIME NULL;
+-- statement-break
+CREATE TABLE IF NOT EXISTS album_restock (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  album_id INT NOT NULL,
+  quantity INT NOT NULL,
+  reason VARCHAR(255) NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (album_id) REFERENCES album(id)
+);
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_albums;
+-- statement-break
+CREATE PROCEDURE sp_get_all_albums()
+BEGIN
+    SELECT id, title, artist, price, stock FROM album WHERE deleted_at IS NULL;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_album_by_id;
+-- statement-break
+CREATE PROCEDURE sp_get_album_by_id(IN p_album_id INT)
+BEGIN
+    SELECT id, title, artist, price, stock FROM album WHERE id = p_album_id AND deleted_at IS NULL;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_albums_by_artist;
+-- statement-break
+CREATE PROCEDURE sp_get_albums_by_artist(IN p_artist VARCHAR(255))
+BEGIN
+    SELECT id, title, artist, price, stock FROM album WHERE artist = p_artist AND deleted_at IS NULL;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_add_purchase;
+-- statement-break
+CREATE PROCEDURE sp_add_purchase(IN p_user_id INT, IN p_album_id INT, IN p_quantity INT)
+BEGIN
+    DECLARE v_stock INT;
+    DECLARE v_purchase_id INT;
+
+    -- Check current stock
+    SELECT stock INTO v_stock FROM album WHERE id = p_album_id AND deleted_at IS NULL FOR UPDATE;
+
+    IF v_stock IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Album not found';
+    END IF;
+
+    IF v_stock < p_quantity THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Insufficient stock for purchase';
+    END IF;
+
+    -- Insert purchase
+    INSERT INTO purchase (user_id, album_id, quantity) VALUES (p_user_id, p_album_id, p_quantity);
+    SET v_purchase_id = LAST_INSERT_ID();
+
+    -- Decrement stock
+    UPDATE album SET stock = stock - p_quantity WHERE id = p_album_id;
+
+    -- Return the purchase ID
+    SELECT v_purchase_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_update_album;
+-- statement-break
+CREATE PROCEDURE sp_update_album(IN p_album_id INT, IN p_title VARCHAR(255), IN p_artist VARCHAR(255), IN p_price DECIMAL(10, 2), IN p_stock INT)
+BEGIN
+    DECLARE v_id INT;
+
+    -- NULL parameters leave the column unchanged
+    SELECT id INTO v_id FROM album WHERE id = p_album_id AND deleted_at IS NULL FOR UPDATE;
+
+    IF v_id IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Album not found';
+    END IF;
+
+    UPDATE album
+    SET title = COALESCE(p_title, title),
+        artist = COALESCE(p_artist, artist),
+        price = COALESCE(p_price, price),
+        stock = COALESCE(p_stock, stock)
+    WHERE id = p_album_id;
+
+    SELECT id, title, artist, price, stock FROM album WHERE id = p_album_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_restock_album;
+-- statement-break
+CREATE PROCEDURE sp_restock_album(IN p_album_id INT, IN p_quantity INT, IN p_reason VARCHAR(255))
+BEGIN
+    DECLARE v_id INT;
+
+    SELECT id INTO v_id FROM album WHERE id = p_album_id AND deleted_at IS NULL FOR UPDATE;
+
+    IF v_id IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Album not found';
+    END IF;
+
+    UPDATE album SET stock = stock + p_quantity WHERE id = p_album_id;
+    INSERT INTO album_restock (album_id, quantity, reason) VALUES (p_album_id, p_quantity, p_reason);
+
+    SELECT id, title, artist, price, stock FROM album WHERE id = p_album_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_delete_album;
+-- statement-break
+CREATE PROCEDURE sp_delete_album(IN p_album_id INT, IN p_soft_if_purchased BOOLEAN)
+BEGIN
+    DECLARE v_id INT;
+    DECLARE v_purchases INT;
+
+    SELECT id INTO v_id FROM album WHERE id = p_album_id AND deleted_at IS NULL FOR UPDATE;
+
+    IF v_id IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Album not found';
+    END IF;
+
+    SELECT COUNT(*) INTO v_purchases FROM purchase WHERE album_id = p_album_id;
+
+    IF v_purchases = 0 THEN
+        DELETE FROM album_restock WHERE album_id = p_album_id;
+        DELETE FROM album WHERE id = p_album_id;
+        SELECT FALSE;
+    ELSEIF p_soft_if_purchased THEN
+        UPDATE album SET deleted_at = CURRENT_TIMESTAMP WHERE id = p_album_id;
+        SELECT TRUE;
+    ELSE
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Album has purchases';
+    END IF;
+END;
@@ -0,0 +1,102 @@
+-- Anonymized users keep their row so purchases still resolve, but are hidden
+-- from user queries and cannot make purchases
+ALTER TABLE user ADD COLUMN deleted_at DATETIME NULL;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_users;
+-- statement-break
+CREATE PROCEDURE sp_get_all_users()
+BEGIN
+    SELECT id, username, email FROM user WHERE deleted_at IS NULL;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_user_by_id;
+-- statement-break
+CREATE PROCEDURE sp_get_user_by_id(IN p_user_id INT)
+BEGIN
+    SELECT id, username, email FROM user WHERE id = p_user_id AND deleted_at IS NULL;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_add_purchase;
+-- statement-break
+CREATE PROCEDURE sp_add_purchase(IN p_user_id INT, IN p_album_id INT, IN p_quantity INT)
+BEGIN
+    DECLARE v_stock INT;
+    DECLARE v_purchase_id INT;
+
+    IF NOT EXISTS (SELECT 1 FROM user WHERE id = p_user_id AND deleted_at IS NULL) THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Unknown user';
+    END IF;
+
+    -- Check current stock
+    SELECT stock INTO v_stock FROM album WHERE id = p_album_id AND deleted_at IS NULL FOR UPDATE;
+
+    IF v_stock IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Album not found';
+    END IF;
+
+    IF v_stock < p_quantity THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Insufficient stock for purchase';
+    END IF;
+
+    -- Insert purchase
+    INSERT INTO purchase (user_id, album_id, quantity) VALUES (p_user_id, p_album_id, p_quantity);
+    SET v_purchase_id = LAST_INSERT_ID();
+
+    -- Decrement stock
+    UPDATE album SET stock = stock - p_quantity WHERE id = p_album_id;
+
+    -- Return the purchase ID
+    SELECT v_purchase_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_update_user;
+-- statement-break
+CREATE PROCEDURE sp_update_user(IN p_user_id INT, IN p_username VARCHAR(255), IN p_email VARCHAR(255))
+BEGIN
+    DECLARE v_id INT;
+
+    -- NULL parameters leave the column unchanged
+    SELECT id INTO v_id FROM user WHERE id = p_user_id AND deleted_at IS NULL FOR UPDATE;
+
+    IF v_id IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'User not found';
+    END IF;
+
+    UPDATE user
+    SET username = COALESCE(p_username, username),
+        email = COALESCE(p_email, email)
+    WHERE id = p_user_id;
+
+    SELECT id, username, email FROM user WHERE id = p_user_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_delete_user;
+-- statement-break
+CREATE PROCEDURE sp_delete_user(IN p_user_id INT, IN p_on_purchases VARCHAR(16))
+BEGIN
+    DECLARE v_id INT;
+    DECLARE v_purchases INT;
+
+    SELECT id INTO v_id FROM user WHERE id = p_user_id AND deleted_at IS NULL FOR UPDATE;
+
+    IF v_id IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'User not found';
+    END IF;
+
+    SELECT COUNT(*) INTO v_purchases FROM purchase WHERE user_id = p_user_id;
+
+    IF v_purchases = 0 OR p_on_purchases = 'cascade' THEN
+        DELETE FROM purchase WHERE user_id = p_user_id;
+        DELETE FROM user WHERE id = p_user_id;
+    ELSEIF p_on_purchases = 'anonymize' THEN
+        UPDATE user
+        SET username = CONCAT('deleted-user-', p_user_id),
+            email = CONCAT('deleted-user-', p_user_id, '@invalid'),
+            deleted_at = CURRENT_TIMESTAMP
+        WHERE id = p_user_id;
+    ELSE
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'User has purchases';
+    END IF;
+
+    SELECT v_purchases;
+END;
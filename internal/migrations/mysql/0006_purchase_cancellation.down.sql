@@ -0,0 +1,45 @@
+DROP PROCEDURE IF EXISTS sp_cancel_purchase;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_purchases;
+-- statement-break
+CREATE PROCEDURE sp_get_all_purchases()
+BEGIN
+    SELECT id, user_id, album_id, quantity FROM purchase;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_purchases_by_user_id;
+-- statement-break
+CREATE PROCEDURE sp_get_purchases_by_user_id(IN p_user_id INT)
+BEGIN
+    SELECT id, user_id, album_id, quantity FROM purchase WHERE user_id = p_user_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_user_purchase_summary;
+-- statement-break
+CREATE PROCEDURE sp_get_user_purchase_summary(IN p_user_id INT)
+BEGIN
+    -- Get user info
+    SELECT id, username, email FROM user WHERE id = p_user_id;
+
+    -- Get purchase details with album info
+    SELECT p.id, p.album_id, a.title, a.artist, a.price, p.quantity
+    FROM purchase p
+    JOIN album a ON p.album_id = a.id
+    WHERE p.user_id = p_user_id
+    ORDER BY p.id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_users_purchase_summary;
+-- statement-break
+CREATE PROCEDURE sp_get_all_users_purchase_summary()
+BEGIN
+    SELECT u.id, u.username, u.email, p.id, p.album_id, a.title, a.artist, a.price, p.quantity
+    FROM user u
+    LEFT JOIN purchase p ON u.id = p.user_id
+    LEFT JOIN album a ON p.album_id = a.id
+    ORDER BY u.id, p.id;
+END;
+-- statement-break
+DROP TABLE IF EXISTS purchase_cancellation;
+-- statement-break
+ALTER TABLE purchase DROP COLUMN cancelled_quantity;
@@ -0,0 +1,81 @@
+-- cancelled_quantity counts the units of a purchase that were cancelled and
+-- returned to stock; each cancellation is logged in purchase_cancellation
+ALTER TABLE purchase ADD COLUMN cancelled_quantity INT NOT NULL DEFAULT 0;
+-- statement-break
+CREATE TABLE IF NOT EXISTS purchase_cancellation (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  purchase_id INT NOT NULL,
+  quantity INT NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (purchase_id) REFERENCES purchase(id) ON DELETE CASCADE
+);
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_purchases;
+-- statement-break
+CREATE PROCEDURE sp_get_all_purchases()
+BEGIN
+    SELECT id, user_id, album_id, quantity, cancelled_quantity FROM purchase;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_purchases_by_user_id;
+-- statement-break
+CREATE PROCEDURE sp_get_purchases_by_user_id(IN p_user_id INT)
+BEGIN
+    SELECT id, user_id, album_id, quantity, cancelled_quantity FROM purchase WHERE user_id = p_user_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_user_purchase_summary;
+-- statement-break
+CREATE PROCEDURE sp_get_user_purchase_summary(IN p_user_id INT)
+BEGIN
+    -- Get user info
+    SELECT id, username, email FROM user WHERE id = p_user_id;
+
+    -- Get purchase details with album info, counting only units not cancelled
+    SELECT p.id, p.album_id, a.title, a.artist, a.price, p.quantity - p.cancelled_quantity
+    FROM purchase p
+    JOIN album a ON p.album_id = a.id
+    WHERE p.user_id = p_user_id AND p.quantity > p.cancelled_quantity
+    ORDER BY p.id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_users_purchase_summary;
+-- statement-break
+CREATE PROCEDURE sp_get_all_users_purchase_summary()
+BEGIN
+    SELECT u.id, u.username, u.email, p.id, p.album_id, a.title, a.artist, a.price, p.quantity - p.cancelled_quantity
+    FROM user u
+    LEFT JOIN purchase p ON u.id = p.user_id AND p.quantity > p.cancelled_quantity
+    LEFT JOIN album a ON p.album_id = a.id
+    ORDER BY u.id, p.id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_cancel_purchase;
+-- statement-break
+CREATE PROCEDURE sp_cancel_purchase(IN p_purchase_id INT, IN p_quantity INT)
+BEGIN
+    DECLARE v_album_id INT;
+    DECLARE v_remaining INT;
+
+    SELECT album_id, quantity - cancelled_quantity INTO v_album_id, v_remaining
+    FROM purchase WHERE id = p_purchase_id FOR UPDATE;
+
+    IF v_album_id IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Purchase not found';
+    END IF;
+
+    -- A NULL quantity cancels every unit not cancelled yet
+    SET p_quantity = COALESCE(p_quantity, v_remaining);
+
+    IF p_quantity < 1 OR p_quantity > v_remaining THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Cancel quantity exceeds purchase';
+    END IF;
+
+    UPDATE purchase SET cancelled_quantity = cancelled_quantity + p_quantity WHERE id = p_purchase_id;
+    INSERT INTO purchase_cancellation (purchase_id, quantity) VALUES (p_purchase_id, p_quantity);
+
+    -- Return the units to stock
+    UPDATE album SET stock = stock + p_quantity WHERE id = v_album_id;
+
+    SELECT id, user_id, album_id, quantity, cancelled_quantity FROM purchase WHERE id = p_purchase_id;
+END;
@@ -0,0 +1,38 @@
+DROP PROCEDURE IF EXISTS sp_add_order_line;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_create_order;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_delete_user;
+-- statement-break
+CREATE PROCEDURE sp_delete_user(IN p_user_id INT, IN p_on_purchases VARCHAR(16))
+BEGIN
+    DECLARE v_id INT;
+    DECLARE v_purchases INT;
+
+    SELECT id INTO v_id FROM user WHERE id = p_user_id AND deleted_at IS NULL FOR UPDATE;
+
+    IF v_id IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'User not found';
+    END IF;
+
+    SELECT COUNT(*) INTO v_purchases FROM purchase WHERE user_id = p_user_id;
+
+    IF v_purchases = 0 OR p_on_purchases = 'cascade' THEN
+        DELETE FROM purchase WHERE user_id = p_user_id;
+        DELETE FROM user WHERE id = p_user_id;
+    ELSEIF p_on_purchases = 'anonymize' THEN
+        UPDATE user
+        SET username = CONCAT('deleted-user-', p_user_id),
+            email = CONCAT('deleted-user-', p_user_id, '@invalid'),
+            deleted_at = CURRENT_TIMESTAMP
+        WHERE id = p_user_id;
+    ELSE
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'User has purchases';
+    END IF;
+
+    SELECT v_purchases;
+END;
+-- statement-break
+ALTER TABLE purchase DROP FOREIGN KEY fk_purchase_order, DROP COLUMN order_id;
+-- statement-break
+DROP TABLE IF EXISTS orders;
@@ -0,0 +1,88 @@
+-- An order groups purchases placed together; each line is a purchase row
+CREATE TABLE IF NOT EXISTS orders (
+  id INT AUTO_INCREMENT PRIMARY KEY,
+  user_id INT NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (user_id) REFERENCES user(id)
+);
+-- statement-break
+ALTER TABLE purchase ADD COLUMN order_id INT NULL,
+  ADD CONSTRAINT fk_purchase_order FOREIGN KEY (order_id) REFERENCES orders(id);
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_create_order;
+-- statement-break
+CREATE PROCEDURE sp_create_order(IN p_user_id INT)
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM user WHERE id = p_user_id AND deleted_at IS NULL) THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Unknown user';
+    END IF;
+
+    INSERT INTO orders (user_id) VALUES (p_user_id);
+    SELECT LAST_INSERT_ID();
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_add_order_line;
+-- statement-break
+CREATE PROCEDURE sp_add_order_line(IN p_order_id INT, IN p_album_id INT, IN p_quantity INT)
+BEGIN
+    DECLARE v_user_id INT;
+    DECLARE v_stock INT;
+    DECLARE v_purchase_id INT;
+
+    SELECT user_id INTO v_user_id FROM orders WHERE id = p_order_id;
+
+    IF v_user_id IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Order not found';
+    END IF;
+
+    -- Lock the album row; callers add lines in album ID order so concurrent
+    -- orders take their locks in the same order
+    SELECT stock INTO v_stock FROM album WHERE id = p_album_id AND deleted_at IS NULL FOR UPDATE;
+
+    IF v_stock IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Album not found';
+    END IF;
+
+    IF v_stock < p_quantity THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Insufficient stock for purchase';
+    END IF;
+
+    INSERT INTO purchase (user_id, album_id, quantity, order_id) VALUES (v_user_id, p_album_id, p_quantity, p_order_id);
+    SET v_purchase_id = LAST_INSERT_ID();
+
+    UPDATE album SET stock = stock - p_quantity WHERE id = p_album_id;
+
+    SELECT v_purchase_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_delete_user;
+-- statement-break
+CREATE PROCEDURE sp_delete_user(IN p_user_id INT, IN p_on_purchases VARCHAR(16))
+BEGIN
+    DECLARE v_id INT;
+    DECLARE v_purchases INT;
+
+    SELECT id INTO v_id FROM user WHERE id = p_user_id AND deleted_at IS NULL FOR UPDATE;
+
+    IF v_id IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'User not found';
+    END IF;
+
+    SELECT COUNT(*) INTO v_purchases FROM purchase WHERE user_id = p_user_id;
+
+    IF v_purchases = 0 OR p_on_purchases = 'cascade' THEN
+        DELETE FROM purchase WHERE user_id = p_user_id;
+        DELETE FROM orders WHERE user_id = p_user_id;
+        DELETE FROM user WHERE id = p_user_id;
+    ELSEIF p_on_purchases = 'anonymize' THEN
+        UPDATE user
+        SET username = CONCAT('deleted-user-', p_user_id),
+            email = CONCAT('deleted-user-', p_user_id, '@invalid'),
+            deleted_at = CURRENT_TIMESTAMP
+        WHERE id = p_user_id;
+    ELSE
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'User has purchases';
+    END IF;
+
+    SELECT v_purchases;
+END;
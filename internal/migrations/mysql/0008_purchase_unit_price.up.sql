@@ -0,0 +1,105 @@
+-- Purchases record the price they were made at, so later album price changes
+-- do not rewrite history. Existing rows take the album's current price.
+ALTER TABLE purchase
+  ADD COLUMN unit_price DECIMAL(10, 2) NULL,
+  ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+-- statement-break
+UPDATE purchase p JOIN album a ON p.album_id = a.id SET p.unit_price = a.price;
+-- statement-break
+ALTER TABLE purchase MODIFY COLUMN unit_price DECIMAL(10, 2) NOT NULL;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_add_purchase;
+-- statement-break
+CREATE PROCEDURE sp_add_purchase(IN p_user_id INT, IN p_album_id INT, IN p_quantity INT)
+BEGIN
+    DECLARE v_stock INT;
+    DECLARE v_price DECIMAL(10, 2);
+    DECLARE v_purchase_id INT;
+
+    IF NOT EXISTS (SELECT 1 FROM user WHERE id = p_user_id AND deleted_at IS NULL) THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Unknown user';
+    END IF;
+
+    -- Check current stock
+    SELECT stock, price INTO v_stock, v_price FROM album WHERE id = p_album_id AND deleted_at IS NULL FOR UPDATE;
+
+    IF v_stock IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Album not found';
+    END IF;
+
+    IF v_stock < p_quantity THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Insufficient stock for purchase';
+    END IF;
+
+    -- Insert purchase
+    INSERT INTO purchase (user_id, album_id, quantity, unit_price) VALUES (p_user_id, p_album_id, p_quantity, v_price);
+    SET v_purchase_id = LAST_INSERT_ID();
+
+    -- Decrement stock
+    UPDATE album SET stock = stock - p_quantity WHERE id = p_album_id;
+
+    -- Return the purchase ID
+    SELECT v_purchase_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_add_order_line;
+-- statement-break
+CREATE PROCEDURE sp_add_order_line(IN p_order_id INT, IN p_album_id INT, IN p_quantity INT)
+BEGIN
+    DECLARE v_user_id INT;
+    DECLARE v_stock INT;
+    DECLARE v_price DECIMAL(10, 2);
+    DECLARE v_purchase_id INT;
+
+    SELECT user_id INTO v_user_id FROM orders WHERE id = p_order_id;
+
+    IF v_user_id IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Order not found';
+    END IF;
+
+    -- Lock the album row; callers add lines in album ID order so concurrent
+    -- orders take their locks in the same order
+    SELECT stock, price INTO v_stock, v_price FROM album WHERE id = p_album_id AND deleted_at IS NULL FOR UPDATE;
+
+    IF v_stock IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Album not found';
+    END IF;
+
+    IF v_stock < p_quantity THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Insufficient stock for purchase';
+    END IF;
+
+    INSERT INTO purchase (user_id, album_id, quantity, order_id, unit_price) VALUES (v_user_id, p_album_id, p_quantity, p_order_id, v_price);
+    SET v_purchase_id = LAST_INSERT_ID();
+
+    UPDATE album SET stock = stock - p_quantity WHERE id = p_album_id;
+
+    SELECT v_purchase_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_user_purchase_summary;
+-- statement-break
+CREATE PROCEDURE sp_get_user_purchase_summary(IN p_user_id INT)
+BEGIN
+    -- Get user info
+    SELECT id, username, email FROM user WHERE id = p_user_id;
+
+    -- Get purchase details with album info, priced as they were bought and
+    -- counting only units not cancelled
+    SELECT p.id, p.album_id, a.title, a.artist, p.unit_price, p.quantity - p.cancelled_quantity, p.currency
+    FROM purchase p
+    JOIN album a ON p.album_id = a.id
+    WHERE p.user_id = p_user_id AND p.quantity > p.cancelled_quantity
+    ORDER BY p.id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_users_purchase_summary;
+-- statement-break
+CREATE PROCEDURE sp_get_all_users_purchase_summary()
+BEGIN
+    SELECT u.id, u.username, u.email, p.id, p.album_id, a.title, a.artist, p.unit_price, p.quantity - p.cancelled_quantity, p.currency
+    FROM user u
+    LEFT JOIN purchase p ON u.id = p.user_id AND p.quantity > p.cancelled_quantity
+    LEFT JOIN album a ON p.album_id = a.id
+    ORDER BY u.id, p.id;
+END;
@@ -0,0 +1,7 @@
+DROP PROCEDURE IF EXISTS sp_list_users_purchase_summary;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_list_purchases;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_list_users;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_list_albums;
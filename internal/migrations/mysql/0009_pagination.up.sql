@@ -0,0 +1,39 @@
+-- Keyset pagination: each procedure returns up to p_limit rows with an ID
+-- greater than p_after_id, in ID order. Callers ask for one row more than the
+-- page size to learn whether another page follows.
+CREATE PROCEDURE sp_list_albums(IN p_after_id INT, IN p_limit INT)
+BEGIN
+    SELECT id, title, artist, price, stock
+    FROM album
+    WHERE id > p_after_id AND deleted_at IS NULL
+    ORDER BY id
+    LIMIT p_limit;
+END;
+-- statement-break
+CREATE PROCEDURE sp_list_users(IN p_after_id INT, IN p_limit INT)
+BEGIN
+    SELECT id, username, email
+    FROM user
+    WHERE id > p_after_id AND deleted_at IS NULL
+    ORDER BY id
+    LIMIT p_limit;
+END;
+-- statement-break
+CREATE PROCEDURE sp_list_purchases(IN p_after_id INT, IN p_limit INT)
+BEGIN
+    SELECT id, user_id, album_id, quantity, cancelled_quantity
+    FROM purchase
+    WHERE id > p_after_id
+    ORDER BY id
+    LIMIT p_limit;
+END;
+-- statement-break
+-- The limit counts users, so it is applied in a derived table before the join
+CREATE PROCEDURE sp_list_users_purchase_summary(IN p_after_id INT, IN p_limit INT)
+BEGIN
+    SELECT u.id, u.username, u.email, p.id, p.album_id, a.title, a.artist, p.unit_price, p.quantity - p.cancelled_quantity, p.currency
+    FROM (SELECT id, username, email FROM user WHERE id > p_after_id ORDER BY id LIMIT p_limit) u
+    LEFT JOIN purchase p ON u.id = p.user_id AND p.quantity > p.cancelled_quantity
+    LEFT JOIN album a ON p.album_id = a.id
+    ORDER BY u.id, p.id;
+END;
@@ -0,0 +1,3 @@
+DROP PROCEDURE IF EXISTS sp_search_catalog;
+-- statement-break
+ALTER TABLE album DROP INDEX ft_album_title_artist;
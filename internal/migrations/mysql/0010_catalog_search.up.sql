@@ -0,0 +1,13 @@
+-- Full-text index for searchCatalog. The store sends a boolean-mode query
+-- such as "blue* tra*", so partial words match and albums matching more words
+-- rank higher.
+ALTER TABLE album ADD FULLTEXT INDEX ft_album_title_artist (title, artist);
+-- statement-break
+CREATE PROCEDURE sp_search_catalog(IN p_query VARCHAR(1024), IN p_limit INT)
+BEGIN
+    SELECT id, title, artist, price, stock, MATCH (title, artist) AGAINST (p_query IN BOOLEAN MODE) AS score
+    FROM album
+    WHERE deleted_at IS NULL AND MATCH (title, artist) AGAINST (p_query IN BOOLEAN MODE)
+    ORDER BY score DESC, id
+    LIMIT p_limit;
+END;
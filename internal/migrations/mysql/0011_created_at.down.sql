@@ -0,0 +1,62 @@
+DROP PROCEDURE IF EXISTS sp_cancel_purchase;
+-- statement-break
+CREATE PROCEDURE sp_cancel_purchase(IN p_purchase_id INT, IN p_quantity INT)
+BEGIN
+    DECLARE v_album_id INT;
+    DECLARE v_remaining INT;
+
+    SELECT album_id, quantity - cancelled_quantity INTO v_album_id, v_remaining
+    FROM purchase WHERE id = p_purchase_id FOR UPDATE;
+
+    IF v_album_id IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Purchase not found';
+    END IF;
+
+    -- A NULL quantity cancels every unit not cancelled yet
+    SET p_quantity = COALESCE(p_quantity, v_remaining);
+
+    IF p_quantity < 1 OR p_quantity > v_remaining THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Cancel quantity exceeds purchase';
+    END IF;
+
+    UPDATE purchase SET cancelled_quantity = cancelled_quantity + p_quantity WHERE id = p_purchase_id;
+    INSERT INTO purchase_cancellation (purchase_id, quantity) VALUES (p_purchase_id, p_quantity);
+
+    -- Return the units to stock
+    UPDATE album SET stock = stock + p_quantity WHERE id = v_album_id;
+
+    SELECT id, user_id, album_id, quantity, cancelled_quantity FROM purchase WHERE id = p_purchase_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_list_purchases;
+-- statement-break
+CREATE PROCEDURE sp_list_purchases(IN p_after_id INT, IN p_limit INT)
+BEGIN
+    SELECT id, user_id, album_id, quantity, cancelled_quantity
+    FROM purchase
+    WHERE id > p_after_id
+    ORDER BY id
+    LIMIT p_limit;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_purchases_by_user_id;
+-- statement-break
+CREATE PROCEDURE sp_get_purchases_by_user_id(IN p_user_id INT)
+BEGIN
+    SELECT id, user_id, album_id, quantity, cancelled_quantity FROM purchase WHERE user_id = p_user_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_purchases;
+-- statement-break
+CREATE PROCEDURE sp_get_all_purchases()
+BEGIN
+    SELECT id, user_id, album_id, quantity, cancelled_quantity FROM purchase;
+END;
+-- statement-break
+DROP INDEX idx_purchase_created_at ON purchase;
+-- statement-break
+ALTER TABLE purchase DROP COLUMN created_at;
+-- statement-break
+ALTER TABLE user DROP COLUMN created_at;
+-- statement-break
+ALTER TABLE album DROP COLUMN created_at;
@@ -0,0 +1,72 @@
+-- Creation timestamps, in UTC: the server sets the session time zone to
+-- +00:00. Existing rows take the time of the migration.
+ALTER TABLE album ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP;
+-- statement-break
+ALTER TABLE user ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP;
+-- statement-break
+ALTER TABLE purchase ADD COLUMN created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP;
+-- statement-break
+CREATE INDEX idx_purchase_created_at ON purchase (created_at);
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_all_purchases;
+-- statement-break
+CREATE PROCEDURE sp_get_all_purchases()
+BEGIN
+    SELECT id, user_id, album_id, quantity, cancelled_quantity, created_at FROM purchase;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_get_purchases_by_user_id;
+-- statement-break
+-- p_from (inclusive) and p_to (exclusive) bound created_at; NULL leaves that end open
+CREATE PROCEDURE sp_get_purchases_by_user_id(IN p_user_id INT, IN p_from DATETIME, IN p_to DATETIME)
+BEGIN
+    SELECT id, user_id, album_id, quantity, cancelled_quantity, created_at
+    FROM purchase
+    WHERE user_id = p_user_id
+      AND (p_from IS NULL OR created_at >= p_from)
+      AND (p_to IS NULL OR created_at < p_to)
+    ORDER BY id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_list_purchases;
+-- statement-break
+CREATE PROCEDURE sp_list_purchases(IN p_after_id INT, IN p_limit INT, IN p_from DATETIME, IN p_to DATETIME)
+BEGIN
+    SELECT id, user_id, album_id, quantity, cancelled_quantity, created_at
+    FROM purchase
+    WHERE id > p_after_id
+      AND (p_from IS NULL OR created_at >= p_from)
+      AND (p_to IS NULL OR created_at < p_to)
+    ORDER BY id
+    LIMIT p_limit;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_cancel_purchase;
+-- statement-break
+CREATE PROCEDURE sp_cancel_purchase(IN p_purchase_id INT, IN p_quantity INT)
+BEGIN
+    DECLARE v_album_id INT;
+    DECLARE v_remaining INT;
+
+    SELECT album_id, quantity - cancelled_quantity INTO v_album_id, v_remaining
+    FROM purchase WHERE id = p_purchase_id FOR UPDATE;
+
+    IF v_album_id IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Purchase not found';
+    END IF;
+
+    -- A NULL quantity cancels every unit not cancelled yet
+    SET p_quantity = COALESCE(p_quantity, v_remaining);
+
+    IF p_quantity < 1 OR p_quantity > v_remaining THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Cancel quantity exceeds purchase';
+    END IF;
+
+    UPDATE purchase SET cancelled_quantity = cancelled_quantity + p_quantity WHERE id = p_purchase_id;
+    INSERT INTO purchase_cancellation (purchase_id, quantity) VALUES (p_purchase_id, p_quantity);
+
+    -- Return the units to stock
+    UPDATE album SET stock = stock + p_quantity WHERE id = v_album_id;
+
+    SELECT id, user_id, album_id, quantity, cancelled_quantity, created_at FROM purchase WHERE id = p_purchase_id;
+END;
@@ -0,0 +1,7 @@
+DROP PROCEDURE IF EXISTS sp_report_order_value;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_report_sales_by_period;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_report_artist_revenue;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_report_top_albums;
@@ -0,0 +1,69 @@
+-- Sales reports. Each counts only units not cancelled, priced at the unit
+-- price they were bought at, over purchases with p_from <= created_at < p_to.
+-- A NULL bound leaves that end of the range open.
+CREATE PROCEDURE sp_report_top_albums(IN p_from DATETIME, IN p_to DATETIME, IN p_limit INT)
+BEGIN
+    SELECT a.id, a.title, a.artist,
+           SUM(p.quantity - p.cancelled_quantity) AS units,
+           SUM((p.quantity - p.cancelled_quantity) * p.unit_price) AS revenue
+    FROM purchase p
+    JOIN album a ON p.album_id = a.id
+    WHERE p.quantity > p.cancelled_quantity
+      AND (p_from IS NULL OR p.created_at >= p_from)
+      AND (p_to IS NULL OR p.created_at < p_to)
+    GROUP BY a.id, a.title, a.artist
+    ORDER BY units DESC, revenue DESC, a.id
+    LIMIT p_limit;
+END;
+-- statement-break
+CREATE PROCEDURE sp_report_artist_revenue(IN p_from DATETIME, IN p_to DATETIME, IN p_limit INT)
+BEGIN
+    SELECT a.artist,
+           SUM(p.quantity - p.cancelled_quantity) AS units,
+           SUM((p.quantity - p.cancelled_quantity) * p.unit_price) AS revenue
+    FROM purchase p
+    JOIN album a ON p.album_id = a.id
+    WHERE p.quantity > p.cancelled_quantity
+      AND (p_from IS NULL OR p.created_at >= p_from)
+      AND (p_to IS NULL OR p.created_at < p_to)
+    GROUP BY a.artist
+    ORDER BY revenue DESC, a.artist
+    LIMIT p_limit;
+END;
+-- statement-break
+-- p_period is 'day', 'week' or 'month'; each row is keyed by the first day of
+-- its period, weeks starting on Monday
+CREATE PROCEDURE sp_report_sales_by_period(IN p_period VARCHAR(10), IN p_from DATETIME, IN p_to DATETIME)
+BEGIN
+    IF p_period NOT IN ('day', 'week', 'month') THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Unknown report period';
+    END IF;
+
+    SELECT CASE p_period
+               WHEN 'day' THEN DATE_FORMAT(p.created_at, '%Y-%m-%d')
+               WHEN 'week' THEN DATE_FORMAT(DATE_SUB(DATE(p.created_at), INTERVAL WEEKDAY(p.created_at) DAY), '%Y-%m-%d')
+               ELSE DATE_FORMAT(p.created_at, '%Y-%m-01')
+           END AS period,
+           SUM(p.quantity - p.cancelled_quantity) AS units,
+           SUM((p.quantity - p.cancelled_quantity) * p.unit_price) AS revenue
+    FROM purchase p
+    WHERE p.quantity > p.cancelled_quantity
+      AND (p_from IS NULL OR p.created_at >= p_from)
+      AND (p_to IS NULL OR p.created_at < p_to)
+    GROUP BY period
+    ORDER BY period;
+END;
+-- statement-break
+-- A purchase made on its own with sp_add_purchase has no order_id and counts
+-- as an order by itself; -id keeps it apart from the real order IDs
+CREATE PROCEDURE sp_report_order_value(IN p_from DATETIME, IN p_to DATETIME)
+BEGIN
+    SELECT COUNT(DISTINCT COALESCE(p.order_id, -p.id)) AS orders,
+           COALESCE(SUM(p.quantity - p.cancelled_quantity), 0) AS units,
+           COALESCE(SUM((p.quantity - p.cancelled_quantity) * p.unit_price), 0) AS revenue,
+           COALESCE(ROUND(SUM((p.quantity - p.cancelled_quantity) * p.unit_price) / COUNT(DISTINCT COALESCE(p.order_id, -p.id)), 2), 0) AS average_order_value
+    FROM purchase p
+    WHERE p.quantity > p.cancelled_quantity
+      AND (p_from IS NULL OR p.created_at >= p_from)
+      AND (p_to IS NULL OR p.created_at < p_to);
+END;
@@ -0,0 +1,13 @@
+-- Anonymized users are hidden from the all-users summary like from the other
+-- user queries
+DROP PROCEDURE IF EXISTS sp_get_all_users_purchase_summary;
+-- statement-break
+CREATE PROCEDURE sp_get_all_users_purchase_summary()
+BEGIN
+    SELECT u.id, u.username, u.email, p.id, p.album_id, a.title, a.artist, p.unit_price, p.quantity - p.cancelled_quantity, p.currency
+    FROM user u
+    LEFT JOIN purchase p ON u.id = p.user_id AND p.quantity > p.cancelled_quantity
+    LEFT JOIN album a ON p.album_id = a.id
+    WHERE u.deleted_at IS NULL
+    ORDER BY u.id, p.id;
+END;
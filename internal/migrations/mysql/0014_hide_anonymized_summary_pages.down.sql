@@ -0,0 +1,10 @@
+DROP PROCEDURE IF EXISTS sp_list_users_purchase_summary;
+-- statement-break
+CREATE PROCEDURE sp_list_users_purchase_summary(IN p_after_id INT, IN p_limit INT)
+BEGIN
+    SELECT u.id, u.username, u.email, p.id, p.album_id, a.title, a.artist, p.unit_price, p.quantity - p.cancelled_quantity, p.currency
+    FROM (SELECT id, username, email FROM user WHERE id > p_after_id ORDER BY id LIMIT p_limit) u
+    LEFT JOIN purchase p ON u.id = p.user_id AND p.quantity > p.cancelled_quantity
+    LEFT JOIN album a ON p.album_id = a.id
+    ORDER BY u.id, p.id;
+END;
@@ -0,0 +1,98 @@
+DROP PROCEDURE IF EXISTS sp_add_purchase;
+-- statement-break
+CREATE PROCEDURE sp_add_purchase(IN p_user_id INT, IN p_album_id INT, IN p_quantity INT)
+BEGIN
+    DECLARE v_stock INT;
+    DECLARE v_price DECIMAL(10, 2);
+    DECLARE v_purchase_id INT;
+
+    IF NOT EXISTS (SELECT 1 FROM user WHERE id = p_user_id AND deleted_at IS NULL) THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Unknown user';
+    END IF;
+
+    -- Check current stock
+    SELECT stock, price INTO v_stock, v_price FROM album WHERE id = p_album_id AND deleted_at IS NULL FOR UPDATE;
+
+    IF v_stock IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Album not found';
+    END IF;
+
+    IF v_stock < p_quantity THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Insufficient stock for purchase';
+    END IF;
+
+    -- Insert purchase
+    INSERT INTO purchase (user_id, album_id, quantity, unit_price) VALUES (p_user_id, p_album_id, p_quantity, v_price);
+    SET v_purchase_id = LAST_INSERT_ID();
+
+    -- Decrement stock
+    UPDATE album SET stock = stock - p_quantity WHERE id = p_album_id;
+
+    -- Return the purchase ID
+    SELECT v_purchase_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_add_order_line;
+-- statement-break
+CREATE PROCEDURE sp_add_order_line(IN p_order_id INT, IN p_album_id INT, IN p_quantity INT)
+BEGIN
+    DECLARE v_user_id INT;
+    DECLARE v_stock INT;
+    DECLARE v_price DECIMAL(10, 2);
+    DECLARE v_purchase_id INT;
+
+    SELECT user_id INTO v_user_id FROM orders WHERE id = p_order_id;
+
+    IF v_user_id IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Order not found';
+    END IF;
+
+    -- Lock the album row; callers add lines in album ID order so concurrent
+    -- orders take their locks in the same order
+    SELECT stock, price INTO v_stock, v_price FROM album WHERE id = p_album_id AND deleted_at IS NULL FOR UPDATE;
+
+    IF v_stock IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Album not found';
+    END IF;
+
+    IF v_stock < p_quantity THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Insufficient stock for purchase';
+    END IF;
+
+    INSERT INTO purchase (user_id, album_id, quantity, order_id, unit_price) VALUES (v_user_id, p_album_id, p_quantity, p_order_id, v_price);
+    SET v_purchase_id = LAST_INSERT_ID();
+
+    UPDATE album SET stock = stock - p_quantity WHERE id = p_album_id;
+
+    SELECT v_purchase_id;
+END;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_cancel_purchase;
+-- statement-break
+CREATE PROCEDURE sp_cancel_purchase(IN p_purchase_id INT, IN p_quantity INT)
+BEGIN
+    DECLARE v_album_id INT;
+    DECLARE v_remaining INT;
+
+    SELECT album_id, quantity - cancelled_quantity INTO v_album_id, v_remaining
+    FROM purchase WHERE id = p_purchase_id FOR UPDATE;
+
+    IF v_album_id IS NULL THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Purchase not found';
+    END IF;
+
+    -- A NULL quantity cancels every unit not cancelled yet
+    SET p_quantity = COALESCE(p_quantity, v_remaining);
+
+    IF p_quantity < 1 OR p_quantity > v_remaining THEN
+        SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'Cancel quantity exceeds purchase';
+    END IF;
+
+    UPDATE purchase SET cancelled_quantity = cancelled_quantity + p_quantity WHERE id = p_purchase_id;
+    INSERT INTO purchase_cancellation (purchase_id, quantity) VALUES (p_purchase_id, p_quantity);
+
+    -- Return the units to stock
+    UPDATE album SET stock = stock + p_quantity WHERE id = v_album_id;
+
+    SELECT id, user_id, album_id, quantity, cancelled_quantity, created_at FROM purchase WHERE id = p_purchase_id;
+END;
@@ -0,0 +1,5 @@
+DROP PROCEDURE IF EXISTS sp_search_catalog_typos;
+-- statement-break
+ALTER TABLE album DROP INDEX ft_album_search_text_ngram;
+-- statement-break
+ALTER TABLE album DROP COLUMN search_text;
@@ -0,0 +1,18 @@
+-- Typo-tolerant catalog search. The ngram parser indexes every pair of
+-- letters, so a misspelled word still shares most of its pairs with the word
+-- meant. FULLTEXT indexes must differ in their columns, hence the generated
+-- column of title and artist.
+ALTER TABLE album ADD COLUMN search_text VARCHAR(511) AS (CONCAT_WS(' ', title, artist)) STORED;
+-- statement-break
+ALTER TABLE album ADD FULLTEXT INDEX ft_album_search_text_ngram (search_text) WITH PARSER ngram;
+-- statement-break
+-- Candidates for the words of p_query with a typo, most shared letter pairs
+-- first; the store keeps those with a word close enough to a query word
+CREATE PROCEDURE sp_search_catalog_typos(IN p_query VARCHAR(1024), IN p_limit INT)
+BEGIN
+    SELECT id, title, artist, price, stock
+    FROM album
+    WHERE deleted_at IS NULL AND MATCH (search_text) AGAINST (p_query IN NATURAL LANGUAGE MODE)
+    ORDER BY MATCH (search_text) AGAINST (p_query IN NATURAL LANGUAGE MODE) DESC, id
+    LIMIT p_limit;
+END;
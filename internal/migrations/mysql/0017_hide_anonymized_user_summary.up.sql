@@ -0,0 +1,16 @@
+-- An anonymized user's summary is NOT_FOUND like the user itself
+DROP PROCEDURE IF EXISTS sp_get_user_purchase_summary;
+-- statement-break
+CREATE PROCEDURE sp_get_user_purchase_summary(IN p_user_id INT)
+BEGIN
+    -- Get user info
+    SELECT id, username, email FROM user WHERE id = p_user_id AND deleted_at IS NULL;
+
+    -- Get purchase details with album info, priced as they were bought and
+    -- counting only units not cancelled
+    SELECT p.id, p.album_id, a.title, a.artist, p.unit_price, p.quantity - p.cancelled_quantity, p.currency
+    FROM purchase p
+    JOIN album a ON p.album_id = a.id
+    WHERE p.user_id = p_user_id AND p.quantity > p.cancelled_quantity
+    ORDER BY p.id;
+END;
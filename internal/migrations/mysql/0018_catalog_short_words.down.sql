@@ -0,0 +1 @@
+DROP PROCEDURE IF EXISTS sp_search_catalog_words;
@@ -0,0 +1,11 @@
+-- The FULLTEXT index leaves out words shorter than innodb_ft_min_token_size
+-- and stopwords, so searchCatalog looks those up with a regular expression on
+-- search_text instead. p_pattern matches the start of any of the words.
+CREATE PROCEDURE sp_search_catalog_words(IN p_pattern VARCHAR(1024), IN p_limit INT)
+BEGIN
+    SELECT id, title, artist, price, stock
+    FROM album
+    WHERE deleted_at IS NULL AND REGEXP_LIKE(search_text, p_pattern, 'i')
+    ORDER BY id
+    LIMIT p_limit;
+END;
@@ -0,0 +1,3 @@
+DROP PROCEDURE IF EXISTS sp_list_purchases_by_user_id;
+-- statement-break
+DROP PROCEDURE IF EXISTS sp_list_albums_by_artist;
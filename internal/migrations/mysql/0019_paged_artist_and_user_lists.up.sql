@@ -0,0 +1,22 @@
+-- Keyset pagination for getAlbumByArtist and getPurchasesByUserID, like the
+-- procedures of 0009_pagination
+CREATE PROCEDURE sp_list_albums_by_artist(IN p_artist VARCHAR(255), IN p_after_id INT, IN p_limit INT)
+BEGIN
+    SELECT id, title, artist, price, stock
+    FROM album
+    WHERE artist = p_artist AND id > p_after_id AND deleted_at IS NULL
+    ORDER BY id
+    LIMIT p_limit;
+END;
+-- statement-break
+-- p_from (inclusive) and p_to (exclusive) bound created_at; NULL leaves that end open
+CREATE PROCEDURE sp_list_purchases_by_user_id(IN p_user_id INT, IN p_after_id INT, IN p_limit INT, IN p_from DATETIME, IN p_to DATETIME)
+BEGIN
+    SELECT id, user_id, album_id, quantity, cancelled_quantity, created_at
+    FROM purchase
+    WHERE user_id = p_user_id AND id > p_after_id
+      AND (p_from IS NULL OR created_at >= p_from)
+      AND (p_to IS NULL OR created_at < p_to)
+    ORDER BY id
+    LIMIT p_limit;
+END;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS purchase;
+-- statement-break
+DROP TABLE IF EXISTS user;
+-- statement-break
+DROP TABLE IF EXISTS album;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS album (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  title TEXT NOT NULL,
+  artist TEXT NOT NULL,
+  price REAL NOT NULL,
+  stock INTEGER NOT NULL DEFAULT 0
+);
+-- statement-break
+CREATE TABLE IF NOT EXISTS user (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  username TEXT UNIQUE NOT NULL,
+  email TEXT UNIQUE NOT NULL
+);
+-- statement-break
+CREATE TABLE IF NOT EXISTS purchase (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  album_id INTEGER NOT NULL,
+  quantity INTEGER NOT NULL DEFAULT 1,
+  FOREIGN KEY (user_id) REFERENCES user(id),
+  FOREIGN KEY (album_id) REFERENCES album(id)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS album_restock;
+-- statement-break
+ALTER TABLE album DROP COLUMN deleted_at;
@@ -0,0 +1,12 @@
+-- Soft-deleted albums keep their row so purchases still resolve, but are
+-- hidden from album queries and cannot be bought
+ALTER TABLE album ADD COLUMN deleted_at TEXT;
+-- statement-break
+CREATE TABLE IF NOT EXISTS album_restock (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  album_id INTEGER NOT NULL,
+  quantity INTEGER NOT NULL,
+  reason TEXT NOT NULL,
+  created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (album_id) REFERENCES album(id)
+);
@@ -0,0 +1,3 @@
+-- Anonymized users keep their row so purchases still resolve, but are hidden
+-- from user queries and cannot make purchases
+ALTER TABLE user ADD COLUMN deleted_at TEXT;
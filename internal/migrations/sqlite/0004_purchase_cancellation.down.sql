@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS purchase_cancellation;
+-- statement-break
+ALTER TABLE purchase DROP COLUMN cancelled_quantity;
@@ -0,0 +1,11 @@
+-- cancelled_quantity counts the units of a purchase that were cancelled and
+-- returned to stock; each cancellation is logged in purchase_cancellation
+ALTER TABLE purchase ADD COLUMN cancelled_quantity INTEGER NOT NULL DEFAULT 0;
+-- statement-break
+CREATE TABLE IF NOT EXISTS purchase_cancellation (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  purchase_id INTEGER NOT NULL,
+  quantity INTEGER NOT NULL,
+  created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (purchase_id) REFERENCES purchase(id) ON DELETE CASCADE
+);
@@ -0,0 +1,3 @@
+ALTER TABLE purchase DROP COLUMN order_id;
+-- statement-break
+DROP TABLE IF EXISTS orders;
@@ -0,0 +1,9 @@
+-- An order groups purchases placed together; each line is a purchase row
+CREATE TABLE IF NOT EXISTS orders (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL,
+  created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (user_id) REFERENCES user(id)
+);
+-- statement-break
+ALTER TABLE purchase ADD COLUMN order_id INTEGER REFERENCES orders(id);
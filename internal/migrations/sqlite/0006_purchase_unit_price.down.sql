@@ -0,0 +1,3 @@
+ALTER TABLE purchase DROP COLUMN currency;
+-- statement-break
+ALTER TABLE purchase DROP COLUMN unit_price;
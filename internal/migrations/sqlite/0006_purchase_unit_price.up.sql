@@ -0,0 +1,7 @@
+-- Purchases record the price they were made at, so later album price changes
+-- do not rewrite history. Existing rows take the album's current price.
+ALTER TABLE purchase ADD COLUMN unit_price REAL NOT NULL DEFAULT 0;
+-- statement-break
+ALTER TABLE purchase ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+-- statement-break
+UPDATE purchase SET unit_price = (SELECT price FROM album WHERE album.id = purchase.album_id);
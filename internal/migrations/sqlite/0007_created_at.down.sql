@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_purchase_created_at;
+-- statement-break
+DROP TRIGGER IF EXISTS purchase_created_at;
+-- statement-break
+DROP TRIGGER IF EXISTS user_created_at;
+-- statement-break
+DROP TRIGGER IF EXISTS album_created_at;
+-- statement-break
+ALTER TABLE purchase DROP COLUMN created_at;
+-- statement-break
+ALTER TABLE user DROP COLUMN created_at;
+-- statement-break
+ALTER TABLE album DROP COLUMN created_at;
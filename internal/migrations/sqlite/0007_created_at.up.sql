@@ -0,0 +1,28 @@
+-- Creation timestamps, in UTC. SQLite cannot add a column with a
+-- CURRENT_TIMESTAMP default, so existing rows are filled in here and
+-- triggers stamp new rows that do not set one.
+ALTER TABLE album ADD COLUMN created_at DATETIME;
+-- statement-break
+ALTER TABLE user ADD COLUMN created_at DATETIME;
+-- statement-break
+ALTER TABLE purchase ADD COLUMN created_at DATETIME;
+-- statement-break
+UPDATE album SET created_at = CURRENT_TIMESTAMP;
+-- statement-break
+UPDATE user SET created_at = CURRENT_TIMESTAMP;
+-- statement-break
+UPDATE purchase SET created_at = CURRENT_TIMESTAMP;
+-- statement-break
+CREATE TRIGGER album_created_at AFTER INSERT ON album WHEN new.created_at IS NULL BEGIN
+    UPDATE album SET created_at = CURRENT_TIMESTAMP WHERE id = new.id;
+END;
+-- statement-break
+CREATE TRIGGER user_created_at AFTER INSERT ON user WHEN new.created_at IS NULL BEGIN
+    UPDATE user SET created_at = CURRENT_TIMESTAMP WHERE id = new.id;
+END;
+-- statement-break
+CREATE TRIGGER purchase_created_at AFTER INSERT ON purchase WHEN new.created_at IS NULL BEGIN
+    UPDATE purchase SET created_at = CURRENT_TIMESTAMP WHERE id = new.id;
+END;
+-- statement-break
+CREATE INDEX idx_purchase_created_at ON purchase (created_at);
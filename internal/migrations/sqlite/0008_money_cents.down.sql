@@ -0,0 +1,15 @@
+ALTER TABLE purchase ADD COLUMN unit_price_real REAL NOT NULL DEFAULT 0;
+-- statement-break
+UPDATE purchase SET unit_price_real = unit_price / 100.0;
+-- statement-break
+ALTER TABLE purchase DROP COLUMN unit_price;
+-- statement-break
+ALTER TABLE purchase RENAME COLUMN unit_price_real TO unit_price;
+-- statement-break
+ALTER TABLE album ADD COLUMN price_real REAL NOT NULL DEFAULT 0;
+-- statement-break
+UPDATE album SET price_real = price / 100.0;
+-- statement-break
+ALTER TABLE album DROP COLUMN price;
+-- statement-break
+ALTER TABLE album RENAME COLUMN price_real TO price;
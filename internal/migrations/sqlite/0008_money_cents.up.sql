@@ -0,0 +1,18 @@
+-- Money is stored as INTEGER cents, so it is exact like MySQL's DECIMAL(10, 2).
+-- SQLite cannot change a column's type, so each column is copied into a new
+-- one that then takes its name.
+ALTER TABLE album ADD COLUMN price_cents INTEGER NOT NULL DEFAULT 0;
+-- statement-break
+UPDATE album SET price_cents = CAST(ROUND(price * 100) AS INTEGER);
+-- statement-break
+ALTER TABLE album DROP COLUMN price;
+-- statement-break
+ALTER TABLE album RENAME COLUMN price_cents TO price;
+-- statement-break
+ALTER TABLE purchase ADD COLUMN unit_price_cents INTEGER NOT NULL DEFAULT 0;
+-- statement-break
+UPDATE purchase SET unit_price_cents = CAST(ROUND(unit_price * 100) AS INTEGER);
+-- statement-break
+ALTER TABLE purchase DROP COLUMN unit_price;
+-- statement-break
+ALTER TABLE purchase RENAME COLUMN unit_price_cents TO unit_price;
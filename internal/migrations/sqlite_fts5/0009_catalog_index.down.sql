@@ -0,0 +1,15 @@
+DROP TRIGGER IF EXISTS album_trigram_update;
+-- statement-break
+DROP TRIGGER IF EXISTS album_trigram_delete;
+-- statement-break
+DROP TRIGGER IF EXISTS album_trigram_insert;
+-- statement-break
+DROP TABLE IF EXISTS album_trigram;
+-- statement-break
+DROP TRIGGER IF EXISTS album_fts_update;
+-- statement-break
+DROP TRIGGER IF EXISTS album_fts_delete;
+-- statement-break
+DROP TRIGGER IF EXISTS album_fts_insert;
+-- statement-break
+DROP TABLE IF EXISTS album_fts;
@@ -0,0 +1,42 @@
+-- Full-text index for searchCatalog: album_fts holds the words of titles and
+-- artists, album_trigram every three letters of them for words with a typo.
+-- Both read their text from the album table, and triggers keep them in step
+-- with it. IF NOT EXISTS adopts the indexes the server used to create at
+-- startup before this migration existed; the rebuilds fill them in either way.
+CREATE VIRTUAL TABLE IF NOT EXISTS album_fts USING fts5(
+    title, artist, content='album', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+);
+-- statement-break
+CREATE TRIGGER IF NOT EXISTS album_fts_insert AFTER INSERT ON album BEGIN
+    INSERT INTO album_fts (rowid, title, artist) VALUES (new.id, new.title, new.artist);
+END;
+-- statement-break
+CREATE TRIGGER IF NOT EXISTS album_fts_delete AFTER DELETE ON album BEGIN
+    INSERT INTO album_fts (album_fts, rowid, title, artist) VALUES ('delete', old.id, old.title, old.artist);
+END;
+-- statement-break
+CREATE TRIGGER IF NOT EXISTS album_fts_update AFTER UPDATE OF title, artist ON album BEGIN
+    INSERT INTO album_fts (album_fts, rowid, title, artist) VALUES ('delete', old.id, old.title, old.artist);
+    INSERT INTO album_fts (rowid, title, artist) VALUES (new.id, new.title, new.artist);
+END;
+-- statement-break
+INSERT INTO album_fts (album_fts) VALUES ('rebuild');
+-- statement-break
+CREATE VIRTUAL TABLE IF NOT EXISTS album_trigram USING fts5(
+    title, artist, content='album', content_rowid='id', tokenize='trigram'
+);
+-- statement-break
+CREATE TRIGGER IF NOT EXISTS album_trigram_insert AFTER INSERT ON album BEGIN
+    INSERT INTO album_trigram (rowid, title, artist) VALUES (new.id, new.title, new.artist);
+END;
+-- statement-break
+CREATE TRIGGER IF NOT EXISTS album_trigram_delete AFTER DELETE ON album BEGIN
+    INSERT INTO album_trigram (album_trigram, rowid, title, artist) VALUES ('delete', old.id, old.title, old.artist);
+END;
+-- statement-break
+CREATE TRIGGER IF NOT EXISTS album_trigram_update AFTER UPDATE OF title, artist ON album BEGIN
+    INSERT INTO album_trigram (album_trigram, rowid, title, artist) VALUES ('delete', old.id, old.title, old.artist);
+    INSERT INTO album_trigram (rowid, title, artist) VALUES (new.id, new.title, new.artist);
+END;
+-- statement-break
+INSERT INTO album_trigram (album_trigram) VALUES ('rebuild');
@@ -1,14 +1,51 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // Album represents an album record in the database
 type Album struct {
 	ID     int64
 	Title  string
 	Artist string
-	Price  float32
+	Price  Money
 	Stock  int
 }
 
+// AlbumUpdate holds the album fields to change; nil fields are left as they are
+type AlbumUpdate struct {
+	Title  *string
+	Artist *string
+	Price  *Money
+	Stock  *int
+}
+
+// AlbumSearch filters and sorts albums. Zero-valued filters match every album.
+type AlbumSearch struct {
+	Title       string // case-insensitive substring of the title
+	Artist      string // case-insensitive substring of the artist
+	MinPrice    *Money
+	MaxPrice    *Money
+	InStockOnly bool
+	SortBy      string // "id", "title", "artist", "price" or "stock"
+	Descending  bool
+	Limit       int
+	// After is the last album of the previous page; only its ID and the sort
+	// field are used. Nil starts from the first page.
+	After *Album
+}
+
+// CatalogMatch is one album found by a full-text catalog search. The
+// highlights are HTML-escaped with every matching word wrapped in <mark>.
+type CatalogMatch struct {
+	Album           Album   `json:"album"`
+	Score           float64 `json:"score"` // higher is more relevant; only comparable within one search
+	TitleHighlight  string  `json:"title_highlight"`
+	ArtistHighlight string  `json:"artist_highlight"`
+}
+
 // User represents a user record in the database
 type User struct {
 	ID       int64
@@ -16,23 +53,55 @@ type User struct {
 	Email    string
 }
 
+// UserUpdate holds the user fields to change; nil fields are left as they are
+type UserUpdate struct {
+	Username *string
+	Email    *string
+}
+
 // Purchase represents a purchase record in the database
 type Purchase struct {
 	ID       int64
 	UserID   int64
 	AlbumID  int64
 	Quantity int
+	// CancelledQuantity is how many of the units were cancelled and returned to stock
+	CancelledQuantity int
+	CreatedAt         time.Time // UTC
+}
+
+// PurchaseFilter narrows purchase queries to a creation time range. A zero
+// time leaves that end of the range open.
+type PurchaseFilter struct {
+	From time.Time // inclusive
+	To   time.Time // exclusive
+}
+
+// Order represents several purchases placed together by one user. Each line
+// is stored as a purchase, so line IDs are purchase IDs.
+type Order struct {
+	ID     int64       `json:"id"`
+	UserID int64       `json:"user_id"`
+	Lines  []OrderLine `json:"lines"`
+}
+
+// OrderLine is one album and quantity of an order
+type OrderLine struct {
+	ID       int64 `json:"id"`
+	AlbumID  int64 `json:"album_id"`
+	Quantity int   `json:"quantity"`
 }
 
 // PurchaseDetail represents purchase information with album details
 type PurchaseDetail struct {
-	ID         int64   `json:"id"`
-	AlbumID    int64   `json:"album_id"`
-	AlbumTitle string  `json:"album_title"`
-	Artist     string  `json:"artist"`
-	Price      float32 `json:"price"`
-	Quantity   int     `json:"quantity"`
-	Subtotal   float32 `json:"subtotal"`
+	ID         int64  `json:"id"`
+	AlbumID    int64  `json:"album_id"`
+	AlbumTitle string `json:"album_title"`
+	Artist     string `json:"artist"`
+	Price      Money  `json:"price"` // unit price when the purchase was made
+	Currency   string `json:"currency"`
+	Quantity   int    `json:"quantity"`
+	Subtotal   Money  `json:"subtotal"`
 }
 
 // UserPurchaseSummary represents a user with their purchase history and total cost
@@ -41,18 +110,68 @@ type UserPurchaseSummary struct {
 	Username  string           `json:"username"`
 	Email     string           `json:"email"`
 	Purchases []PurchaseDetail `json:"purchases"`
-	TotalCost float32          `json:"total_cost"`
+	TotalCost Money            `json:"total_cost"`
+}
+
+// PageRequest selects one page of a list in ID order: up to Limit records
+// whose ID is greater than After
+type PageRequest struct {
+	After int64
+	Limit int
+}
+
+// Page is one page of a list action's results. NextCursor is set when HasMore
+// is, and is sent back as the cursor to fetch the following page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// AlbumEvent is pushed to the connections subscribed to an album or to the
+// whole catalog after a change to the album is committed
+type AlbumEvent struct {
+	Event   string `json:"event"`
+	AlbumID int64  `json:"album_id"`
+	Stock   *int   `json:"stock,omitempty"` // set on stockChanged
+	Album   *Album `json:"album,omitempty"` // set on albumAdded and albumUpdated
 }
 
-// WSMessage represents a WebSocket message from the client
+// Subscription is what one connection receives events for
+type Subscription struct {
+	AlbumIDs []int64 `json:"album_ids"`
+	Catalog  bool    `json:"catalog"` // every album, including ones added later
+}
+
+// WSMessage represents a WebSocket message from the client. ID is optional;
+// any JSON string or number the client sends is echoed on the response.
 type WSMessage struct {
-	Action string      `json:"action"`
-	Data   interface{} `json:"data"`
+	ID     json.RawMessage `json:"id,omitempty"`
+	Action string          `json:"action"`
+	Data   interface{}     `json:"data"`
+}
+
+// WSBatch is a batch envelope. With Atomic set, every message runs in one
+// database transaction that is committed only if all of them succeed.
+type WSBatch struct {
+	ID       json.RawMessage `json:"id,omitempty"`
+	Atomic   bool            `json:"atomic"`
+	Messages []WSMessage     `json:"messages"`
+}
+
+// WSError describes why an action failed. Code is stable and meant for
+// programs; Message is for people and may change.
+type WSError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Fields  []string               `json:"fields,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
 }
 
 // WSResponse represents a WebSocket response to the client
 type WSResponse struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data"`
-	Error   string      `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Success bool            `json:"success"`
+	Data    interface{}     `json:"data"`
+	Error   *WSError        `json:"error,omitempty"`
 }
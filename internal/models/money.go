@@ -0,0 +1,125 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in minor currency units (cents), so prices add up and
+// multiply exactly. It is stored as DECIMAL(10, 2) in MySQL and as INTEGER
+// cents in SQLite, and encoded in JSON as a decimal number with two places,
+// e.g. 29.99.
+type Money int64
+
+// minorUnits is the number of minor units in one major unit
+const minorUnits = 100
+
+// MaxPrice is the largest price a DECIMAL(10, 2) column holds
+const MaxPrice Money = 99999999_99
+
+// MoneyFromFloat converts a decoded JSON number to Money. ok is false when f
+// has more than two decimal places or is out of range.
+func MoneyFromFloat(f float64) (m Money, ok bool) {
+	cents := math.Round(f * minorUnits)
+	if math.Abs(cents-f*minorUnits) > 1e-6 || math.Abs(cents) > math.MaxInt64/2 {
+		return 0, false
+	}
+	return Money(cents), true
+}
+
+// ParseMoney parses a decimal string such as "29.99", "-5" or "12.50".
+// Digits past the second decimal place must be zero.
+func ParseMoney(s string) (Money, error) {
+	text := strings.TrimSpace(s)
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(text, "-")
+
+	whole, frac, _ := strings.Cut(text, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("parse money %q: empty amount", s)
+	}
+	if strings.TrimRight(frac[min(len(frac), 2):], "0") != "" {
+		return 0, fmt.Errorf("parse money %q: more than 2 decimal places", s)
+	}
+	frac = (frac + "00")[:2]
+
+	units, err := strconv.ParseInt("0"+whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse money %q: %w", s, err)
+	}
+	cents, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse money %q: %w", s, err)
+	}
+
+	m := Money(units*minorUnits + cents)
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+// Times returns the amount for quantity units
+func (m Money) Times(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// String formats m with two decimal places
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/minorUnits, cents%minorUnits)
+}
+
+// MarshalJSON encodes m as a JSON number with two decimal places
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON decodes a JSON number without going through float64
+func (m *Money) UnmarshalJSON(data []byte) error {
+	parsed, err := ParseMoney(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan reads a DECIMAL column, which MySQL returns as text, or SQLite
+// INTEGER cents
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return m.scanText(string(v))
+	case string:
+		return m.scanText(v)
+	case int64:
+		*m = Money(v)
+	default:
+		return fmt.Errorf("scan money: unsupported type %T", src)
+	}
+	return nil
+}
+
+func (m *Money) scanText(s string) error {
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value binds m as a decimal string for a MySQL DECIMAL column. SQLite
+// stores cents and binds int64(m) instead.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
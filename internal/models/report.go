@@ -0,0 +1,37 @@
+package models
+
+// Sales reports. Units and revenue count only units that were not cancelled,
+// priced at the unit price they were bought at.
+
+// AlbumSales is one album's line of the top-selling albums report
+type AlbumSales struct {
+	AlbumID int64  `json:"album_id"`
+	Title   string `json:"title"`
+	Artist  string `json:"artist"`
+	Units   int    `json:"units"`
+	Revenue Money  `json:"revenue"`
+}
+
+// ArtistRevenue is one artist's line of the revenue by artist report
+type ArtistRevenue struct {
+	Artist  string `json:"artist"`
+	Units   int    `json:"units"`
+	Revenue Money  `json:"revenue"`
+}
+
+// PeriodSales is the sales of one day, week or month. Period is the first
+// day of it as YYYY-MM-DD; weeks start on Monday.
+type PeriodSales struct {
+	Period  string `json:"period"`
+	Units   int    `json:"units"`
+	Revenue Money  `json:"revenue"`
+}
+
+// OrderValueReport is the average value of an order. Every placeOrder order
+// counts once, and so does every purchase made on its own with addPurchase.
+type OrderValueReport struct {
+	Orders            int   `json:"orders"`
+	Units             int   `json:"units"`
+	Revenue           Money `json:"revenue"`
+	AverageOrderValue Money `json:"average_order_value"`
+}
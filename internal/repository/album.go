@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"example/data-access/internal/constants"
 	"example/data-access/internal/logger"
@@ -13,104 +14,353 @@ import (
 // Album database operations
 
 // GetAllAlbums calls stored procedure to get all albums in the database
-func GetAllAlbums(db *sql.DB) ([]models.Album, error) {
-	var albums []models.Album
+func (s *MySQLStore) GetAllAlbums(ctx context.Context) ([]models.Album, error) {
+	log := logger.FromContext(ctx)
 
-	ctx, cancel := context.WithTimeout(context.Background(), constants.DBTimeout)
-	defer cancel()
+	var albums []models.Album
 
-	rows, err := db.QueryContext(ctx, "CALL sp_get_all_albums()")
+	rows, err := s.q.QueryContext(ctx, "CALL sp_get_all_albums()")
 	if err != nil {
-		logger.Log.Errorw("Failed to call stored procedure sp_get_all_albums", "error", err)
-		return nil, fmt.Errorf("getAllAlbums: %v", err)
+		log.Errorw("Failed to call stored procedure sp_get_all_albums", "error", err)
+		return nil, fmt.Errorf("getAllAlbums: %w", classifyMySQLError(err))
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var alb models.Album
-		var price float64
-		if err := rows.Scan(&alb.ID, &alb.Title, &alb.Artist, &price, &alb.Stock); err != nil {
-			logger.Log.Errorw("Failed to scan album", "error", err)
-			return nil, fmt.Errorf("getAllAlbums: %v", err)
+		if err := rows.Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Stock); err != nil {
+			log.Errorw("Failed to scan album", "error", err)
+			return nil, fmt.Errorf("getAllAlbums: %w", classifyMySQLError(err))
 		}
-		alb.Price = float32(price)
 		albums = append(albums, alb)
 	}
 
 	if err := rows.Err(); err != nil {
-		logger.Log.Errorw("Error iterating albums", "error", err)
-		return nil, fmt.Errorf("getAllAlbums: %v", err)
+		log.Errorw("Error iterating albums", "error", err)
+		return nil, fmt.Errorf("getAllAlbums: %w", classifyMySQLError(err))
 	}
 
 	return albums, nil
 }
 
-// GetAlbumsByArtist calls stored procedure to get albums that have the specified artist name
-func GetAlbumsByArtist(db *sql.DB, name string) ([]models.Album, error) {
+// ListAlbums calls stored procedure to get one page of albums after the given ID
+func (s *MySQLStore) ListAlbums(ctx context.Context, page models.PageRequest) ([]models.Album, bool, error) {
+	log := logger.FromContext(ctx)
+
 	var albums []models.Album
 
-	ctx, cancel := context.WithTimeout(context.Background(), constants.DBTimeout)
-	defer cancel()
+	rows, err := s.q.QueryContext(ctx, "CALL sp_list_albums(?, ?)", page.After, fetchLimit(page))
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_list_albums", "after", page.After, "limit", page.Limit, "error", err)
+		return nil, false, fmt.Errorf("listAlbums after %d: %w", page.After, classifyMySQLError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alb models.Album
+		if err := rows.Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Stock); err != nil {
+			log.Errorw("Failed to scan album", "error", err)
+			return nil, false, fmt.Errorf("listAlbums after %d: %w", page.After, classifyMySQLError(err))
+		}
+		albums = append(albums, alb)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating albums", "error", err)
+		return nil, false, fmt.Errorf("listAlbums after %d: %w", page.After, classifyMySQLError(err))
+	}
+
+	albums, hasMore := trimPage(albums, page)
+	return albums, hasMore, nil
+}
+
+// GetAlbumsByArtist calls stored procedure to get albums that have the specified artist name
+func (s *MySQLStore) GetAlbumsByArtist(ctx context.Context, name string) ([]models.Album, error) {
+	log := logger.FromContext(ctx)
+
+	var albums []models.Album
 
-	rows, err := db.QueryContext(ctx, "CALL sp_get_albums_by_artist(?)", name)
+	rows, err := s.q.QueryContext(ctx, "CALL sp_get_albums_by_artist(?)", name)
 	if err != nil {
-		logger.Log.Errorw("Failed to call stored procedure sp_get_albums_by_artist", "artist", name, "error", err)
-		return nil, fmt.Errorf("getAlbumsByArtist %q: %v", name, err)
+		log.Errorw("Failed to call stored procedure sp_get_albums_by_artist", "artist", name, "error", err)
+		return nil, fmt.Errorf("getAlbumsByArtist %q: %w", name, classifyMySQLError(err))
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var alb models.Album
-		var price float64
-		if err := rows.Scan(&alb.ID, &alb.Title, &alb.Artist, &price, &alb.Stock); err != nil {
-			logger.Log.Errorw("Failed to scan album", "artist", name, "error", err)
-			return nil, fmt.Errorf("getAlbumsByArtist %q: %v", name, err)
+		if err := rows.Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Stock); err != nil {
+			log.Errorw("Failed to scan album", "artist", name, "error", err)
+			return nil, fmt.Errorf("getAlbumsByArtist %q: %w", name, classifyMySQLError(err))
 		}
-		alb.Price = float32(price)
 		albums = append(albums, alb)
 	}
 
 	if err := rows.Err(); err != nil {
-		logger.Log.Errorw("Error iterating albums by artist", "artist", name, "error", err)
-		return nil, fmt.Errorf("getAlbumsByArtist %q: %v", name, err)
+		log.Errorw("Error iterating albums by artist", "artist", name, "error", err)
+		return nil, fmt.Errorf("getAlbumsByArtist %q: %w", name, classifyMySQLError(err))
 	}
 
 	return albums, nil
 }
 
+// ListAlbumsByArtist calls stored procedure to get one page of the albums that
+// have the specified artist name, after the given ID
+func (s *MySQLStore) ListAlbumsByArtist(ctx context.Context, name string, page models.PageRequest) ([]models.Album, bool, error) {
+	log := logger.FromContext(ctx)
+
+	var albums []models.Album
+
+	rows, err := s.q.QueryContext(ctx, "CALL sp_list_albums_by_artist(?, ?, ?)", name, page.After, fetchLimit(page))
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_list_albums_by_artist", "artist", name, "after", page.After, "limit", page.Limit, "error", err)
+		return nil, false, fmt.Errorf("listAlbumsByArtist %q after %d: %w", name, page.After, classifyMySQLError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alb models.Album
+		if err := rows.Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Stock); err != nil {
+			log.Errorw("Failed to scan album", "artist", name, "error", err)
+			return nil, false, fmt.Errorf("listAlbumsByArtist %q after %d: %w", name, page.After, classifyMySQLError(err))
+		}
+		albums = append(albums, alb)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating albums by artist", "artist", name, "error", err)
+		return nil, false, fmt.Errorf("listAlbumsByArtist %q after %d: %w", name, page.After, classifyMySQLError(err))
+	}
+
+	albums, hasMore := trimPage(albums, page)
+	return albums, hasMore, nil
+}
+
+// SearchAlbums gets one page of albums matching search. Sorting is chosen per
+// request, so unlike the other album queries this runs the SQL built by
+// albumSearchQuery instead of a stored procedure.
+func (s *MySQLStore) SearchAlbums(ctx context.Context, search models.AlbumSearch) ([]models.Album, bool, error) {
+	log := logger.FromContext(ctx)
+
+	query, args, err := albumSearchQuery(search, mysqlMoney)
+	if err != nil {
+		return nil, false, fmt.Errorf("searchAlbums: %w", err)
+	}
+
+	var albums []models.Album
+
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Errorw("Failed to search albums", "sort", search.SortBy, "error", err)
+		return nil, false, fmt.Errorf("searchAlbums: %w", classifyMySQLError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alb models.Album
+		if err := rows.Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Stock); err != nil {
+			log.Errorw("Failed to scan album", "error", err)
+			return nil, false, fmt.Errorf("searchAlbums: %w", classifyMySQLError(err))
+		}
+		albums = append(albums, alb)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating album search results", "error", err)
+		return nil, false, fmt.Errorf("searchAlbums: %w", classifyMySQLError(err))
+	}
+
+	albums, hasMore := trimPage(albums, models.PageRequest{Limit: search.Limit})
+	return albums, hasMore, nil
+}
+
+// albumSortColumns are the columns SearchAlbums can sort by. Only these
+// names are written into the query; every filter value is a bound parameter.
+var albumSortColumns = map[string]string{
+	constants.SortByID:     "id",
+	constants.SortByTitle:  "title",
+	constants.SortByArtist: "artist",
+	constants.SortByPrice:  "price",
+	constants.SortByStock:  "stock",
+}
+
+// likeEscaper escapes LIKE wildcards so substring filters match them literally
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// albumSearchQuery builds the SELECT for search, valid in both MySQL and
+// SQLite. Pages are keyed on the sort column with the album ID as tie-breaker,
+// and one extra row is fetched to tell whether another page follows.
+// bindMoney turns a price into the argument the backend's price column
+// compares with.
+func albumSearchQuery(search models.AlbumSearch, bindMoney func(models.Money) interface{}) (string, []interface{}, error) {
+	sortBy := search.SortBy
+	if sortBy == "" {
+		sortBy = constants.SortByID
+	}
+	column, ok := albumSortColumns[sortBy]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown sort field %q", search.SortBy)
+	}
+
+	where := []string{"deleted_at IS NULL"}
+	var args []interface{}
+
+	if search.Title != "" {
+		where = append(where, "LOWER(title) LIKE ? ESCAPE '!'")
+		args = append(args, "%"+likeEscaper.Replace(strings.ToLower(search.Title))+"%")
+	}
+	if search.Artist != "" {
+		where = append(where, "LOWER(artist) LIKE ? ESCAPE '!'")
+		args = append(args, "%"+likeEscaper.Replace(strings.ToLower(search.Artist))+"%")
+	}
+	if search.MinPrice != nil {
+		where = append(where, "price >= ?")
+		args = append(args, bindMoney(*search.MinPrice))
+	}
+	if search.MaxPrice != nil {
+		where = append(where, "price <= ?")
+		args = append(args, bindMoney(*search.MaxPrice))
+	}
+	if search.InStockOnly {
+		where = append(where, "stock > 0")
+	}
+
+	direction, after := "ASC", ">"
+	if search.Descending {
+		direction, after = "DESC", "<"
+	}
+
+	if search.After != nil {
+		if column == "id" {
+			where = append(where, "id "+after+" ?")
+			args = append(args, search.After.ID)
+		} else {
+			key := albumSortKey(*search.After, sortBy)
+			if price, ok := key.(models.Money); ok {
+				key = bindMoney(price)
+			}
+			where = append(where, "("+column+" "+after+" ? OR ("+column+" = ? AND id > ?))")
+			args = append(args, key, key, search.After.ID)
+		}
+	}
+
+	order := "id " + direction
+	if column != "id" {
+		order = column + " " + direction + ", id ASC"
+	}
+
+	query := "SELECT id, title, artist, price, stock FROM album WHERE " + strings.Join(where, " AND ") +
+		" ORDER BY " + order + " LIMIT ?"
+	args = append(args, search.Limit+1)
+	return query, args, nil
+}
+
+// mysqlMoney binds a price as the decimal MySQL stores
+func mysqlMoney(m models.Money) interface{} {
+	return m
+}
+
+// albumSortKey returns the value of alb's sort field
+func albumSortKey(alb models.Album, sortBy string) interface{} {
+	switch sortBy {
+	case constants.SortByTitle:
+		return alb.Title
+	case constants.SortByArtist:
+		return alb.Artist
+	case constants.SortByPrice:
+		return alb.Price
+	case constants.SortByStock:
+		return alb.Stock
+	default:
+		return alb.ID
+	}
+}
+
 // GetAlbumByID calls stored procedure to get the album with the specified ID
-func GetAlbumByID(db *sql.DB, id int64) (models.Album, error) {
-	var alb models.Album
+func (s *MySQLStore) GetAlbumByID(ctx context.Context, id int64) (models.Album, error) {
+	log := logger.FromContext(ctx)
 
-	ctx, cancel := context.WithTimeout(context.Background(), constants.DBTimeout)
-	defer cancel()
+	var alb models.Album
 
-	row := db.QueryRowContext(ctx, "CALL sp_get_album_by_id(?)", id)
-	var price float64
-	if err := row.Scan(&alb.ID, &alb.Title, &alb.Artist, &price, &alb.Stock); err != nil {
-		logger.Log.Errorw("Album not found", "album_id", id, "error", err)
-		return alb, fmt.Errorf("getAlbumByID %d: %v", id, err)
+	row := s.q.QueryRowContext(ctx, "CALL sp_get_album_by_id(?)", id)
+	if err := row.Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Stock); err != nil {
+		log.Errorw("Album not found", "album_id", id, "error", err)
+		return alb, fmt.Errorf("getAlbumByID %d: %w", id, classifyMySQLError(err))
 	}
-	alb.Price = float32(price)
 
 	return alb, nil
 }
 
 // AddAlbum calls stored procedure to add an album to the database,
 // returning the album ID of the new entry
-func AddAlbum(db *sql.DB, alb models.Album) (int64, error) {
-	logger.Log.Infow("Adding new album", "title", alb.Title, "artist", alb.Artist, "price", alb.Price, "stock", alb.Stock)
+func (s *MySQLStore) AddAlbum(ctx context.Context, alb models.Album) (int64, error) {
+	log := logger.FromContext(ctx)
 
-	ctx, cancel := context.WithTimeout(context.Background(), constants.DBTimeout)
-	defer cancel()
+	log.Infow("Adding new album", "title", alb.Title, "artist", alb.Artist, "price", alb.Price, "stock", alb.Stock)
 
 	var albumID int64
-	err := db.QueryRowContext(ctx, "CALL sp_add_album(?, ?, ?, ?)", alb.Title, alb.Artist, alb.Price, alb.Stock).Scan(&albumID)
+	err := s.q.QueryRowContext(ctx, "CALL sp_add_album(?, ?, ?, ?)", alb.Title, alb.Artist, alb.Price, alb.Stock).Scan(&albumID)
 	if err != nil {
-		logger.Log.Errorw("Failed to call stored procedure sp_add_album", "error", err, "title", alb.Title)
-		return 0, fmt.Errorf("addAlbum: %v", err)
+		log.Errorw("Failed to call stored procedure sp_add_album", "error", err, "title", alb.Title)
+		return 0, fmt.Errorf("addAlbum: %w", classifyMySQLError(err))
 	}
 
-	logger.Log.Infow("Album created", "album_id", albumID, "title", alb.Title, "artist", alb.Artist)
+	log.Infow("Album created", "album_id", albumID, "title", alb.Title, "artist", alb.Artist)
 	return albumID, nil
 }
+
+// UpdateAlbum calls stored procedure to change the given fields of an album,
+// returning the updated album
+func (s *MySQLStore) UpdateAlbum(ctx context.Context, id int64, update models.AlbumUpdate) (models.Album, error) {
+	log := logger.FromContext(ctx)
+
+	var alb models.Album
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, "CALL sp_update_album(?, ?, ?, ?, ?)", id, update.Title, update.Artist, update.Price, update.Stock).
+			Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Stock)
+	})
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_update_album", "album_id", id, "error", err)
+		return alb, fmt.Errorf("updateAlbum %d: %w", id, classifyMySQLError(err))
+	}
+
+	log.Infow("Album updated", "album_id", id)
+	return alb, nil
+}
+
+// RestockAlbum calls stored procedure to add quantity to an album's stock and
+// record the reason, returning the updated album
+func (s *MySQLStore) RestockAlbum(ctx context.Context, id int64, quantity int, reason string) (models.Album, error) {
+	log := logger.FromContext(ctx)
+
+	var alb models.Album
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, "CALL sp_restock_album(?, ?, ?)", id, quantity, reason).
+			Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Stock)
+	})
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_restock_album", "album_id", id, "error", err)
+		return alb, fmt.Errorf("restockAlbum %d: %w", id, classifyMySQLError(err))
+	}
+
+	log.Infow("Album restocked", "album_id", id, "quantity", quantity, "reason", reason, "stock", alb.Stock)
+	return alb, nil
+}
+
+// DeleteAlbum calls stored procedure to delete an album, soft-deleting it
+// instead when it has purchases and softIfPurchased is set
+func (s *MySQLStore) DeleteAlbum(ctx context.Context, id int64, softIfPurchased bool) (bool, error) {
+	log := logger.FromContext(ctx)
+
+	var soft bool
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, "CALL sp_delete_album(?, ?)", id, softIfPurchased).Scan(&soft)
+	})
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_delete_album", "album_id", id, "error", err)
+		return false, fmt.Errorf("deleteAlbum %d: %w", id, classifyMySQLError(err))
+	}
+
+	log.Infow("Album deleted", "album_id", id, "soft", soft)
+	return soft, nil
+}
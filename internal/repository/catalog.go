@@ -0,0 +1,357 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"example/data-access/internal/logger"
+	"example/data-access/internal/models"
+)
+
+// Catalog search operations
+
+// maxCatalogTerms caps how many words of a query are searched for
+const maxCatalogTerms = 10
+
+// maxCatalogCandidates caps how many albums the MySQL typo and short word
+// searches read before keeping those that match a query word
+const maxCatalogCandidates = 100
+
+// minFullTextTermLength is InnoDB's default innodb_ft_min_token_size: shorter
+// words are not in the FULLTEXT index
+const minFullTextTermLength = 3
+
+// fullTextStopwords are InnoDB's default stopwords, which the FULLTEXT index
+// leaves out too
+var fullTextStopwords = map[string]bool{
+	"a": true, "about": true, "an": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"com": true, "de": true, "en": true, "for": true, "from": true, "how": true, "i": true, "in": true,
+	"is": true, "it": true, "la": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "what": true, "when": true, "where": true, "who": true,
+	"will": true, "with": true, "und": true, "www": true,
+}
+
+// SearchCatalog calls stored procedure to run a FULLTEXT search over album
+// titles and artists, then highlights the matching words. Words the index
+// leaves out, being short or stopwords, are looked up without it. If that
+// finds fewer than limit albums, the rest are filled up with albums matching
+// a word with a typo.
+func (s *MySQLStore) SearchCatalog(ctx context.Context, query string, limit int) ([]models.CatalogMatch, error) {
+	log := logger.FromContext(ctx)
+
+	terms := catalogTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	var matches []models.CatalogMatch
+	indexed, unindexed := splitFullTextTerms(terms)
+	if len(indexed) > 0 {
+		// Boolean mode with a trailing * on every word: any word may match, and
+		// albums matching more of them score higher
+		against := strings.Join(indexed, "* ") + "*"
+
+		rows, err := s.q.QueryContext(ctx, "CALL sp_search_catalog(?, ?)", against, limit)
+		if err != nil {
+			log.Errorw("Failed to call stored procedure sp_search_catalog", "query", query, "error", err)
+			return nil, fmt.Errorf("searchCatalog %q: %w", query, classifyMySQLError(err))
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var m models.CatalogMatch
+			if err := rows.Scan(&m.Album.ID, &m.Album.Title, &m.Album.Artist, &m.Album.Price, &m.Album.Stock, &m.Score); err != nil {
+				log.Errorw("Failed to scan catalog match", "query", query, "error", err)
+				return nil, fmt.Errorf("searchCatalog %q: %w", query, classifyMySQLError(err))
+			}
+			matches = append(matches, highlightMatch(m, terms))
+		}
+
+		if err := rows.Err(); err != nil {
+			log.Errorw("Error iterating catalog matches", "query", query, "error", err)
+			return nil, fmt.Errorf("searchCatalog %q: %w", query, classifyMySQLError(err))
+		}
+		rows.Close()
+	}
+
+	if len(matches) < limit && len(unindexed) > 0 {
+		words, err := s.searchCatalogCandidates(ctx, query, "sp_search_catalog_words", wordsPattern(unindexed), terms)
+		if err != nil {
+			return nil, err
+		}
+		matches = appendFallbackMatches(matches, words, limit, terms)
+	}
+
+	if len(matches) == limit || len(typoTerms(terms)) == 0 {
+		return matches, nil
+	}
+	typos, err := s.searchCatalogCandidates(ctx, query, "sp_search_catalog_typos", strings.Join(typoTerms(terms), " "), terms)
+	if err != nil {
+		return nil, err
+	}
+	return appendFallbackMatches(matches, typos, limit, terms), nil
+}
+
+// splitFullTextTerms splits terms into those the FULLTEXT index has and those
+// it leaves out
+func splitFullTextTerms(terms []string) (indexed, unindexed []string) {
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < minFullTextTermLength || fullTextStopwords[term] {
+			unindexed = append(unindexed, term)
+		} else {
+			indexed = append(indexed, term)
+		}
+	}
+	return indexed, unindexed
+}
+
+// wordsPattern returns a regular expression matching a word that starts with
+// one of terms. Terms are letters and digits only, so they need no escaping.
+func wordsPattern(terms []string) string {
+	return `\b(` + strings.Join(terms, "|") + ")"
+}
+
+// searchCatalogCandidates calls procedure, sp_search_catalog_typos or
+// sp_search_catalog_words, to find candidates for the query words, and keeps
+// the albums with a word that termMatch accepts
+func (s *MySQLStore) searchCatalogCandidates(ctx context.Context, query, procedure, words string, terms []string) ([]models.CatalogMatch, error) {
+	log := logger.FromContext(ctx)
+
+	rows, err := s.q.QueryContext(ctx, "CALL "+procedure+"(?, ?)", words, maxCatalogCandidates)
+	if err != nil {
+		log.Errorw("Failed to call stored procedure "+procedure, "query", query, "error", err)
+		return nil, fmt.Errorf("searchCatalog %q: %w", query, classifyMySQLError(err))
+	}
+	defer rows.Close()
+
+	var matches []models.CatalogMatch
+	for rows.Next() {
+		var m models.CatalogMatch
+		if err := rows.Scan(&m.Album.ID, &m.Album.Title, &m.Album.Artist, &m.Album.Price, &m.Album.Stock); err != nil {
+			log.Errorw("Failed to scan catalog candidate", "query", query, "procedure", procedure, "error", err)
+			return nil, fmt.Errorf("searchCatalog %q: %w", query, classifyMySQLError(err))
+		}
+		m.Score = catalogScore(m.Album.Title, m.Album.Artist, terms)
+		if m.Score > 0 {
+			matches = append(matches, m)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating catalog candidates", "query", query, "procedure", procedure, "error", err)
+		return nil, fmt.Errorf("searchCatalog %q: %w", query, classifyMySQLError(err))
+	}
+
+	return matches, nil
+}
+
+// appendFallbackMatches adds the albums of fallback not already in matches,
+// best first, until there are limit matches, and highlights them. Their
+// scores are scaled below the last score of matches, so they always rank
+// after the matches found before them.
+func appendFallbackMatches(matches, fallback []models.CatalogMatch, limit int, terms []string) []models.CatalogMatch {
+	found := make(map[int64]bool, len(matches))
+	for _, m := range matches {
+		found[m.Album.ID] = true
+	}
+
+	sort.SliceStable(fallback, func(i, j int) bool {
+		if fallback[i].Score != fallback[j].Score {
+			return fallback[i].Score > fallback[j].Score
+		}
+		return fallback[i].Album.ID < fallback[j].Album.ID
+	})
+
+	floor := 0.0
+	if len(matches) > 0 {
+		floor = matches[len(matches)-1].Score
+	}
+	for _, m := range fallback {
+		if len(matches) == limit {
+			break
+		}
+		if found[m.Album.ID] {
+			continue
+		}
+		if floor > 0 {
+			m.Score = floor * m.Score / (m.Score + 1)
+		}
+		matches = append(matches, highlightMatch(m, terms))
+	}
+	return matches
+}
+
+// catalogTerms splits query into distinct lower-case words of letters and
+// digits. Everything else is dropped, so the terms are safe to put into a
+// FULLTEXT or FTS5 query string.
+func catalogTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), notWordRune) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+		if len(terms) == maxCatalogTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// notWordRune reports whether r separates words
+func notWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// Weights of a query term matching a word: a whole word beats a prefix, which
+// beats a word spelled with a typo
+const (
+	wordMatch   = 1.0
+	prefixMatch = 0.5
+	typoMatch   = 0.25
+)
+
+// minTypoTermLength is the length, in runes, from which a query word also
+// matches words one edit away; from twice that, two edits away
+const minTypoTermLength = 4
+
+// termMatch returns how well term matches word, both in lower case, or 0
+func termMatch(word, term string) float64 {
+	switch {
+	case word == term:
+		return wordMatch
+	case strings.HasPrefix(word, term):
+		return prefixMatch
+	case withinTypos(word, term):
+		return typoMatch
+	}
+	return 0
+}
+
+// withinTypos reports whether word is close enough to term to be a misspelling of it
+func withinTypos(word, term string) bool {
+	n := utf8.RuneCountInString(term)
+	typos := 0
+	switch {
+	case n >= 2*minTypoTermLength:
+		typos = 2
+	case n >= minTypoTermLength:
+		typos = 1
+	default:
+		return false
+	}
+	if diff := utf8.RuneCountInString(word) - n; diff > typos || -diff > typos {
+		return false
+	}
+	return editDistance(word, term) <= typos
+}
+
+// editDistance returns the Levenshtein distance between a and b in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// typoTerms returns the terms long enough to be matched with a typo
+func typoTerms(terms []string) []string {
+	var long []string
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) >= minTypoTermLength {
+			long = append(long, term)
+		}
+	}
+	return long
+}
+
+// catalogScore scores an album against terms the way the full-text indexes
+// roughly do: each term counts once, with its best termMatch, and matches in
+// the title count twice as much as in the artist. 0 means no match.
+func catalogScore(title, artist string, terms []string) float64 {
+	return 2*termScore(title, terms) + termScore(artist, terms)
+}
+
+// termScore adds up the best termMatch of each term against the words of text
+func termScore(text string, terms []string) float64 {
+	words := strings.FieldsFunc(strings.ToLower(text), notWordRune)
+	var score float64
+	for _, term := range terms {
+		best := 0.0
+		for _, word := range words {
+			best = max(best, termMatch(word, term))
+		}
+		score += best
+	}
+	return score
+}
+
+// matchesTerm reports whether word, in lower case, is matched by one of terms
+func matchesTerm(word string, terms []string) bool {
+	for _, term := range terms {
+		if termMatch(word, term) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightMatch fills in the title and artist highlights of m
+func highlightMatch(m models.CatalogMatch, terms []string) models.CatalogMatch {
+	m.TitleHighlight = highlight(m.Album.Title, terms)
+	m.ArtistHighlight = highlight(m.Album.Artist, terms)
+	return m
+}
+
+// highlight HTML-escapes text and wraps every word matched by terms in <mark>
+func highlight(text string, terms []string) string {
+	var b strings.Builder
+	wordStart := -1
+	flush := func(end int) {
+		word := text[wordStart:end]
+		if matchesTerm(strings.ToLower(word), terms) {
+			b.WriteString("<mark>" + html.EscapeString(word) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(word))
+		}
+		wordStart = -1
+	}
+
+	for i, r := range text {
+		if !notWordRune(r) {
+			if wordStart < 0 {
+				wordStart = i
+			}
+			continue
+		}
+		if wordStart >= 0 {
+			flush(i)
+		}
+		b.WriteString(html.EscapeString(string(r)))
+	}
+	if wordStart >= 0 {
+		flush(len(text))
+	}
+	return b.String()
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Domain errors returned by every Store, wrapped with the operation and the
+// underlying driver error. Check them with errors.Is.
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrDuplicate         = errors.New("duplicate record")
+	ErrInvalidReference  = errors.New("invalid reference")
+	ErrInUse             = errors.New("record in use")
+	ErrNotCancellable    = errors.New("not cancellable")
+)
+
+// OrderLineError reports the order line that made PlaceOrder fail. Err wraps
+// the domain error.
+type OrderLineError struct {
+	Line    int // index into Order.Lines
+	AlbumID int64
+	Err     error
+}
+
+func (e *OrderLineError) Error() string {
+	return fmt.Sprintf("line %d (album %d): %v", e.Line, e.AlbumID, e.Err)
+}
+
+func (e *OrderLineError) Unwrap() error {
+	return e.Err
+}
+
+// MySQL server error numbers
+const (
+	mysqlErrDuplicateEntry  = 1062
+	mysqlErrRowIsReferenced = 1451
+	mysqlErrNoReferencedRow = 1452
+	mysqlErrSignalException = 1644
+)
+
+// MESSAGE_TEXT prefixes of the signals that map to a specific domain error:
+// sp_add_purchase signals when the album has too little stock or the user does
+// not exist, sp_cancel_purchase when more units are cancelled than remain.
+// Other signals end in "not found" (ErrNotFound) or "has purchases" (ErrInUse).
+const (
+	mysqlSignalInsufficientStock = "Insufficient stock"
+	mysqlSignalUnknownUser       = "Unknown user"
+	mysqlSignalCancelExceeds     = "Cancel quantity exceeds"
+)
+
+// classifyMySQLError wraps err with the matching domain error, if any
+func classifyMySQLError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return err
+	}
+
+	switch mysqlErr.Number {
+	case mysqlErrDuplicateEntry:
+		return fmt.Errorf("%w: %w", ErrDuplicate, err)
+	case mysqlErrNoReferencedRow:
+		return fmt.Errorf("%w: %w", ErrInvalidReference, err)
+	case mysqlErrRowIsReferenced:
+		return fmt.Errorf("%w: %w", ErrInUse, err)
+	case mysqlErrSignalException:
+		if string(mysqlErr.SQLState[:]) != "45000" {
+			return err
+		}
+		if strings.HasPrefix(mysqlErr.Message, mysqlSignalInsufficientStock) {
+			return fmt.Errorf("%w: %w", ErrInsufficientStock, err)
+		}
+		if strings.HasPrefix(mysqlErr.Message, mysqlSignalUnknownUser) {
+			return fmt.Errorf("%w: %w", ErrInvalidReference, err)
+		}
+		if strings.HasPrefix(mysqlErr.Message, mysqlSignalCancelExceeds) {
+			return fmt.Errorf("%w: %w", ErrNotCancellable, err)
+		}
+		if strings.HasSuffix(mysqlErr.Message, "not found") {
+			return fmt.Errorf("%w: %w", ErrNotFound, err)
+		}
+		if strings.HasSuffix(mysqlErr.Message, "has purchases") {
+			return fmt.Errorf("%w: %w", ErrInUse, err)
+		}
+	}
+	return err
+}
+
+// classifySQLiteError wraps err with the matching domain error, if any
+func classifySQLiteError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return err
+	}
+
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return fmt.Errorf("%w: %w", ErrDuplicate, err)
+	case sqlite3.ErrConstraintForeignKey:
+		return fmt.Errorf("%w: %w", ErrInvalidReference, err)
+	}
+	return err
+}
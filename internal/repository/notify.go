@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+
+	"example/data-access/internal/constants"
+	"example/data-access/internal/models"
+)
+
+// Publisher receives album events once the change behind them is committed
+type Publisher interface {
+	Publish(event models.AlbumEvent)
+}
+
+// NotifyingStore wraps a Store and publishes an event for every committed
+// album addition, update and deletion and every stock change. The stock is
+// the one the change's own transaction left. Inside WithTx the events are
+// held back until the transaction commits, and dropped if it rolls back.
+type NotifyingStore struct {
+	Store
+	pub     Publisher
+	pending *[]models.AlbumEvent // set while bound to a transaction
+}
+
+// NewNotifyingStore returns a Store that publishes the changes made through s to pub
+func NewNotifyingStore(s Store, pub Publisher) *NotifyingStore {
+	return &NotifyingStore{Store: s, pub: pub}
+}
+
+// WithTx runs fn with a NotifyingStore bound to one transaction and publishes
+// its events after the commit. A nested call joins the outer transaction and
+// leaves publishing to it.
+func (s *NotifyingStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if s.pending != nil {
+		return s.Store.WithTx(ctx, func(tx Store) error {
+			return fn(&NotifyingStore{Store: tx, pub: s.pub, pending: s.pending})
+		})
+	}
+
+	var pending []models.AlbumEvent
+	err := s.Store.WithTx(ctx, func(tx Store) error {
+		return fn(&NotifyingStore{Store: tx, pub: s.pub, pending: &pending})
+	})
+	if err != nil {
+		return err
+	}
+	for _, event := range pending {
+		s.pub.Publish(event)
+	}
+	return nil
+}
+
+// AddAlbum adds an album and publishes albumAdded
+func (s *NotifyingStore) AddAlbum(ctx context.Context, alb models.Album) (int64, error) {
+	id, err := s.Store.AddAlbum(ctx, alb)
+	if err != nil {
+		return id, err
+	}
+	alb.ID = id
+	s.emit(models.AlbumEvent{Event: constants.EventAlbumAdded, AlbumID: id, Album: &alb})
+	return id, nil
+}
+
+// UpdateAlbum updates an album and publishes albumUpdated, and stockChanged
+// if the update set the stock
+func (s *NotifyingStore) UpdateAlbum(ctx context.Context, id int64, update models.AlbumUpdate) (models.Album, error) {
+	alb, err := s.Store.UpdateAlbum(ctx, id, update)
+	if err != nil {
+		return alb, err
+	}
+	s.emit(models.AlbumEvent{Event: constants.EventAlbumUpdated, AlbumID: id, Album: &alb})
+	if update.Stock != nil {
+		s.emitStock(alb)
+	}
+	return alb, nil
+}
+
+// RestockAlbum restocks an album and publishes stockChanged
+func (s *NotifyingStore) RestockAlbum(ctx context.Context, id int64, quantity int, reason string) (models.Album, error) {
+	alb, err := s.Store.RestockAlbum(ctx, id, quantity, reason)
+	if err != nil {
+		return alb, err
+	}
+	s.emitStock(alb)
+	return alb, nil
+}
+
+// DeleteAlbum deletes an album and publishes albumDeleted, whether the album
+// was removed or soft-deleted
+func (s *NotifyingStore) DeleteAlbum(ctx context.Context, id int64, softIfPurchased bool) (bool, error) {
+	soft, err := s.Store.DeleteAlbum(ctx, id, softIfPurchased)
+	if err != nil {
+		return soft, err
+	}
+	s.emit(models.AlbumEvent{Event: constants.EventAlbumDeleted, AlbumID: id})
+	return soft, nil
+}
+
+// AddPurchase adds a purchase and publishes the album's new stock
+func (s *NotifyingStore) AddPurchase(ctx context.Context, p models.Purchase) (int64, int, error) {
+	id, stock, err := s.Store.AddPurchase(ctx, p)
+	if err != nil {
+		return id, stock, err
+	}
+	s.emitStock(models.Album{ID: p.AlbumID, Stock: stock})
+	return id, stock, nil
+}
+
+// CancelPurchase cancels units of a purchase and publishes the album's new stock
+func (s *NotifyingStore) CancelPurchase(ctx context.Context, id int64, quantity int) (models.Purchase, int, error) {
+	p, stock, err := s.Store.CancelPurchase(ctx, id, quantity)
+	if err != nil {
+		return p, stock, err
+	}
+	s.emitStock(models.Album{ID: p.AlbumID, Stock: stock})
+	return p, stock, nil
+}
+
+// PlaceOrder places an order and publishes the new stock of each album in it
+func (s *NotifyingStore) PlaceOrder(ctx context.Context, order models.Order) (models.Order, map[int64]int, error) {
+	placed, stock, err := s.Store.PlaceOrder(ctx, order)
+	if err != nil {
+		return placed, stock, err
+	}
+	seen := make(map[int64]bool, len(placed.Lines))
+	for _, line := range placed.Lines {
+		if !seen[line.AlbumID] {
+			seen[line.AlbumID] = true
+			s.emitStock(models.Album{ID: line.AlbumID, Stock: stock[line.AlbumID]})
+		}
+	}
+	return placed, stock, nil
+}
+
+// emitStock emits stockChanged with the stock of alb
+func (s *NotifyingStore) emitStock(alb models.Album) {
+	stock := alb.Stock
+	s.emit(models.AlbumEvent{Event: constants.EventStockChanged, AlbumID: alb.ID, Stock: &stock})
+}
+
+// emit publishes event now, or after the commit when bound to a transaction
+func (s *NotifyingStore) emit(event models.AlbumEvent) {
+	if s.pending != nil {
+		*s.pending = append(*s.pending, event)
+		return
+	}
+	s.pub.Publish(event)
+}
+
+var _ Store = (*NotifyingStore)(nil)
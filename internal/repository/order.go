@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"example/data-access/internal/logger"
+	"example/data-access/internal/models"
+)
+
+// Order database operations
+
+// PlaceOrder calls stored procedures to create an order and add each line as
+// a purchase, all in one transaction
+func (s *MySQLStore) PlaceOrder(ctx context.Context, order models.Order) (models.Order, map[int64]int, error) {
+	log := logger.FromContext(ctx)
+
+	log.Debugw("Placing order through stored procedures", "user_id", order.UserID, "lines", len(order.Lines))
+
+	placed := models.Order{UserID: order.UserID, Lines: append([]models.OrderLine(nil), order.Lines...)}
+	stock := make(map[int64]int, len(order.Lines))
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx, "CALL sp_create_order(?)", order.UserID).Scan(&placed.ID); err != nil {
+			return classifyMySQLError(err)
+		}
+
+		for _, i := range lockOrder(placed.Lines) {
+			line := &placed.Lines[i]
+			var left int
+			err := tx.QueryRowContext(ctx, "CALL sp_add_order_line(?, ?, ?)", placed.ID, line.AlbumID, line.Quantity).Scan(&line.ID, &left)
+			if err != nil {
+				return &OrderLineError{Line: i, AlbumID: line.AlbumID, Err: classifyMySQLError(err)}
+			}
+			stock[line.AlbumID] = left
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warnw("Failed to place order through stored procedures", "user_id", order.UserID, "error", err)
+		return models.Order{}, nil, fmt.Errorf("placeOrder: %w", err)
+	}
+
+	log.Infow("Order placed", "order_id", placed.ID, "user_id", placed.UserID, "lines", len(placed.Lines))
+	return placed, stock, nil
+}
+
+// lockOrder returns the indexes of lines sorted by album ID. Lines are stored
+// in this order so every transaction locks album rows in the same sequence
+// and concurrent orders cannot deadlock.
+func lockOrder(lines []models.OrderLine) []int {
+	idx := make([]int, len(lines))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		return lines[idx[a]].AlbumID < lines[idx[b]].AlbumID
+	})
+	return idx
+}
@@ -0,0 +1,17 @@
+package repository
+
+import "example/data-access/internal/models"
+
+// fetchLimit is how many rows to select for page: one more than its limit,
+// so the extra row tells whether another page follows
+func fetchLimit(page models.PageRequest) int {
+	return page.Limit + 1
+}
+
+// trimPage drops the extra row fetched by fetchLimit and reports whether there was one
+func trimPage[T any](items []T, page models.PageRequest) ([]T, bool) {
+	if len(items) > page.Limit {
+		return items[:page.Limit], true
+	}
+	return items, false
+}
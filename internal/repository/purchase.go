@@ -4,8 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
-	"example/data-access/internal/constants"
 	"example/data-access/internal/logger"
 	"example/data-access/internal/models"
 )
@@ -13,163 +13,299 @@ import (
 // Purchase database operations
 
 // GetAllPurchases calls stored procedure to get all purchases in the database
-func GetAllPurchases(db *sql.DB) ([]models.Purchase, error) {
-	var purchases []models.Purchase
+func (s *MySQLStore) GetAllPurchases(ctx context.Context) ([]models.Purchase, error) {
+	log := logger.FromContext(ctx)
 
-	ctx, cancel := context.WithTimeout(context.Background(), constants.DBTimeout)
-	defer cancel()
+	var purchases []models.Purchase
 
-	rows, err := db.QueryContext(ctx, "CALL sp_get_all_purchases()")
+	rows, err := s.q.QueryContext(ctx, "CALL sp_get_all_purchases()")
 	if err != nil {
-		logger.Log.Errorw("Failed to call stored procedure sp_get_all_purchases", "error", err)
-		return nil, fmt.Errorf("getAllPurchases: %v", err)
+		log.Errorw("Failed to call stored procedure sp_get_all_purchases", "error", err)
+		return nil, fmt.Errorf("getAllPurchases: %w", classifyMySQLError(err))
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var p models.Purchase
-		if err := rows.Scan(&p.ID, &p.UserID, &p.AlbumID, &p.Quantity); err != nil {
-			logger.Log.Errorw("Failed to scan purchase", "error", err)
-			return nil, fmt.Errorf("getAllPurchases: %v", err)
+		if err := rows.Scan(&p.ID, &p.UserID, &p.AlbumID, &p.Quantity, &p.CancelledQuantity, &p.CreatedAt); err != nil {
+			log.Errorw("Failed to scan purchase", "error", err)
+			return nil, fmt.Errorf("getAllPurchases: %w", classifyMySQLError(err))
 		}
 		purchases = append(purchases, p)
 	}
 
 	if err := rows.Err(); err != nil {
-		logger.Log.Errorw("Error iterating purchases", "error", err)
-		return nil, fmt.Errorf("getAllPurchases: %v", err)
+		log.Errorw("Error iterating purchases", "error", err)
+		return nil, fmt.Errorf("getAllPurchases: %w", classifyMySQLError(err))
 	}
 
 	return purchases, nil
 }
 
-// GetPurchasesByUserID calls stored procedure to get purchases by a specific user
-func GetPurchasesByUserID(db *sql.DB, userID int64) ([]models.Purchase, error) {
+// ListPurchases calls stored procedure to get one page of purchases after the
+// given ID, made within filter
+func (s *MySQLStore) ListPurchases(ctx context.Context, filter models.PurchaseFilter, page models.PageRequest) ([]models.Purchase, bool, error) {
+	log := logger.FromContext(ctx)
+
 	var purchases []models.Purchase
 
-	ctx, cancel := context.WithTimeout(context.Background(), constants.DBTimeout)
-	defer cancel()
+	rows, err := s.q.QueryContext(ctx, "CALL sp_list_purchases(?, ?, ?, ?)", page.After, fetchLimit(page), nullTime(filter.From), nullTime(filter.To))
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_list_purchases", "after", page.After, "limit", page.Limit, "error", err)
+		return nil, false, fmt.Errorf("listPurchases after %d: %w", page.After, classifyMySQLError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Purchase
+		if err := rows.Scan(&p.ID, &p.UserID, &p.AlbumID, &p.Quantity, &p.CancelledQuantity, &p.CreatedAt); err != nil {
+			log.Errorw("Failed to scan purchase", "error", err)
+			return nil, false, fmt.Errorf("listPurchases after %d: %w", page.After, classifyMySQLError(err))
+		}
+		purchases = append(purchases, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating purchases", "error", err)
+		return nil, false, fmt.Errorf("listPurchases after %d: %w", page.After, classifyMySQLError(err))
+	}
+
+	purchases, hasMore := trimPage(purchases, page)
+	return purchases, hasMore, nil
+}
 
-	rows, err := db.QueryContext(ctx, "CALL sp_get_purchases_by_user_id(?)", userID)
+// GetPurchasesByUserID calls stored procedure to get purchases by a specific user made within filter
+func (s *MySQLStore) GetPurchasesByUserID(ctx context.Context, userID int64, filter models.PurchaseFilter) ([]models.Purchase, error) {
+	log := logger.FromContext(ctx)
+
+	var purchases []models.Purchase
+
+	rows, err := s.q.QueryContext(ctx, "CALL sp_get_purchases_by_user_id(?, ?, ?)", userID, nullTime(filter.From), nullTime(filter.To))
 	if err != nil {
-		logger.Log.Errorw("Failed to call stored procedure sp_get_purchases_by_user_id", "user_id", userID, "error", err)
-		return nil, fmt.Errorf("getPurchasesByUserID %d: %v", userID, err)
+		log.Errorw("Failed to call stored procedure sp_get_purchases_by_user_id", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("getPurchasesByUserID %d: %w", userID, classifyMySQLError(err))
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var p models.Purchase
-		if err := rows.Scan(&p.ID, &p.UserID, &p.AlbumID, &p.Quantity); err != nil {
-			logger.Log.Errorw("Failed to scan purchase", "user_id", userID, "error", err)
-			return nil, fmt.Errorf("getPurchasesByUserID %d: %v", userID, err)
+		if err := rows.Scan(&p.ID, &p.UserID, &p.AlbumID, &p.Quantity, &p.CancelledQuantity, &p.CreatedAt); err != nil {
+			log.Errorw("Failed to scan purchase", "user_id", userID, "error", err)
+			return nil, fmt.Errorf("getPurchasesByUserID %d: %w", userID, classifyMySQLError(err))
 		}
 		purchases = append(purchases, p)
 	}
 
 	if err := rows.Err(); err != nil {
-		logger.Log.Errorw("Error iterating purchases by user", "user_id", userID, "error", err)
-		return nil, fmt.Errorf("getPurchasesByUserID %d: %v", userID, err)
+		log.Errorw("Error iterating purchases by user", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("getPurchasesByUserID %d: %w", userID, classifyMySQLError(err))
 	}
 
 	return purchases, nil
 }
 
+// ListPurchasesByUserID calls stored procedure to get one page of a user's
+// purchases after the given ID, made within filter
+func (s *MySQLStore) ListPurchasesByUserID(ctx context.Context, userID int64, filter models.PurchaseFilter, page models.PageRequest) ([]models.Purchase, bool, error) {
+	log := logger.FromContext(ctx)
+
+	var purchases []models.Purchase
+
+	rows, err := s.q.QueryContext(ctx, "CALL sp_list_purchases_by_user_id(?, ?, ?, ?, ?)", userID, page.After, fetchLimit(page), nullTime(filter.From), nullTime(filter.To))
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_list_purchases_by_user_id", "user_id", userID, "after", page.After, "limit", page.Limit, "error", err)
+		return nil, false, fmt.Errorf("listPurchasesByUserID %d after %d: %w", userID, page.After, classifyMySQLError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Purchase
+		if err := rows.Scan(&p.ID, &p.UserID, &p.AlbumID, &p.Quantity, &p.CancelledQuantity, &p.CreatedAt); err != nil {
+			log.Errorw("Failed to scan purchase", "user_id", userID, "error", err)
+			return nil, false, fmt.Errorf("listPurchasesByUserID %d after %d: %w", userID, page.After, classifyMySQLError(err))
+		}
+		purchases = append(purchases, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating purchases by user", "user_id", userID, "error", err)
+		return nil, false, fmt.Errorf("listPurchasesByUserID %d after %d: %w", userID, page.After, classifyMySQLError(err))
+	}
+
+	purchases, hasMore := trimPage(purchases, page)
+	return purchases, hasMore, nil
+}
+
 // AddPurchase calls stored procedure to add a purchase to the database,
-// returning the purchase ID of the new entry
-func AddPurchase(db *sql.DB, p models.Purchase) (int64, error) {
-	logger.Log.Debugw("Starting purchase through stored procedure", "user_id", p.UserID, "album_id", p.AlbumID, "quantity", p.Quantity)
+// returning the purchase ID of the new entry and the album's stock left. The
+// procedure leaves transaction control to the caller, so the call runs in the
+// store's transaction or a new one.
+func (s *MySQLStore) AddPurchase(ctx context.Context, p models.Purchase) (int64, int, error) {
+	log := logger.FromContext(ctx)
 
-	ctx, cancel := context.WithTimeout(context.Background(), constants.DBTimeout)
-	defer cancel()
+	log.Debugw("Starting purchase through stored procedure", "user_id", p.UserID, "album_id", p.AlbumID, "quantity", p.Quantity)
 
 	var purchaseID int64
-	err := db.QueryRowContext(ctx, "CALL sp_add_purchase(?, ?, ?)", p.UserID, p.AlbumID, p.Quantity).Scan(&purchaseID)
+	var stock int
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, "CALL sp_add_purchase(?, ?, ?)", p.UserID, p.AlbumID, p.Quantity).Scan(&purchaseID, &stock)
+	})
 	if err != nil {
-		logger.Log.Errorw("Failed to call stored procedure sp_add_purchase", "error", err, "user_id", p.UserID, "album_id", p.AlbumID)
-		return 0, fmt.Errorf("addPurchase: %v", err)
+		log.Errorw("Failed to call stored procedure sp_add_purchase", "error", err, "user_id", p.UserID, "album_id", p.AlbumID)
+		return 0, 0, fmt.Errorf("addPurchase: %w", classifyMySQLError(err))
 	}
 
-	logger.Log.Infow("Purchase added successfully through stored procedure", "purchase_id", purchaseID, "user_id", p.UserID, "album_id", p.AlbumID, "quantity", p.Quantity)
+	log.Infow("Purchase added successfully through stored procedure", "purchase_id", purchaseID, "user_id", p.UserID, "album_id", p.AlbumID, "quantity", p.Quantity)
 
-	return purchaseID, nil
+	return purchaseID, stock, nil
+}
+
+// CancelPurchase calls stored procedure to cancel quantity units of a
+// purchase, or all remaining units if quantity is 0, and return them to stock
+func (s *MySQLStore) CancelPurchase(ctx context.Context, id int64, quantity int) (models.Purchase, int, error) {
+	log := logger.FromContext(ctx)
+
+	// sp_cancel_purchase takes NULL to mean every remaining unit
+	var arg *int
+	if quantity > 0 {
+		arg = &quantity
+	}
+
+	var p models.Purchase
+	var stock int
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, "CALL sp_cancel_purchase(?, ?)", id, arg).
+			Scan(&p.ID, &p.UserID, &p.AlbumID, &p.Quantity, &p.CancelledQuantity, &p.CreatedAt, &stock)
+	})
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_cancel_purchase", "purchase_id", id, "quantity", quantity, "error", err)
+		return p, 0, fmt.Errorf("cancelPurchase %d: %w", id, classifyMySQLError(err))
+	}
+
+	log.Infow("Purchase cancelled", "purchase_id", id, "quantity", quantity, "album_id", p.AlbumID, "cancelled_quantity", p.CancelledQuantity)
+	return p, stock, nil
 }
 
 // GetUserPurchaseSummary calls stored procedure to get a user's purchases with album details and calculates total cost
-func GetUserPurchaseSummary(db *sql.DB, userID int64) (models.UserPurchaseSummary, error) {
-	summary := models.UserPurchaseSummary{}
+func (s *MySQLStore) GetUserPurchaseSummary(ctx context.Context, userID int64) (models.UserPurchaseSummary, error) {
+	log := logger.FromContext(ctx)
 
-	ctx, cancel := context.WithTimeout(context.Background(), constants.DBTimeout)
-	defer cancel()
+	summary := models.UserPurchaseSummary{}
 
 	// Call stored procedure to get user info and purchases
-	rows, err := db.QueryContext(ctx, "CALL sp_get_user_purchase_summary(?)", userID)
+	rows, err := s.q.QueryContext(ctx, "CALL sp_get_user_purchase_summary(?)", userID)
 	if err != nil {
-		logger.Log.Errorw("Failed to call stored procedure sp_get_user_purchase_summary", "user_id", userID, "error", err)
-		return summary, fmt.Errorf("getUserPurchaseSummary %d: %v", userID, err)
+		log.Errorw("Failed to call stored procedure sp_get_user_purchase_summary", "user_id", userID, "error", err)
+		return summary, fmt.Errorf("getUserPurchaseSummary %d: %w", userID, classifyMySQLError(err))
 	}
 	defer rows.Close()
 
-	// First row contains user info
-	if rows.Next() {
-		if err := rows.Scan(&summary.UserID, &summary.Username, &summary.Email); err != nil {
-			logger.Log.Errorw("Failed to scan user info from stored procedure", "user_id", userID, "error", err)
-			return summary, fmt.Errorf("getUserPurchaseSummary %d: %v", userID, err)
+	// First row contains user info; without it the user does not exist
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			log.Errorw("Failed to read user info from stored procedure", "user_id", userID, "error", err)
+			return summary, fmt.Errorf("getUserPurchaseSummary %d: %w", userID, classifyMySQLError(err))
 		}
+		log.Errorw("User not found", "user_id", userID)
+		return summary, fmt.Errorf("getUserPurchaseSummary %d: %w", userID, ErrNotFound)
+	}
+	if err := rows.Scan(&summary.UserID, &summary.Username, &summary.Email); err != nil {
+		log.Errorw("Failed to scan user info from stored procedure", "user_id", userID, "error", err)
+		return summary, fmt.Errorf("getUserPurchaseSummary %d: %w", userID, classifyMySQLError(err))
 	}
 
 	// Move to next result set with purchase details
 	if !rows.NextResultSet() {
-		logger.Log.Infow("No purchase details found for user", "user_id", userID)
+		log.Infow("No purchase details found for user", "user_id", userID)
 		return summary, nil
 	}
 
-	totalCost := float32(0)
-	for rows.Next() {
-		var detail models.PurchaseDetail
-		var price float64
-		if err := rows.Scan(&detail.ID, &detail.AlbumID, &detail.AlbumTitle, &detail.Artist, &price, &detail.Quantity); err != nil {
-			logger.Log.Errorw("Failed to scan purchase detail from stored procedure", "user_id", userID, "error", err)
-			return summary, fmt.Errorf("getUserPurchaseSummary %d: %v", userID, err)
-		}
-		detail.Price = float32(price)
-		detail.Subtotal = detail.Price * float32(detail.Quantity)
-		totalCost += detail.Subtotal
-		summary.Purchases = append(summary.Purchases, detail)
+	if err := scanPurchaseDetails(rows, &summary); err != nil {
+		log.Errorw("Failed to scan purchase detail from stored procedure", "user_id", userID, "error", err)
+		return summary, fmt.Errorf("getUserPurchaseSummary %d: %w", userID, classifyMySQLError(err))
 	}
 
-	summary.TotalCost = totalCost
 	return summary, nil
 }
 
 // GetAllUsersPurchaseSummary calls stored procedure to get purchase summaries for all users
-func GetAllUsersPurchaseSummary(db *sql.DB) ([]models.UserPurchaseSummary, error) {
-	var summaries []models.UserPurchaseSummary
-
-	ctx, cancel := context.WithTimeout(context.Background(), constants.DBTimeout)
-	defer cancel()
+func (s *MySQLStore) GetAllUsersPurchaseSummary(ctx context.Context) ([]models.UserPurchaseSummary, error) {
+	log := logger.FromContext(ctx)
 
 	// Call stored procedure to get all users purchase summaries
-	rows, err := db.QueryContext(ctx, "CALL sp_get_all_users_purchase_summary()")
+	rows, err := s.q.QueryContext(ctx, "CALL sp_get_all_users_purchase_summary()")
 	if err != nil {
-		logger.Log.Errorw("Failed to call stored procedure sp_get_all_users_purchase_summary", "error", err)
-		return nil, fmt.Errorf("getAllUsersPurchaseSummary: %v", err)
+		log.Errorw("Failed to call stored procedure sp_get_all_users_purchase_summary", "error", err)
+		return nil, fmt.Errorf("getAllUsersPurchaseSummary: %w", classifyMySQLError(err))
 	}
 	defer rows.Close()
 
+	summaries, err := scanUsersPurchaseSummary(rows)
+	if err != nil {
+		log.Errorw("Failed to scan all users purchase summary from stored procedure", "error", err)
+		return nil, fmt.Errorf("getAllUsersPurchaseSummary: %w", classifyMySQLError(err))
+	}
+
+	return summaries, nil
+}
+
+// ListUsersPurchaseSummary calls stored procedure to get purchase summaries for one page of users
+func (s *MySQLStore) ListUsersPurchaseSummary(ctx context.Context, page models.PageRequest) ([]models.UserPurchaseSummary, bool, error) {
+	log := logger.FromContext(ctx)
+
+	rows, err := s.q.QueryContext(ctx, "CALL sp_list_users_purchase_summary(?, ?)", page.After, fetchLimit(page))
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_list_users_purchase_summary", "after", page.After, "limit", page.Limit, "error", err)
+		return nil, false, fmt.Errorf("listUsersPurchaseSummary after %d: %w", page.After, classifyMySQLError(err))
+	}
+	defer rows.Close()
+
+	summaries, err := scanUsersPurchaseSummary(rows)
+	if err != nil {
+		log.Errorw("Failed to scan users purchase summary from stored procedure", "error", err)
+		return nil, false, fmt.Errorf("listUsersPurchaseSummary after %d: %w", page.After, classifyMySQLError(err))
+	}
+
+	summaries, hasMore := trimPage(summaries, page)
+	return summaries, hasMore, nil
+}
+
+// scanPurchaseDetails reads (id, album_id, title, artist, unit_price, quantity,
+// currency) rows into summary and accumulates the total cost
+func scanPurchaseDetails(rows *sql.Rows, summary *models.UserPurchaseSummary) error {
+	var totalCost models.Money
+	for rows.Next() {
+		var detail models.PurchaseDetail
+		if err := rows.Scan(&detail.ID, &detail.AlbumID, &detail.AlbumTitle, &detail.Artist, &detail.Price, &detail.Quantity, &detail.Currency); err != nil {
+			return err
+		}
+		detail.Subtotal = detail.Price.Times(detail.Quantity)
+		totalCost += detail.Subtotal
+		summary.Purchases = append(summary.Purchases, detail)
+	}
+
+	summary.TotalCost = totalCost
+	return rows.Err()
+}
+
+// scanUsersPurchaseSummary groups denormalized user/purchase rows, ordered by
+// user ID, into one summary per user
+func scanUsersPurchaseSummary(rows *sql.Rows) ([]models.UserPurchaseSummary, error) {
+	var summaries []models.UserPurchaseSummary
+
 	currentUserID := int64(-1)
 	var currentSummary models.UserPurchaseSummary
-	totalCost := float32(0)
+	var totalCost models.Money
 
 	for rows.Next() {
 		var userID int64
 		var username, email string
 		var purchaseID, albumID, quantity *int64
-		var albumTitle, artist *string
-		var price *float64
+		var albumTitle, artist, currency *string
+		var price *models.Money
 
-		if err := rows.Scan(&userID, &username, &email, &purchaseID, &albumID, &albumTitle, &artist, &price, &quantity); err != nil {
-			logger.Log.Errorw("Failed to scan user purchase summary from stored procedure", "error", err)
-			return nil, fmt.Errorf("getAllUsersPurchaseSummary: %v", err)
+		if err := rows.Scan(&userID, &username, &email, &purchaseID, &albumID, &albumTitle, &artist, &price, &quantity, &currency); err != nil {
+			return nil, err
 		}
 
 		// If we moved to a new user, save the previous one
@@ -196,10 +332,11 @@ func GetAllUsersPurchaseSummary(db *sql.DB) ([]models.UserPurchaseSummary, error
 				AlbumTitle: *albumTitle,
 				Artist:     *artist,
 				Quantity:   int(*quantity),
+				Currency:   *currency,
 			}
 			if price != nil {
-				detail.Price = float32(*price)
-				detail.Subtotal = detail.Price * float32(detail.Quantity)
+				detail.Price = *price
+				detail.Subtotal = detail.Price.Times(detail.Quantity)
 				totalCost += detail.Subtotal
 			}
 			currentSummary.Purchases = append(currentSummary.Purchases, detail)
@@ -213,9 +350,13 @@ func GetAllUsersPurchaseSummary(db *sql.DB) ([]models.UserPurchaseSummary, error
 	}
 
 	if err := rows.Err(); err != nil {
-		logger.Log.Errorw("Error iterating all users purchase summary from stored procedure", "error", err)
-		return nil, fmt.Errorf("getAllUsersPurchaseSummary: %v", err)
+		return nil, err
 	}
 
 	return summaries, nil
 }
+
+// nullTime binds t in UTC, or NULL for the zero time of an open filter bound
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"example/data-access/internal/logger"
+	"example/data-access/internal/models"
+)
+
+// Sales report operations
+
+// TopSellingAlbums calls stored procedure to get the best-selling albums within filter
+func (s *MySQLStore) TopSellingAlbums(ctx context.Context, filter models.PurchaseFilter, limit int) ([]models.AlbumSales, error) {
+	log := logger.FromContext(ctx)
+
+	rows, err := s.q.QueryContext(ctx, "CALL sp_report_top_albums(?, ?, ?)", nullTime(filter.From), nullTime(filter.To), limit)
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_report_top_albums", "from", filter.From, "to", filter.To, "error", err)
+		return nil, fmt.Errorf("topSellingAlbums: %w", classifyMySQLError(err))
+	}
+	defer rows.Close()
+
+	sales, err := scanAlbumSales(rows)
+	if err != nil {
+		log.Errorw("Failed to scan top selling albums from stored procedure", "error", err)
+		return nil, fmt.Errorf("topSellingAlbums: %w", classifyMySQLError(err))
+	}
+	return sales, nil
+}
+
+// RevenueByArtist calls stored procedure to get the artists with the most revenue within filter
+func (s *MySQLStore) RevenueByArtist(ctx context.Context, filter models.PurchaseFilter, limit int) ([]models.ArtistRevenue, error) {
+	log := logger.FromContext(ctx)
+
+	rows, err := s.q.QueryContext(ctx, "CALL sp_report_artist_revenue(?, ?, ?)", nullTime(filter.From), nullTime(filter.To), limit)
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_report_artist_revenue", "from", filter.From, "to", filter.To, "error", err)
+		return nil, fmt.Errorf("revenueByArtist: %w", classifyMySQLError(err))
+	}
+	defer rows.Close()
+
+	revenue, err := scanArtistRevenue(rows)
+	if err != nil {
+		log.Errorw("Failed to scan artist revenue from stored procedure", "error", err)
+		return nil, fmt.Errorf("revenueByArtist: %w", classifyMySQLError(err))
+	}
+	return revenue, nil
+}
+
+// SalesByPeriod calls stored procedure to get units and revenue per day, week
+// or month within filter
+func (s *MySQLStore) SalesByPeriod(ctx context.Context, period string, filter models.PurchaseFilter) ([]models.PeriodSales, error) {
+	log := logger.FromContext(ctx)
+
+	rows, err := s.q.QueryContext(ctx, "CALL sp_report_sales_by_period(?, ?, ?)", period, nullTime(filter.From), nullTime(filter.To))
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_report_sales_by_period", "period", period, "from", filter.From, "to", filter.To, "error", err)
+		return nil, fmt.Errorf("salesByPeriod %s: %w", period, classifyMySQLError(err))
+	}
+	defer rows.Close()
+
+	sales, err := scanPeriodSales(rows)
+	if err != nil {
+		log.Errorw("Failed to scan sales by period from stored procedure", "period", period, "error", err)
+		return nil, fmt.Errorf("salesByPeriod %s: %w", period, classifyMySQLError(err))
+	}
+	return sales, nil
+}
+
+// OrderValue calls stored procedure to get the average order value within filter
+func (s *MySQLStore) OrderValue(ctx context.Context, filter models.PurchaseFilter) (models.OrderValueReport, error) {
+	log := logger.FromContext(ctx)
+
+	var report models.OrderValueReport
+	err := s.q.QueryRowContext(ctx, "CALL sp_report_order_value(?, ?)", nullTime(filter.From), nullTime(filter.To)).
+		Scan(&report.Orders, &report.Units, &report.Revenue, &report.AverageOrderValue)
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_report_order_value", "from", filter.From, "to", filter.To, "error", err)
+		return report, fmt.Errorf("orderValue: %w", classifyMySQLError(err))
+	}
+	return report, nil
+}
+
+// scanAlbumSales reads (album_id, title, artist, units, revenue) rows
+func scanAlbumSales(rows *sql.Rows) ([]models.AlbumSales, error) {
+	sales := []models.AlbumSales{}
+	for rows.Next() {
+		var s models.AlbumSales
+		if err := rows.Scan(&s.AlbumID, &s.Title, &s.Artist, &s.Units, &s.Revenue); err != nil {
+			return nil, err
+		}
+		sales = append(sales, s)
+	}
+	return sales, rows.Err()
+}
+
+// scanArtistRevenue reads (artist, units, revenue) rows
+func scanArtistRevenue(rows *sql.Rows) ([]models.ArtistRevenue, error) {
+	revenue := []models.ArtistRevenue{}
+	for rows.Next() {
+		var r models.ArtistRevenue
+		if err := rows.Scan(&r.Artist, &r.Units, &r.Revenue); err != nil {
+			return nil, err
+		}
+		revenue = append(revenue, r)
+	}
+	return revenue, rows.Err()
+}
+
+// scanPeriodSales reads (period, units, revenue) rows
+func scanPeriodSales(rows *sql.Rows) ([]models.PeriodSales, error) {
+	sales := []models.PeriodSales{}
+	for rows.Next() {
+		var s models.PeriodSales
+		if err := rows.Scan(&s.Period, &s.Units, &s.Revenue); err != nil {
+			return nil, err
+		}
+		sales = append(sales, s)
+	}
+	return sales, rows.Err()
+}
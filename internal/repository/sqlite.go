@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"example/data-access/internal/constants"
+	"example/data-access/internal/models"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is go-sqlite3 with the functions the SQLite store adds:
+// catalog_score(title, artist, terms) scores an album for a catalog search,
+// terms being the query words separated by spaces
+const sqliteDriver = "sqlite3_catalog"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("catalog_score", func(title, artist, terms string) float64 {
+				return catalogScore(title, artist, strings.Fields(terms))
+			}, true)
+		},
+	})
+}
+
+// SQLiteStore implements Store with plain SQL against a SQLite database
+type SQLiteStore struct {
+	db *sql.DB
+	q  querier // db, or tx when the store is bound to a transaction
+	tx *sql.Tx
+}
+
+// NewSQLiteStore returns a Store backed by the given SQLite database
+func NewSQLiteStore(db *sql.DB) *SQLiteStore {
+	return &SQLiteStore{db: db, q: db}
+}
+
+// WithTx runs fn with a SQLiteStore bound to one transaction
+func (s *SQLiteStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	return runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		return fn(&SQLiteStore{db: s.db, q: tx, tx: tx})
+	})
+}
+
+var _ Store = (*SQLiteStore)(nil)
+
+// OpenSQLite opens a SQLite database file, or an in-memory database for ":memory:".
+// Transactions take the write lock up front so the stock check in AddPurchase
+// cannot race with another writer.
+func OpenSQLite(path string) (*sql.DB, error) {
+	dsn := path + "?_txlock=immediate&_busy_timeout=5000&_foreign_keys=on"
+	if path != constants.SQLiteMemoryPath {
+		dsn += "&_journal_mode=WAL"
+	}
+
+	db, err := sql.Open(sqliteDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("openSQLite %q: %v", path, err)
+	}
+
+	// Every connection to :memory: is a separate database, so keep exactly one
+	// and never close it
+	if path == constants.SQLiteMemoryPath {
+		db.SetMaxOpenConns(1)
+		db.SetMaxIdleConns(1)
+		db.SetConnMaxLifetime(0)
+		db.SetConnMaxIdleTime(0)
+	}
+
+	return db, nil
+}
+
+// sqliteMoney binds a price as the integer cents SQLite stores
+func sqliteMoney(m models.Money) interface{} {
+	return int64(m)
+}
+
+// sqliteNullMoney binds an optional price as the integer cents SQLite
+// stores, or NULL when it is not set
+func sqliteNullMoney(m *models.Money) sql.NullInt64 {
+	if m == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*m), Valid: true}
+}
+
+// requireRow returns ErrNotFound if an UPDATE or DELETE matched no row
+func requireRow(result sql.Result, id int64) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("record %d: %w", id, ErrNotFound)
+	}
+	return nil
+}
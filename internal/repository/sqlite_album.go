@@ -0,0 +1,320 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"example/data-access/internal/logger"
+	"example/data-access/internal/models"
+)
+
+// Album database operations (SQLite)
+
+// GetAllAlbums gets all albums in the database
+func (s *SQLiteStore) GetAllAlbums(ctx context.Context) ([]models.Album, error) {
+	log := logger.FromContext(ctx)
+
+	var albums []models.Album
+
+	rows, err := s.q.QueryContext(ctx, "SELECT id, title, artist, price, stock FROM album WHERE deleted_at IS NULL")
+	if err != nil {
+		log.Errorw("Failed to query albums", "error", err)
+		return nil, fmt.Errorf("getAllAlbums: %w", classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alb models.Album
+		if err := rows.Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Stock); err != nil {
+			log.Errorw("Failed to scan album", "error", err)
+			return nil, fmt.Errorf("getAllAlbums: %w", classifySQLiteError(err))
+		}
+		albums = append(albums, alb)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating albums", "error", err)
+		return nil, fmt.Errorf("getAllAlbums: %w", classifySQLiteError(err))
+	}
+
+	return albums, nil
+}
+
+// ListAlbums gets one page of albums after the given ID
+func (s *SQLiteStore) ListAlbums(ctx context.Context, page models.PageRequest) ([]models.Album, bool, error) {
+	log := logger.FromContext(ctx)
+
+	var albums []models.Album
+
+	rows, err := s.q.QueryContext(ctx, "SELECT id, title, artist, price, stock FROM album WHERE id > ? AND deleted_at IS NULL ORDER BY id LIMIT ?", page.After, fetchLimit(page))
+	if err != nil {
+		log.Errorw("Failed to query albums", "after", page.After, "limit", page.Limit, "error", err)
+		return nil, false, fmt.Errorf("listAlbums after %d: %w", page.After, classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alb models.Album
+		if err := rows.Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Stock); err != nil {
+			log.Errorw("Failed to scan album", "error", err)
+			return nil, false, fmt.Errorf("listAlbums after %d: %w", page.After, classifySQLiteError(err))
+		}
+		albums = append(albums, alb)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating albums", "error", err)
+		return nil, false, fmt.Errorf("listAlbums after %d: %w", page.After, classifySQLiteError(err))
+	}
+
+	albums, hasMore := trimPage(albums, page)
+	return albums, hasMore, nil
+}
+
+// GetAlbumsByArtist gets albums that have the specified artist name
+func (s *SQLiteStore) GetAlbumsByArtist(ctx context.Context, name string) ([]models.Album, error) {
+	log := logger.FromContext(ctx)
+
+	var albums []models.Album
+
+	rows, err := s.q.QueryContext(ctx, "SELECT id, title, artist, price, stock FROM album WHERE artist = ? AND deleted_at IS NULL", name)
+	if err != nil {
+		log.Errorw("Failed to query albums by artist", "artist", name, "error", err)
+		return nil, fmt.Errorf("getAlbumsByArtist %q: %w", name, classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alb models.Album
+		if err := rows.Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Stock); err != nil {
+			log.Errorw("Failed to scan album", "artist", name, "error", err)
+			return nil, fmt.Errorf("getAlbumsByArtist %q: %w", name, classifySQLiteError(err))
+		}
+		albums = append(albums, alb)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating albums by artist", "artist", name, "error", err)
+		return nil, fmt.Errorf("getAlbumsByArtist %q: %w", name, classifySQLiteError(err))
+	}
+
+	return albums, nil
+}
+
+// ListAlbumsByArtist gets one page of the albums that have the specified
+// artist name, after the given ID
+func (s *SQLiteStore) ListAlbumsByArtist(ctx context.Context, name string, page models.PageRequest) ([]models.Album, bool, error) {
+	log := logger.FromContext(ctx)
+
+	var albums []models.Album
+
+	rows, err := s.q.QueryContext(ctx, "SELECT id, title, artist, price, stock FROM album WHERE artist = ? AND id > ? AND deleted_at IS NULL ORDER BY id LIMIT ?", name, page.After, fetchLimit(page))
+	if err != nil {
+		log.Errorw("Failed to query albums by artist", "artist", name, "after", page.After, "limit", page.Limit, "error", err)
+		return nil, false, fmt.Errorf("listAlbumsByArtist %q after %d: %w", name, page.After, classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alb models.Album
+		if err := rows.Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Stock); err != nil {
+			log.Errorw("Failed to scan album", "artist", name, "error", err)
+			return nil, false, fmt.Errorf("listAlbumsByArtist %q after %d: %w", name, page.After, classifySQLiteError(err))
+		}
+		albums = append(albums, alb)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating albums by artist", "artist", name, "error", err)
+		return nil, false, fmt.Errorf("listAlbumsByArtist %q after %d: %w", name, page.After, classifySQLiteError(err))
+	}
+
+	albums, hasMore := trimPage(albums, page)
+	return albums, hasMore, nil
+}
+
+// SearchAlbums gets one page of albums matching search
+func (s *SQLiteStore) SearchAlbums(ctx context.Context, search models.AlbumSearch) ([]models.Album, bool, error) {
+	log := logger.FromContext(ctx)
+
+	query, args, err := albumSearchQuery(search, sqliteMoney)
+	if err != nil {
+		return nil, false, fmt.Errorf("searchAlbums: %w", err)
+	}
+
+	var albums []models.Album
+
+	rows, err := s.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		log.Errorw("Failed to search albums", "sort", search.SortBy, "error", err)
+		return nil, false, fmt.Errorf("searchAlbums: %w", classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alb models.Album
+		if err := rows.Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Stock); err != nil {
+			log.Errorw("Failed to scan album", "error", err)
+			return nil, false, fmt.Errorf("searchAlbums: %w", classifySQLiteError(err))
+		}
+		albums = append(albums, alb)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating album search results", "error", err)
+		return nil, false, fmt.Errorf("searchAlbums: %w", classifySQLiteError(err))
+	}
+
+	albums, hasMore := trimPage(albums, models.PageRequest{Limit: search.Limit})
+	return albums, hasMore, nil
+}
+
+// GetAlbumByID gets the album with the specified ID
+func (s *SQLiteStore) GetAlbumByID(ctx context.Context, id int64) (models.Album, error) {
+	log := logger.FromContext(ctx)
+
+	var alb models.Album
+
+	row := s.q.QueryRowContext(ctx, "SELECT id, title, artist, price, stock FROM album WHERE id = ? AND deleted_at IS NULL", id)
+	if err := row.Scan(&alb.ID, &alb.Title, &alb.Artist, &alb.Price, &alb.Stock); err != nil {
+		log.Errorw("Album not found", "album_id", id, "error", err)
+		return alb, fmt.Errorf("getAlbumByID %d: %w", id, classifySQLiteError(err))
+	}
+
+	return alb, nil
+}
+
+// AddAlbum adds an album to the database, returning the album ID of the new entry
+func (s *SQLiteStore) AddAlbum(ctx context.Context, alb models.Album) (int64, error) {
+	log := logger.FromContext(ctx)
+
+	log.Infow("Adding new album", "title", alb.Title, "artist", alb.Artist, "price", alb.Price, "stock", alb.Stock)
+
+	result, err := s.q.ExecContext(ctx, "INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", alb.Title, alb.Artist, sqliteMoney(alb.Price), alb.Stock)
+	if err != nil {
+		log.Errorw("Failed to insert album", "error", err, "title", alb.Title)
+		return 0, fmt.Errorf("addAlbum: %w", classifySQLiteError(err))
+	}
+
+	albumID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("addAlbum: %w", classifySQLiteError(err))
+	}
+
+	log.Infow("Album created", "album_id", albumID, "title", alb.Title, "artist", alb.Artist)
+	return albumID, nil
+}
+
+// UpdateAlbum changes the given fields of an album, returning the updated album
+func (s *SQLiteStore) UpdateAlbum(ctx context.Context, id int64, update models.AlbumUpdate) (models.Album, error) {
+	log := logger.FromContext(ctx)
+
+	var alb models.Album
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		// NULL parameters leave the column unchanged
+		result, err := tx.ExecContext(ctx, `
+			UPDATE album
+			SET title = COALESCE(?, title),
+				artist = COALESCE(?, artist),
+				price = COALESCE(?, price),
+				stock = COALESCE(?, stock)
+			WHERE id = ? AND deleted_at IS NULL`,
+			update.Title, update.Artist, sqliteNullMoney(update.Price), update.Stock, id)
+		if err != nil {
+			return classifySQLiteError(err)
+		}
+		if err := requireRow(result, id); err != nil {
+			return err
+		}
+
+		alb, err = (&SQLiteStore{db: s.db, q: tx, tx: tx}).GetAlbumByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		log.Errorw("Failed to update album", "album_id", id, "error", err)
+		return alb, fmt.Errorf("updateAlbum %d: %w", id, err)
+	}
+
+	log.Infow("Album updated", "album_id", id)
+	return alb, nil
+}
+
+// RestockAlbum adds quantity to an album's stock and records the reason,
+// returning the updated album
+func (s *SQLiteStore) RestockAlbum(ctx context.Context, id int64, quantity int, reason string) (models.Album, error) {
+	log := logger.FromContext(ctx)
+
+	var alb models.Album
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "UPDATE album SET stock = stock + ? WHERE id = ? AND deleted_at IS NULL", quantity, id)
+		if err != nil {
+			return classifySQLiteError(err)
+		}
+		if err := requireRow(result, id); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "INSERT INTO album_restock (album_id, quantity, reason) VALUES (?, ?, ?)", id, quantity, reason); err != nil {
+			return classifySQLiteError(err)
+		}
+
+		alb, err = (&SQLiteStore{db: s.db, q: tx, tx: tx}).GetAlbumByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		log.Errorw("Failed to restock album", "album_id", id, "error", err)
+		return alb, fmt.Errorf("restockAlbum %d: %w", id, err)
+	}
+
+	log.Infow("Album restocked", "album_id", id, "quantity", quantity, "reason", reason, "stock", alb.Stock)
+	return alb, nil
+}
+
+// DeleteAlbum deletes an album, soft-deleting it instead when it has
+// purchases and softIfPurchased is set
+func (s *SQLiteStore) DeleteAlbum(ctx context.Context, id int64, softIfPurchased bool) (bool, error) {
+	log := logger.FromContext(ctx)
+
+	var soft bool
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		var purchases int
+		err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(p.id)
+			FROM album a
+			LEFT JOIN purchase p ON p.album_id = a.id
+			WHERE a.id = ? AND a.deleted_at IS NULL
+			GROUP BY a.id`, id).Scan(&purchases)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("album %d: %w", id, ErrNotFound)
+		}
+		if err != nil {
+			return classifySQLiteError(err)
+		}
+
+		switch {
+		case purchases == 0:
+			if _, err := tx.ExecContext(ctx, "DELETE FROM album_restock WHERE album_id = ?", id); err != nil {
+				return classifySQLiteError(err)
+			}
+			_, err = tx.ExecContext(ctx, "DELETE FROM album WHERE id = ?", id)
+		case softIfPurchased:
+			soft = true
+			_, err = tx.ExecContext(ctx, "UPDATE album SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?", id)
+		default:
+			return fmt.Errorf("album %d has %d purchases: %w", id, purchases, ErrInUse)
+		}
+		if err != nil {
+			return classifySQLiteError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorw("Failed to delete album", "album_id", id, "error", err)
+		return false, fmt.Errorf("deleteAlbum %d: %w", id, err)
+	}
+
+	log.Infow("Album deleted", "album_id", id, "soft", soft)
+	return soft, nil
+}
@@ -0,0 +1,63 @@
+//go:build !sqlite_fts5
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"example/data-access/internal/logger"
+	"example/data-access/internal/models"
+)
+
+// Catalog search operations (SQLite without FTS5)
+//
+// The default go-sqlite3 build has no FTS5 module, so this build scores every
+// album with the catalog_score SQL function and lets SQLite rank and limit
+// them. Build with -tags sqlite_fts5 for the indexed search in
+// sqlite_catalog_fts5.go.
+
+// SearchCatalog finds the albums with the best catalog_score for the query
+// words, including words with a typo
+func (s *SQLiteStore) SearchCatalog(ctx context.Context, query string, limit int) ([]models.CatalogMatch, error) {
+	log := logger.FromContext(ctx)
+
+	terms := catalogTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT id, title, artist, price, stock, score
+		FROM (
+			SELECT id, title, artist, price, stock, catalog_score(title, artist, ?) AS score
+			FROM album
+			WHERE deleted_at IS NULL
+		)
+		WHERE score > 0
+		ORDER BY score DESC, id
+		LIMIT ?`, strings.Join(terms, " "), limit)
+	if err != nil {
+		log.Errorw("Failed to query catalog", "query", query, "error", err)
+		return nil, fmt.Errorf("searchCatalog %q: %w", query, classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	var matches []models.CatalogMatch
+	for rows.Next() {
+		var m models.CatalogMatch
+		if err := rows.Scan(&m.Album.ID, &m.Album.Title, &m.Album.Artist, &m.Album.Price, &m.Album.Stock, &m.Score); err != nil {
+			log.Errorw("Failed to scan catalog match", "query", query, "error", err)
+			return nil, fmt.Errorf("searchCatalog %q: %w", query, classifySQLiteError(err))
+		}
+		matches = append(matches, highlightMatch(m, terms))
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating catalog matches", "query", query, "error", err)
+		return nil, fmt.Errorf("searchCatalog %q: %w", query, classifySQLiteError(err))
+	}
+
+	return matches, nil
+}
@@ -0,0 +1,122 @@
+//go:build sqlite_fts5
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"example/data-access/internal/logger"
+	"example/data-access/internal/models"
+)
+
+// Catalog search operations (SQLite with FTS5)
+//
+// The album_fts and album_trigram indexes are created by the sqlite_fts5
+// migrations, which only builds with this tag apply.
+
+// SearchCatalog runs an FTS5 query over album titles and artists, ranked by
+// bm25 with title matches weighted twice as much as artist matches. If that
+// finds fewer than limit albums, the rest are filled up with albums matching
+// a word with a typo.
+func (s *SQLiteStore) SearchCatalog(ctx context.Context, query string, limit int) ([]models.CatalogMatch, error) {
+	log := logger.FromContext(ctx)
+
+	terms := catalogTerms(query)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	// Any word may match, each as a prefix; terms are letters and digits only
+	match := `"` + strings.Join(terms, `"* OR "`) + `"*`
+
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT a.id, a.title, a.artist, a.price, a.stock, -bm25(album_fts, 2.0, 1.0)
+		FROM album_fts
+		JOIN album a ON a.id = album_fts.rowid
+		WHERE album_fts MATCH ? AND a.deleted_at IS NULL
+		ORDER BY bm25(album_fts, 2.0, 1.0), a.id
+		LIMIT ?`, match, limit)
+	if err != nil {
+		log.Errorw("Failed to query catalog index", "query", query, "error", err)
+		return nil, fmt.Errorf("searchCatalog %q: %w", query, classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	var matches []models.CatalogMatch
+	for rows.Next() {
+		var m models.CatalogMatch
+		if err := rows.Scan(&m.Album.ID, &m.Album.Title, &m.Album.Artist, &m.Album.Price, &m.Album.Stock, &m.Score); err != nil {
+			log.Errorw("Failed to scan catalog match", "query", query, "error", err)
+			return nil, fmt.Errorf("searchCatalog %q: %w", query, classifySQLiteError(err))
+		}
+		matches = append(matches, highlightMatch(m, terms))
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating catalog matches", "query", query, "error", err)
+		return nil, fmt.Errorf("searchCatalog %q: %w", query, classifySQLiteError(err))
+	}
+	rows.Close()
+
+	if len(matches) == limit || len(typoTerms(terms)) == 0 {
+		return matches, nil
+	}
+	typos, err := s.searchCatalogTypos(ctx, query, terms, limit)
+	if err != nil {
+		return nil, err
+	}
+	return appendFallbackMatches(matches, typos, limit, terms), nil
+}
+
+// searchCatalogTypos finds the albums sharing a trigram with a query word in
+// album_trigram and returns the limit with the best catalog_score. Albums
+// also found by the word index are among them, so appendFallbackMatches still
+// has enough to fill up with.
+func (s *SQLiteStore) searchCatalogTypos(ctx context.Context, query string, terms []string, limit int) ([]models.CatalogMatch, error) {
+	log := logger.FromContext(ctx)
+
+	// Any trigram of a word long enough for typos may match
+	var trigrams []string
+	for _, term := range typoTerms(terms) {
+		runes := []rune(term)
+		for i := 0; i+3 <= len(runes); i++ {
+			trigrams = append(trigrams, `"`+string(runes[i:i+3])+`"`)
+		}
+	}
+
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT id, title, artist, price, stock, score
+		FROM (
+			SELECT a.id, a.title, a.artist, a.price, a.stock, catalog_score(a.title, a.artist, ?) AS score
+			FROM album_trigram
+			JOIN album a ON a.id = album_trigram.rowid
+			WHERE album_trigram MATCH ? AND a.deleted_at IS NULL
+		)
+		WHERE score > 0
+		ORDER BY score DESC, id
+		LIMIT ?`, strings.Join(terms, " "), strings.Join(trigrams, " OR "), limit)
+	if err != nil {
+		log.Errorw("Failed to query catalog trigram index", "query", query, "error", err)
+		return nil, fmt.Errorf("searchCatalog %q: %w", query, classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	var matches []models.CatalogMatch
+	for rows.Next() {
+		var m models.CatalogMatch
+		if err := rows.Scan(&m.Album.ID, &m.Album.Title, &m.Album.Artist, &m.Album.Price, &m.Album.Stock, &m.Score); err != nil {
+			log.Errorw("Failed to scan catalog typo match", "query", query, "error", err)
+			return nil, fmt.Errorf("searchCatalog %q: %w", query, classifySQLiteError(err))
+		}
+		matches = append(matches, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating catalog typo matches", "query", query, "error", err)
+		return nil, fmt.Errorf("searchCatalog %q: %w", query, classifySQLiteError(err))
+	}
+
+	return matches, nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"example/data-access/internal/logger"
+	"example/data-access/internal/models"
+)
+
+// Order database operations (SQLite)
+
+// PlaceOrder creates an order and adds each line as a purchase, all in one
+// transaction
+func (s *SQLiteStore) PlaceOrder(ctx context.Context, order models.Order) (models.Order, map[int64]int, error) {
+	log := logger.FromContext(ctx)
+
+	log.Debugw("Starting order transaction", "user_id", order.UserID, "lines", len(order.Lines))
+
+	placed := models.Order{UserID: order.UserID, Lines: append([]models.OrderLine(nil), order.Lines...)}
+	stock := make(map[int64]int, len(order.Lines))
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		if err := checkPurchaser(ctx, tx, order.UserID); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, "INSERT INTO orders (user_id) VALUES (?)", order.UserID)
+		if err != nil {
+			return classifySQLiteError(err)
+		}
+		if placed.ID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+
+		orderID := sql.NullInt64{Int64: placed.ID, Valid: true}
+		for _, i := range lockOrder(placed.Lines) {
+			line := &placed.Lines[i]
+			p := models.Purchase{UserID: order.UserID, AlbumID: line.AlbumID, Quantity: line.Quantity}
+			var left int
+			if line.ID, left, err = insertPurchase(ctx, tx, p, orderID); err != nil {
+				return &OrderLineError{Line: i, AlbumID: line.AlbumID, Err: err}
+			}
+			stock[line.AlbumID] = left
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warnw("Failed to place order", "user_id", order.UserID, "error", err)
+		return models.Order{}, nil, fmt.Errorf("placeOrder: %w", err)
+	}
+
+	log.Infow("Order placed", "order_id", placed.ID, "user_id", placed.UserID, "lines", len(placed.Lines))
+	return placed, stock, nil
+}
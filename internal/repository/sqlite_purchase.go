@@ -0,0 +1,392 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"example/data-access/internal/logger"
+	"example/data-access/internal/models"
+)
+
+// Purchase database operations (SQLite)
+
+// GetAllPurchases gets all purchases in the database
+func (s *SQLiteStore) GetAllPurchases(ctx context.Context) ([]models.Purchase, error) {
+	log := logger.FromContext(ctx)
+
+	var purchases []models.Purchase
+
+	rows, err := s.q.QueryContext(ctx, "SELECT id, user_id, album_id, quantity, cancelled_quantity, created_at FROM purchase")
+	if err != nil {
+		log.Errorw("Failed to query purchases", "error", err)
+		return nil, fmt.Errorf("getAllPurchases: %w", classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Purchase
+		if err := rows.Scan(&p.ID, &p.UserID, &p.AlbumID, &p.Quantity, &p.CancelledQuantity, &p.CreatedAt); err != nil {
+			log.Errorw("Failed to scan purchase", "error", err)
+			return nil, fmt.Errorf("getAllPurchases: %w", classifySQLiteError(err))
+		}
+		purchases = append(purchases, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating purchases", "error", err)
+		return nil, fmt.Errorf("getAllPurchases: %w", classifySQLiteError(err))
+	}
+
+	return purchases, nil
+}
+
+// ListPurchases gets one page of purchases after the given ID, made within filter
+func (s *SQLiteStore) ListPurchases(ctx context.Context, filter models.PurchaseFilter, page models.PageRequest) ([]models.Purchase, bool, error) {
+	log := logger.FromContext(ctx)
+
+	var purchases []models.Purchase
+
+	where, args := purchaseFilterSQL("created_at", filter)
+	args = append([]interface{}{page.After}, append(args, fetchLimit(page))...)
+	rows, err := s.q.QueryContext(ctx, "SELECT id, user_id, album_id, quantity, cancelled_quantity, created_at FROM purchase WHERE id > ?"+where+" ORDER BY id LIMIT ?", args...)
+	if err != nil {
+		log.Errorw("Failed to query purchases", "after", page.After, "limit", page.Limit, "error", err)
+		return nil, false, fmt.Errorf("listPurchases after %d: %w", page.After, classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Purchase
+		if err := rows.Scan(&p.ID, &p.UserID, &p.AlbumID, &p.Quantity, &p.CancelledQuantity, &p.CreatedAt); err != nil {
+			log.Errorw("Failed to scan purchase", "error", err)
+			return nil, false, fmt.Errorf("listPurchases after %d: %w", page.After, classifySQLiteError(err))
+		}
+		purchases = append(purchases, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating purchases", "error", err)
+		return nil, false, fmt.Errorf("listPurchases after %d: %w", page.After, classifySQLiteError(err))
+	}
+
+	purchases, hasMore := trimPage(purchases, page)
+	return purchases, hasMore, nil
+}
+
+// GetPurchasesByUserID gets purchases by a specific user made within filter
+func (s *SQLiteStore) GetPurchasesByUserID(ctx context.Context, userID int64, filter models.PurchaseFilter) ([]models.Purchase, error) {
+	log := logger.FromContext(ctx)
+
+	var purchases []models.Purchase
+
+	where, args := purchaseFilterSQL("created_at", filter)
+	args = append([]interface{}{userID}, args...)
+	rows, err := s.q.QueryContext(ctx, "SELECT id, user_id, album_id, quantity, cancelled_quantity, created_at FROM purchase WHERE user_id = ?"+where+" ORDER BY id", args...)
+	if err != nil {
+		log.Errorw("Failed to query purchases by user", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("getPurchasesByUserID %d: %w", userID, classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Purchase
+		if err := rows.Scan(&p.ID, &p.UserID, &p.AlbumID, &p.Quantity, &p.CancelledQuantity, &p.CreatedAt); err != nil {
+			log.Errorw("Failed to scan purchase", "user_id", userID, "error", err)
+			return nil, fmt.Errorf("getPurchasesByUserID %d: %w", userID, classifySQLiteError(err))
+		}
+		purchases = append(purchases, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating purchases by user", "user_id", userID, "error", err)
+		return nil, fmt.Errorf("getPurchasesByUserID %d: %w", userID, classifySQLiteError(err))
+	}
+
+	return purchases, nil
+}
+
+// ListPurchasesByUserID gets one page of a user's purchases after the given
+// ID, made within filter
+func (s *SQLiteStore) ListPurchasesByUserID(ctx context.Context, userID int64, filter models.PurchaseFilter, page models.PageRequest) ([]models.Purchase, bool, error) {
+	log := logger.FromContext(ctx)
+
+	var purchases []models.Purchase
+
+	where, args := purchaseFilterSQL("created_at", filter)
+	args = append([]interface{}{userID, page.After}, append(args, fetchLimit(page))...)
+	rows, err := s.q.QueryContext(ctx, "SELECT id, user_id, album_id, quantity, cancelled_quantity, created_at FROM purchase WHERE user_id = ? AND id > ?"+where+" ORDER BY id LIMIT ?", args...)
+	if err != nil {
+		log.Errorw("Failed to query purchases by user", "user_id", userID, "after", page.After, "limit", page.Limit, "error", err)
+		return nil, false, fmt.Errorf("listPurchasesByUserID %d after %d: %w", userID, page.After, classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.Purchase
+		if err := rows.Scan(&p.ID, &p.UserID, &p.AlbumID, &p.Quantity, &p.CancelledQuantity, &p.CreatedAt); err != nil {
+			log.Errorw("Failed to scan purchase", "user_id", userID, "error", err)
+			return nil, false, fmt.Errorf("listPurchasesByUserID %d after %d: %w", userID, page.After, classifySQLiteError(err))
+		}
+		purchases = append(purchases, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating purchases by user", "user_id", userID, "error", err)
+		return nil, false, fmt.Errorf("listPurchasesByUserID %d after %d: %w", userID, page.After, classifySQLiteError(err))
+	}
+
+	purchases, hasMore := trimPage(purchases, page)
+	return purchases, hasMore, nil
+}
+
+// sqliteTimeLayout is how CURRENT_TIMESTAMP stores times, so that filter bounds
+// compare correctly against created_at as text
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// purchaseFilterSQL returns the AND conditions and arguments that limit
+// purchases to filter's range of column, the purchase's created_at
+func purchaseFilterSQL(column string, filter models.PurchaseFilter) (string, []interface{}) {
+	var where string
+	var args []interface{}
+	if !filter.From.IsZero() {
+		where += " AND " + column + " >= ?"
+		args = append(args, filter.From.UTC().Format(sqliteTimeLayout))
+	}
+	if !filter.To.IsZero() {
+		where += " AND " + column + " < ?"
+		args = append(args, filter.To.UTC().Format(sqliteTimeLayout))
+	}
+	return where, args
+}
+
+// AddPurchase adds a purchase to the database, returning the purchase ID of the new entry
+// and the album's stock left. Like sp_add_purchase it checks stock, inserts the purchase
+// and decrements stock in one transaction, joining the store's transaction if it has one.
+func (s *SQLiteStore) AddPurchase(ctx context.Context, p models.Purchase) (int64, int, error) {
+	log := logger.FromContext(ctx)
+
+	log.Debugw("Starting purchase transaction", "user_id", p.UserID, "album_id", p.AlbumID, "quantity", p.Quantity)
+
+	var purchaseID int64
+	var stock int
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		if err := checkPurchaser(ctx, tx, p.UserID); err != nil {
+			return err
+		}
+
+		var err error
+		purchaseID, stock, err = insertPurchase(ctx, tx, p, sql.NullInt64{})
+		return err
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("addPurchase: %w", err)
+	}
+
+	log.Infow("Purchase added successfully", "purchase_id", purchaseID, "user_id", p.UserID, "album_id", p.AlbumID, "quantity", p.Quantity)
+
+	return purchaseID, stock, nil
+}
+
+// checkPurchaser returns ErrInvalidReference unless the user exists and is
+// not anonymized
+func checkPurchaser(ctx context.Context, tx *sql.Tx, userID int64) error {
+	log := logger.FromContext(ctx)
+
+	var id int64
+	err := tx.QueryRowContext(ctx, "SELECT id FROM user WHERE id = ? AND deleted_at IS NULL", userID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warnw("User not found for purchase", "user_id", userID)
+		return fmt.Errorf("user %d: %w", userID, ErrInvalidReference)
+	}
+	if err != nil {
+		log.Errorw("Failed to read purchasing user", "error", err, "user_id", userID)
+		return classifySQLiteError(err)
+	}
+	return nil
+}
+
+// insertPurchase checks the album's stock, inserts the purchase at the album's
+// current price, optionally as a line of orderID, and decrements the stock.
+// It returns the purchase ID and the stock left.
+func insertPurchase(ctx context.Context, tx *sql.Tx, p models.Purchase, orderID sql.NullInt64) (int64, int, error) {
+	log := logger.FromContext(ctx)
+
+	// Check current stock and take the price the purchase is made at
+	var stock int
+	var price models.Money
+	err := tx.QueryRowContext(ctx, "SELECT stock, price FROM album WHERE id = ? AND deleted_at IS NULL", p.AlbumID).Scan(&stock, &price)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Warnw("Album not found for purchase", "album_id", p.AlbumID)
+		return 0, 0, fmt.Errorf("album %d: %w", p.AlbumID, ErrNotFound)
+	}
+	if err != nil {
+		log.Errorw("Failed to read album stock", "error", err, "album_id", p.AlbumID)
+		return 0, 0, classifySQLiteError(err)
+	}
+
+	if stock < p.Quantity {
+		log.Warnw("Insufficient stock for purchase", "album_id", p.AlbumID, "stock", stock, "quantity", p.Quantity)
+		return 0, 0, fmt.Errorf("album %d: %w", p.AlbumID, ErrInsufficientStock)
+	}
+
+	// Insert purchase
+	result, err := tx.ExecContext(ctx, "INSERT INTO purchase (user_id, album_id, quantity, order_id, unit_price) VALUES (?, ?, ?, ?, ?)", p.UserID, p.AlbumID, p.Quantity, orderID, sqliteMoney(price))
+	if err != nil {
+		log.Errorw("Failed to insert purchase", "error", err, "user_id", p.UserID, "album_id", p.AlbumID)
+		return 0, 0, classifySQLiteError(err)
+	}
+
+	purchaseID, err := result.LastInsertId()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Decrement stock
+	err = tx.QueryRowContext(ctx, "UPDATE album SET stock = stock - ? WHERE id = ? RETURNING stock", p.Quantity, p.AlbumID).Scan(&stock)
+	if err != nil {
+		log.Errorw("Failed to decrement album stock", "error", err, "album_id", p.AlbumID)
+		return 0, 0, classifySQLiteError(err)
+	}
+	return purchaseID, stock, nil
+}
+
+// CancelPurchase cancels quantity units of a purchase, or all remaining units
+// if quantity is 0, and returns them to the album's stock in one transaction
+func (s *SQLiteStore) CancelPurchase(ctx context.Context, id int64, quantity int) (models.Purchase, int, error) {
+	log := logger.FromContext(ctx)
+
+	var p models.Purchase
+	var stock int
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		var albumID int64
+		var remaining int
+		err := tx.QueryRowContext(ctx, "SELECT album_id, quantity - cancelled_quantity FROM purchase WHERE id = ?", id).Scan(&albumID, &remaining)
+		if err != nil {
+			return classifySQLiteError(err)
+		}
+
+		if quantity == 0 {
+			quantity = remaining
+		}
+		if quantity < 1 || quantity > remaining {
+			log.Warnw("Cancel quantity exceeds purchase", "purchase_id", id, "quantity", quantity, "remaining", remaining)
+			return fmt.Errorf("purchase %d has %d units left to cancel: %w", id, remaining, ErrNotCancellable)
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE purchase SET cancelled_quantity = cancelled_quantity + ? WHERE id = ?", quantity, id); err != nil {
+			return classifySQLiteError(err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO purchase_cancellation (purchase_id, quantity) VALUES (?, ?)", id, quantity); err != nil {
+			return classifySQLiteError(err)
+		}
+
+		// Return the units to stock
+		err = tx.QueryRowContext(ctx, "UPDATE album SET stock = stock + ? WHERE id = ? RETURNING stock", quantity, albumID).Scan(&stock)
+		if err != nil {
+			return classifySQLiteError(err)
+		}
+
+		err = tx.QueryRowContext(ctx, "SELECT id, user_id, album_id, quantity, cancelled_quantity, created_at FROM purchase WHERE id = ?", id).
+			Scan(&p.ID, &p.UserID, &p.AlbumID, &p.Quantity, &p.CancelledQuantity, &p.CreatedAt)
+		return classifySQLiteError(err)
+	})
+	if err != nil {
+		log.Errorw("Failed to cancel purchase", "purchase_id", id, "quantity", quantity, "error", err)
+		return p, 0, fmt.Errorf("cancelPurchase %d: %w", id, err)
+	}
+
+	log.Infow("Purchase cancelled", "purchase_id", id, "quantity", quantity, "album_id", p.AlbumID, "cancelled_quantity", p.CancelledQuantity)
+	return p, stock, nil
+}
+
+// GetUserPurchaseSummary gets a user's purchases with album details and calculates total cost.
+// SQLite has no multiple result sets, so the user and purchase queries run separately.
+// An anonymized user is not found.
+func (s *SQLiteStore) GetUserPurchaseSummary(ctx context.Context, userID int64) (models.UserPurchaseSummary, error) {
+	log := logger.FromContext(ctx)
+
+	summary := models.UserPurchaseSummary{}
+
+	// Get user info
+	err := s.q.QueryRowContext(ctx, "SELECT id, username, email FROM user WHERE id = ? AND deleted_at IS NULL", userID).Scan(&summary.UserID, &summary.Username, &summary.Email)
+	if err != nil {
+		log.Errorw("Failed to query user info for summary", "user_id", userID, "error", err)
+		return summary, fmt.Errorf("getUserPurchaseSummary %d: %w", userID, classifySQLiteError(err))
+	}
+
+	// Get purchase details with album info, priced as they were bought and
+	// counting only units not cancelled
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT p.id, p.album_id, a.title, a.artist, p.unit_price, p.quantity - p.cancelled_quantity, p.currency
+		FROM purchase p
+		JOIN album a ON p.album_id = a.id
+		WHERE p.user_id = ? AND p.quantity > p.cancelled_quantity
+		ORDER BY p.id`, userID)
+	if err != nil {
+		log.Errorw("Failed to query purchase details for summary", "user_id", userID, "error", err)
+		return summary, fmt.Errorf("getUserPurchaseSummary %d: %w", userID, classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	if err := scanPurchaseDetails(rows, &summary); err != nil {
+		log.Errorw("Failed to scan purchase detail", "user_id", userID, "error", err)
+		return summary, fmt.Errorf("getUserPurchaseSummary %d: %w", userID, classifySQLiteError(err))
+	}
+
+	return summary, nil
+}
+
+// GetAllUsersPurchaseSummary gets purchase summaries for all users not anonymized
+func (s *SQLiteStore) GetAllUsersPurchaseSummary(ctx context.Context) ([]models.UserPurchaseSummary, error) {
+	log := logger.FromContext(ctx)
+
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT u.id, u.username, u.email, p.id, p.album_id, a.title, a.artist, p.unit_price, p.quantity - p.cancelled_quantity, p.currency
+		FROM user u
+		LEFT JOIN purchase p ON u.id = p.user_id AND p.quantity > p.cancelled_quantity
+		LEFT JOIN album a ON p.album_id = a.id
+		WHERE u.deleted_at IS NULL
+		ORDER BY u.id, p.id`)
+	if err != nil {
+		log.Errorw("Failed to query all users purchase summary", "error", err)
+		return nil, fmt.Errorf("getAllUsersPurchaseSummary: %w", classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	summaries, err := scanUsersPurchaseSummary(rows)
+	if err != nil {
+		log.Errorw("Failed to scan all users purchase summary", "error", err)
+		return nil, fmt.Errorf("getAllUsersPurchaseSummary: %w", classifySQLiteError(err))
+	}
+
+	return summaries, nil
+}
+
+// ListUsersPurchaseSummary gets purchase summaries for one page of users not anonymized.
+// The limit applies to users, so it is taken in a subquery before the join.
+func (s *SQLiteStore) ListUsersPurchaseSummary(ctx context.Context, page models.PageRequest) ([]models.UserPurchaseSummary, bool, error) {
+	log := logger.FromContext(ctx)
+
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT u.id, u.username, u.email, p.id, p.album_id, a.title, a.artist, p.unit_price, p.quantity - p.cancelled_quantity, p.currency
+		FROM (SELECT id, username, email FROM user WHERE id > ? AND deleted_at IS NULL ORDER BY id LIMIT ?) u
+		LEFT JOIN purchase p ON u.id = p.user_id AND p.quantity > p.cancelled_quantity
+		LEFT JOIN album a ON p.album_id = a.id
+		ORDER BY u.id, p.id`, page.After, fetchLimit(page))
+	if err != nil {
+		log.Errorw("Failed to query users purchase summary", "after", page.After, "limit", page.Limit, "error", err)
+		return nil, false, fmt.Errorf("listUsersPurchaseSummary after %d: %w", page.After, classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	summaries, err := scanUsersPurchaseSummary(rows)
+	if err != nil {
+		log.Errorw("Failed to scan users purchase summary", "error", err)
+		return nil, false, fmt.Errorf("listUsersPurchaseSummary after %d: %w", page.After, classifySQLiteError(err))
+	}
+
+	summaries, hasMore := trimPage(summaries, page)
+	return summaries, hasMore, nil
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"example/data-access/internal/constants"
+	"example/data-access/internal/logger"
+	"example/data-access/internal/models"
+)
+
+// Sales report operations (SQLite). The queries match the sp_report_*
+// procedures: only units not cancelled count, at the price they were bought at.
+
+// netUnits and netRevenue are the units and revenue of purchase p left after cancellations
+const (
+	netUnits   = "(p.quantity - p.cancelled_quantity)"
+	netRevenue = "(p.quantity - p.cancelled_quantity) * p.unit_price"
+)
+
+// reportPeriodStarts maps each report period to the expression for the first
+// day of the period a purchase falls in. Weeks start on Monday.
+var reportPeriodStarts = map[string]string{
+	constants.ReportPeriodDay:   "date(p.created_at)",
+	constants.ReportPeriodWeek:  "date(p.created_at, '-' || ((CAST(strftime('%w', p.created_at) AS INTEGER) + 6) % 7) || ' days')",
+	constants.ReportPeriodMonth: "strftime('%Y-%m-01', p.created_at)",
+}
+
+// TopSellingAlbums gets the best-selling albums within filter
+func (s *SQLiteStore) TopSellingAlbums(ctx context.Context, filter models.PurchaseFilter, limit int) ([]models.AlbumSales, error) {
+	log := logger.FromContext(ctx)
+
+	where, args := purchaseFilterSQL("p.created_at", filter)
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT a.id, a.title, a.artist, SUM(`+netUnits+`) AS units, SUM(`+netRevenue+`) AS revenue
+		FROM purchase p
+		JOIN album a ON p.album_id = a.id
+		WHERE p.quantity > p.cancelled_quantity`+where+`
+		GROUP BY a.id, a.title, a.artist
+		ORDER BY units DESC, revenue DESC, a.id
+		LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		log.Errorw("Failed to query top selling albums", "from", filter.From, "to", filter.To, "error", err)
+		return nil, fmt.Errorf("topSellingAlbums: %w", classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	sales, err := scanAlbumSales(rows)
+	if err != nil {
+		log.Errorw("Failed to scan top selling albums", "error", err)
+		return nil, fmt.Errorf("topSellingAlbums: %w", classifySQLiteError(err))
+	}
+	return sales, nil
+}
+
+// RevenueByArtist gets the artists with the most revenue within filter
+func (s *SQLiteStore) RevenueByArtist(ctx context.Context, filter models.PurchaseFilter, limit int) ([]models.ArtistRevenue, error) {
+	log := logger.FromContext(ctx)
+
+	where, args := purchaseFilterSQL("p.created_at", filter)
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT a.artist, SUM(`+netUnits+`) AS units, SUM(`+netRevenue+`) AS revenue
+		FROM purchase p
+		JOIN album a ON p.album_id = a.id
+		WHERE p.quantity > p.cancelled_quantity`+where+`
+		GROUP BY a.artist
+		ORDER BY revenue DESC, a.artist
+		LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		log.Errorw("Failed to query artist revenue", "from", filter.From, "to", filter.To, "error", err)
+		return nil, fmt.Errorf("revenueByArtist: %w", classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	revenue, err := scanArtistRevenue(rows)
+	if err != nil {
+		log.Errorw("Failed to scan artist revenue", "error", err)
+		return nil, fmt.Errorf("revenueByArtist: %w", classifySQLiteError(err))
+	}
+	return revenue, nil
+}
+
+// SalesByPeriod gets units and revenue per day, week or month within filter
+func (s *SQLiteStore) SalesByPeriod(ctx context.Context, period string, filter models.PurchaseFilter) ([]models.PeriodSales, error) {
+	log := logger.FromContext(ctx)
+
+	start, ok := reportPeriodStarts[period]
+	if !ok {
+		return nil, fmt.Errorf("salesByPeriod: unknown period %q", period)
+	}
+
+	where, args := purchaseFilterSQL("p.created_at", filter)
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT `+start+` AS period, SUM(`+netUnits+`) AS units, SUM(`+netRevenue+`) AS revenue
+		FROM purchase p
+		WHERE p.quantity > p.cancelled_quantity`+where+`
+		GROUP BY period
+		ORDER BY period`, args...)
+	if err != nil {
+		log.Errorw("Failed to query sales by period", "period", period, "from", filter.From, "to", filter.To, "error", err)
+		return nil, fmt.Errorf("salesByPeriod %s: %w", period, classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	sales, err := scanPeriodSales(rows)
+	if err != nil {
+		log.Errorw("Failed to scan sales by period", "period", period, "error", err)
+		return nil, fmt.Errorf("salesByPeriod %s: %w", period, classifySQLiteError(err))
+	}
+	return sales, nil
+}
+
+// OrderValue gets the average order value within filter. A purchase without an
+// order is an order by itself; -id keeps it apart from the real order IDs.
+// The average is rounded to the cent.
+func (s *SQLiteStore) OrderValue(ctx context.Context, filter models.PurchaseFilter) (models.OrderValueReport, error) {
+	log := logger.FromContext(ctx)
+
+	var report models.OrderValueReport
+	where, args := purchaseFilterSQL("p.created_at", filter)
+	err := s.q.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT COALESCE(p.order_id, -p.id)),
+		       COALESCE(SUM(`+netUnits+`), 0),
+		       COALESCE(SUM(`+netRevenue+`), 0),
+		       COALESCE(CAST(ROUND(1.0 * SUM(`+netRevenue+`) / COUNT(DISTINCT COALESCE(p.order_id, -p.id))) AS INTEGER), 0)
+		FROM purchase p
+		WHERE p.quantity > p.cancelled_quantity`+where, args...).
+		Scan(&report.Orders, &report.Units, &report.Revenue, &report.AverageOrderValue)
+	if err != nil {
+		log.Errorw("Failed to query order value", "from", filter.From, "to", filter.To, "error", err)
+		return report, fmt.Errorf("orderValue: %w", classifySQLiteError(err))
+	}
+	return report, nil
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"example/data-access/internal/logger"
+	"example/data-access/internal/models"
+)
+
+// User database operations (SQLite)
+
+// GetAllUsers gets all users in the database
+func (s *SQLiteStore) GetAllUsers(ctx context.Context) ([]models.User, error) {
+	log := logger.FromContext(ctx)
+
+	var users []models.User
+
+	rows, err := s.q.QueryContext(ctx, "SELECT id, username, email FROM user WHERE deleted_at IS NULL")
+	if err != nil {
+		log.Errorw("Failed to query users", "error", err)
+		return nil, fmt.Errorf("getAllUsers: %w", classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email); err != nil {
+			log.Errorw("Failed to scan user", "error", err)
+			return nil, fmt.Errorf("getAllUsers: %w", classifySQLiteError(err))
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating users", "error", err)
+		return nil, fmt.Errorf("getAllUsers: %w", classifySQLiteError(err))
+	}
+
+	return users, nil
+}
+
+// ListUsers gets one page of users after the given ID
+func (s *SQLiteStore) ListUsers(ctx context.Context, page models.PageRequest) ([]models.User, bool, error) {
+	log := logger.FromContext(ctx)
+
+	var users []models.User
+
+	rows, err := s.q.QueryContext(ctx, "SELECT id, username, email FROM user WHERE id > ? AND deleted_at IS NULL ORDER BY id LIMIT ?", page.After, fetchLimit(page))
+	if err != nil {
+		log.Errorw("Failed to query users", "after", page.After, "limit", page.Limit, "error", err)
+		return nil, false, fmt.Errorf("listUsers after %d: %w", page.After, classifySQLiteError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email); err != nil {
+			log.Errorw("Failed to scan user", "error", err)
+			return nil, false, fmt.Errorf("listUsers after %d: %w", page.After, classifySQLiteError(err))
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating users", "error", err)
+		return nil, false, fmt.Errorf("listUsers after %d: %w", page.After, classifySQLiteError(err))
+	}
+
+	users, hasMore := trimPage(users, page)
+	return users, hasMore, nil
+}
+
+// GetUserByID gets a user with the specified ID
+func (s *SQLiteStore) GetUserByID(ctx context.Context, id int64) (models.User, error) {
+	log := logger.FromContext(ctx)
+
+	var user models.User
+
+	row := s.q.QueryRowContext(ctx, "SELECT id, username, email FROM user WHERE id = ? AND deleted_at IS NULL", id)
+	if err := row.Scan(&user.ID, &user.Username, &user.Email); err != nil {
+		log.Errorw("User not found", "user_id", id, "error", err)
+		return user, fmt.Errorf("getUserByID %d: %w", id, classifySQLiteError(err))
+	}
+
+	return user, nil
+}
+
+// AddUser adds a user to the database, returning the user ID of the new entry
+func (s *SQLiteStore) AddUser(ctx context.Context, user models.User) (int64, error) {
+	log := logger.FromContext(ctx)
+
+	log.Infow("Adding new user", "username", user.Username, "email", user.Email)
+
+	result, err := s.q.ExecContext(ctx, "INSERT INTO user (username, email) VALUES (?, ?)", user.Username, user.Email)
+	if err != nil {
+		log.Errorw("Failed to insert user", "error", err, "username", user.Username)
+		return 0, fmt.Errorf("addUser: %w", classifySQLiteError(err))
+	}
+
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("addUser: %w", classifySQLiteError(err))
+	}
+
+	log.Infow("User created", "user_id", userID, "username", user.Username)
+	return userID, nil
+}
+
+// UpdateUser changes a user's username and/or email, returning the updated user
+func (s *SQLiteStore) UpdateUser(ctx context.Context, id int64, update models.UserUpdate) (models.User, error) {
+	log := logger.FromContext(ctx)
+
+	var user models.User
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		// NULL parameters leave the column unchanged
+		result, err := tx.ExecContext(ctx, `
+			UPDATE user
+			SET username = COALESCE(?, username),
+				email = COALESCE(?, email)
+			WHERE id = ? AND deleted_at IS NULL`,
+			update.Username, update.Email, id)
+		if err != nil {
+			return classifySQLiteError(err)
+		}
+		if err := requireRow(result, id); err != nil {
+			return err
+		}
+
+		user, err = (&SQLiteStore{db: s.db, q: tx, tx: tx}).GetUserByID(ctx, id)
+		return err
+	})
+	if err != nil {
+		log.Errorw("Failed to update user", "user_id", id, "error", err)
+		return user, fmt.Errorf("updateUser %d: %w", id, err)
+	}
+
+	log.Infow("User updated", "user_id", id)
+	return user, nil
+}
+
+// DeleteUser deletes a user, handling the user's purchases according to policy
+func (s *SQLiteStore) DeleteUser(ctx context.Context, id int64, policy UserPurchasePolicy) (int, error) {
+	log := logger.FromContext(ctx)
+
+	var purchases int
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(p.id)
+			FROM user u
+			LEFT JOIN purchase p ON p.user_id = u.id
+			WHERE u.id = ? AND u.deleted_at IS NULL
+			GROUP BY u.id`, id).Scan(&purchases)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user %d: %w", id, ErrNotFound)
+		}
+		if err != nil {
+			return classifySQLiteError(err)
+		}
+
+		switch {
+		case purchases == 0 || policy == CascadePurchases:
+			if _, err := tx.ExecContext(ctx, "DELETE FROM purchase WHERE user_id = ?", id); err != nil {
+				return classifySQLiteError(err)
+			}
+			if _, err := tx.ExecContext(ctx, "DELETE FROM orders WHERE user_id = ?", id); err != nil {
+				return classifySQLiteError(err)
+			}
+			_, err = tx.ExecContext(ctx, "DELETE FROM user WHERE id = ?", id)
+		case policy == AnonymizePurchaser:
+			_, err = tx.ExecContext(ctx, `
+				UPDATE user
+				SET username = 'deleted-user-' || id,
+					email = 'deleted-user-' || id || '@invalid',
+					deleted_at = CURRENT_TIMESTAMP
+				WHERE id = ?`, id)
+		default:
+			return fmt.Errorf("user %d has %d purchases: %w", id, purchases, ErrInUse)
+		}
+		if err != nil {
+			return classifySQLiteError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorw("Failed to delete user", "user_id", id, "policy", policy, "error", err)
+		return 0, fmt.Errorf("deleteUser %d: %w", id, err)
+	}
+
+	log.Infow("User deleted", "user_id", id, "policy", policy, "purchases", purchases)
+	return purchases, nil
+}
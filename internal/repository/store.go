@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"example/data-access/internal/models"
+)
+
+// AlbumStore provides access to album records
+type AlbumStore interface {
+	GetAllAlbums(ctx context.Context) ([]models.Album, error)
+	// ListAlbums returns one page of albums in ID order and whether more follow
+	ListAlbums(ctx context.Context, page models.PageRequest) (albums []models.Album, hasMore bool, err error)
+	GetAlbumsByArtist(ctx context.Context, name string) ([]models.Album, error)
+	// ListAlbumsByArtist returns one page of the artist's albums in ID order
+	// and whether more follow
+	ListAlbumsByArtist(ctx context.Context, name string, page models.PageRequest) (albums []models.Album, hasMore bool, err error)
+	// SearchAlbums returns one page of albums matching search, in its sort
+	// order, and whether more follow
+	SearchAlbums(ctx context.Context, search models.AlbumSearch) (albums []models.Album, hasMore bool, err error)
+	GetAlbumByID(ctx context.Context, id int64) (models.Album, error)
+	AddAlbum(ctx context.Context, alb models.Album) (int64, error)
+	UpdateAlbum(ctx context.Context, id int64, update models.AlbumUpdate) (models.Album, error)
+	RestockAlbum(ctx context.Context, id int64, quantity int, reason string) (models.Album, error)
+	// DeleteAlbum removes an album without purchases. An album with purchases
+	// is soft-deleted if softIfPurchased is set, or refused with ErrInUse.
+	DeleteAlbum(ctx context.Context, id int64, softIfPurchased bool) (soft bool, err error)
+}
+
+// CatalogStore provides full-text search over album titles and artists
+type CatalogStore interface {
+	// SearchCatalog returns up to limit albums matching any word of query,
+	// most relevant first. A word also matches longer words it is a prefix of.
+	SearchCatalog(ctx context.Context, query string, limit int) ([]models.CatalogMatch, error)
+}
+
+// UserStore provides access to user records
+type UserStore interface {
+	GetAllUsers(ctx context.Context) ([]models.User, error)
+	// ListUsers returns one page of users in ID order and whether more follow
+	ListUsers(ctx context.Context, page models.PageRequest) (users []models.User, hasMore bool, err error)
+	GetUserByID(ctx context.Context, id int64) (models.User, error)
+	AddUser(ctx context.Context, user models.User) (int64, error)
+	UpdateUser(ctx context.Context, id int64, update models.UserUpdate) (models.User, error)
+	// DeleteUser removes a user and returns how many purchases the user had.
+	// policy decides what happens to those purchases.
+	DeleteUser(ctx context.Context, id int64, policy UserPurchasePolicy) (purchases int, err error)
+}
+
+// UserPurchasePolicy decides what DeleteUser does when the user has purchases
+type UserPurchasePolicy string
+
+const (
+	// BlockIfPurchased refuses the delete with ErrInUse
+	BlockIfPurchased UserPurchasePolicy = "block"
+	// AnonymizePurchaser keeps the purchases and the user row, replacing the
+	// username and email with placeholders and hiding the user
+	AnonymizePurchaser UserPurchasePolicy = "anonymize"
+	// CascadePurchases deletes the purchases along with the user. Stock is
+	// not restored.
+	CascadePurchases UserPurchasePolicy = "cascade"
+)
+
+// PurchaseStore provides access to purchase records
+type PurchaseStore interface {
+	GetAllPurchases(ctx context.Context) ([]models.Purchase, error)
+	// ListPurchases returns one page of the purchases made within filter, in
+	// ID order, and whether more follow
+	ListPurchases(ctx context.Context, filter models.PurchaseFilter, page models.PageRequest) (purchases []models.Purchase, hasMore bool, err error)
+	// GetPurchasesByUserID returns a user's purchases made within filter, in ID order
+	GetPurchasesByUserID(ctx context.Context, userID int64, filter models.PurchaseFilter) ([]models.Purchase, error)
+	// ListPurchasesByUserID returns one page of a user's purchases made within
+	// filter, in ID order, and whether more follow
+	ListPurchasesByUserID(ctx context.Context, userID int64, filter models.PurchaseFilter, page models.PageRequest) (purchases []models.Purchase, hasMore bool, err error)
+	// AddPurchase adds a purchase and returns its ID and the album's stock
+	// left after it
+	AddPurchase(ctx context.Context, p models.Purchase) (id int64, stock int, err error)
+	// CancelPurchase cancels quantity units of a purchase, or every unit not
+	// yet cancelled if quantity is 0, and returns them to the album's stock.
+	// stock is the album's stock after the units are returned.
+	CancelPurchase(ctx context.Context, id int64, quantity int) (p models.Purchase, stock int, err error)
+}
+
+// OrderStore places multi-line orders
+type OrderStore interface {
+	// PlaceOrder stores an order and all its lines in one transaction, or
+	// nothing if any line fails. It returns the order with the order and line
+	// IDs set, and the stock each album of the order has left; a line failure
+	// is reported as an *OrderLineError.
+	PlaceOrder(ctx context.Context, order models.Order) (placed models.Order, stock map[int64]int, err error)
+}
+
+// SummaryStore provides aggregated purchase information per user
+type SummaryStore interface {
+	GetUserPurchaseSummary(ctx context.Context, userID int64) (models.UserPurchaseSummary, error)
+	GetAllUsersPurchaseSummary(ctx context.Context) ([]models.UserPurchaseSummary, error)
+	// ListUsersPurchaseSummary returns the summaries of one page of users in
+	// user ID order, with all of each user's purchases, and whether more follow
+	ListUsersPurchaseSummary(ctx context.Context, page models.PageRequest) (summaries []models.UserPurchaseSummary, hasMore bool, err error)
+}
+
+// ReportStore provides sales reports aggregated in the database. Each counts
+// only units not cancelled, at the price they were bought at, over purchases
+// made within filter.
+type ReportStore interface {
+	// TopSellingAlbums returns up to limit albums with the most units sold
+	TopSellingAlbums(ctx context.Context, filter models.PurchaseFilter, limit int) ([]models.AlbumSales, error)
+	// RevenueByArtist returns up to limit artists with the most revenue
+	RevenueByArtist(ctx context.Context, filter models.PurchaseFilter, limit int) ([]models.ArtistRevenue, error)
+	// SalesByPeriod returns the units and revenue of every day, week or month
+	// with sales, oldest first. period is one of the constants.ReportPeriod values.
+	SalesByPeriod(ctx context.Context, period string, filter models.PurchaseFilter) ([]models.PeriodSales, error)
+	// OrderValue returns the number of orders, their revenue and the average order value
+	OrderValue(ctx context.Context, filter models.PurchaseFilter) (models.OrderValueReport, error)
+}
+
+// Transactor runs several operations atomically
+type Transactor interface {
+	// WithTx calls fn with a Store bound to one database transaction, which is
+	// committed if fn returns nil and rolled back otherwise. Called on a Store
+	// that is already in a transaction, fn joins it.
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+}
+
+// Store is the full set of data operations the server depends on
+type Store interface {
+	AlbumStore
+	CatalogStore
+	UserStore
+	PurchaseStore
+	OrderStore
+	SummaryStore
+	ReportStore
+	Transactor
+}
+
+// MySQLStore implements Store using the MySQL stored procedures
+type MySQLStore struct {
+	db *sql.DB
+	q  querier // db, or tx when the store is bound to a transaction
+	tx *sql.Tx
+}
+
+// NewMySQLStore returns a Store backed by the given MySQL connection pool
+func NewMySQLStore(db *sql.DB) *MySQLStore {
+	return &MySQLStore{db: db, q: db}
+}
+
+// WithTx runs fn with a MySQLStore bound to one transaction
+func (s *MySQLStore) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	return runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		return fn(&MySQLStore{db: s.db, q: tx, tx: tx})
+	})
+}
+
+var _ Store = (*MySQLStore)(nil)
@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"fmt"
 
-	"example/data-access/internal/constants"
 	"example/data-access/internal/logger"
 	"example/data-access/internal/models"
 )
@@ -13,47 +12,76 @@ import (
 // User database operations
 
 // GetAllUsers calls stored procedure to get all users in the database
-func GetAllUsers(db *sql.DB) ([]models.User, error) {
-	var users []models.User
+func (s *MySQLStore) GetAllUsers(ctx context.Context) ([]models.User, error) {
+	log := logger.FromContext(ctx)
 
-	ctx, cancel := context.WithTimeout(context.Background(), constants.DBTimeout)
-	defer cancel()
+	var users []models.User
 
-	rows, err := db.QueryContext(ctx, "CALL sp_get_all_users()")
+	rows, err := s.q.QueryContext(ctx, "CALL sp_get_all_users()")
 	if err != nil {
-		logger.Log.Errorw("Failed to call stored procedure sp_get_all_users", "error", err)
-		return nil, fmt.Errorf("getAllUsers: %v", err)
+		log.Errorw("Failed to call stored procedure sp_get_all_users", "error", err)
+		return nil, fmt.Errorf("getAllUsers: %w", classifyMySQLError(err))
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var user models.User
 		if err := rows.Scan(&user.ID, &user.Username, &user.Email); err != nil {
-			logger.Log.Errorw("Failed to scan user", "error", err)
-			return nil, fmt.Errorf("getAllUsers: %v", err)
+			log.Errorw("Failed to scan user", "error", err)
+			return nil, fmt.Errorf("getAllUsers: %w", classifyMySQLError(err))
 		}
 		users = append(users, user)
 	}
 
 	if err := rows.Err(); err != nil {
-		logger.Log.Errorw("Error iterating users", "error", err)
-		return nil, fmt.Errorf("getAllUsers: %v", err)
+		log.Errorw("Error iterating users", "error", err)
+		return nil, fmt.Errorf("getAllUsers: %w", classifyMySQLError(err))
 	}
 
 	return users, nil
 }
 
+// ListUsers calls stored procedure to get one page of users after the given ID
+func (s *MySQLStore) ListUsers(ctx context.Context, page models.PageRequest) ([]models.User, bool, error) {
+	log := logger.FromContext(ctx)
+
+	var users []models.User
+
+	rows, err := s.q.QueryContext(ctx, "CALL sp_list_users(?, ?)", page.After, fetchLimit(page))
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_list_users", "after", page.After, "limit", page.Limit, "error", err)
+		return nil, false, fmt.Errorf("listUsers after %d: %w", page.After, classifyMySQLError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email); err != nil {
+			log.Errorw("Failed to scan user", "error", err)
+			return nil, false, fmt.Errorf("listUsers after %d: %w", page.After, classifyMySQLError(err))
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Errorw("Error iterating users", "error", err)
+		return nil, false, fmt.Errorf("listUsers after %d: %w", page.After, classifyMySQLError(err))
+	}
+
+	users, hasMore := trimPage(users, page)
+	return users, hasMore, nil
+}
+
 // GetUserByID calls stored procedure to get a user with the specified ID
-func GetUserByID(db *sql.DB, id int64) (models.User, error) {
-	var user models.User
+func (s *MySQLStore) GetUserByID(ctx context.Context, id int64) (models.User, error) {
+	log := logger.FromContext(ctx)
 
-	ctx, cancel := context.WithTimeout(context.Background(), constants.DBTimeout)
-	defer cancel()
+	var user models.User
 
-	row := db.QueryRowContext(ctx, "CALL sp_get_user_by_id(?)", id)
+	row := s.q.QueryRowContext(ctx, "CALL sp_get_user_by_id(?)", id)
 	if err := row.Scan(&user.ID, &user.Username, &user.Email); err != nil {
-		logger.Log.Errorw("User not found", "user_id", id, "error", err)
-		return user, fmt.Errorf("getUserByID %d: %v", id, err)
+		log.Errorw("User not found", "user_id", id, "error", err)
+		return user, fmt.Errorf("getUserByID %d: %w", id, classifyMySQLError(err))
 	}
 
 	return user, nil
@@ -61,19 +89,55 @@ func GetUserByID(db *sql.DB, id int64) (models.User, error) {
 
 // AddUser calls stored procedure to add a user to the database,
 // returning the user ID of the new entry
-func AddUser(db *sql.DB, user models.User) (int64, error) {
-	logger.Log.Infow("Adding new user", "username", user.Username, "email", user.Email)
+func (s *MySQLStore) AddUser(ctx context.Context, user models.User) (int64, error) {
+	log := logger.FromContext(ctx)
 
-	ctx, cancel := context.WithTimeout(context.Background(), constants.DBTimeout)
-	defer cancel()
+	log.Infow("Adding new user", "username", user.Username, "email", user.Email)
 
 	var userID int64
-	err := db.QueryRowContext(ctx, "CALL sp_add_user(?, ?)", user.Username, user.Email).Scan(&userID)
+	err := s.q.QueryRowContext(ctx, "CALL sp_add_user(?, ?)", user.Username, user.Email).Scan(&userID)
 	if err != nil {
-		logger.Log.Errorw("Failed to call stored procedure sp_add_user", "error", err, "username", user.Username)
-		return 0, fmt.Errorf("addUser: %v", err)
+		log.Errorw("Failed to call stored procedure sp_add_user", "error", err, "username", user.Username)
+		return 0, fmt.Errorf("addUser: %w", classifyMySQLError(err))
 	}
 
-	logger.Log.Infow("User created", "user_id", userID, "username", user.Username)
+	log.Infow("User created", "user_id", userID, "username", user.Username)
 	return userID, nil
 }
+
+// UpdateUser calls stored procedure to change a user's username and/or email,
+// returning the updated user
+func (s *MySQLStore) UpdateUser(ctx context.Context, id int64, update models.UserUpdate) (models.User, error) {
+	log := logger.FromContext(ctx)
+
+	var user models.User
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, "CALL sp_update_user(?, ?, ?)", id, update.Username, update.Email).
+			Scan(&user.ID, &user.Username, &user.Email)
+	})
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_update_user", "user_id", id, "error", err)
+		return user, fmt.Errorf("updateUser %d: %w", id, classifyMySQLError(err))
+	}
+
+	log.Infow("User updated", "user_id", id)
+	return user, nil
+}
+
+// DeleteUser calls stored procedure to delete a user, handling the user's
+// purchases according to policy
+func (s *MySQLStore) DeleteUser(ctx context.Context, id int64, policy UserPurchasePolicy) (int, error) {
+	log := logger.FromContext(ctx)
+
+	var purchases int
+	err := runInTx(ctx, s.db, s.tx, func(tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, "CALL sp_delete_user(?, ?)", id, string(policy)).Scan(&purchases)
+	})
+	if err != nil {
+		log.Errorw("Failed to call stored procedure sp_delete_user", "user_id", id, "policy", policy, "error", err)
+		return 0, fmt.Errorf("deleteUser %d: %w", id, classifyMySQLError(err))
+	}
+
+	log.Infow("User deleted", "user_id", id, "policy", policy, "purchases", purchases)
+	return purchases, nil
+}
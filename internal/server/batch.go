@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"example/data-access/internal/constants"
+	"example/data-access/internal/logger"
+	"example/data-access/internal/models"
+	"example/data-access/internal/repository"
+
+	"go.uber.org/zap"
+)
+
+// errBatchItemFailed aborts the transaction of an atomic batch
+var errBatchItemFailed = errors.New("batch item failed")
+
+// handleBatchEnvelope processes a batch envelope and returns one response
+// whose data holds the per-item responses
+func handleBatchEnvelope(ctx context.Context, batch models.WSBatch, clientAddr string) models.WSResponse {
+	log := requestLogger(clientAddr, batch.ID)
+
+	var response models.WSResponse
+	switch {
+	case !validRequestID(batch.ID):
+		log.Warnw(constants.LogInvalidRequest, "error", "invalid batch ID")
+		return validationError(constants.ErrInvalidRequestID, constants.JSONFieldRequestID, nil)
+	case len(batch.Messages) == 0:
+		log.Warnw(constants.LogInvalidRequest, "error", "empty batch")
+		response = validationError(constants.ErrBatchEmpty, constants.JSONFieldMessages, nil)
+	case len(batch.Messages) > maxBatchSize:
+		response, _ = checkBatchSize(len(batch.Messages), clientAddr)
+	case batch.Atomic:
+		response = handleAtomicBatch(ctx, batch.Messages, clientAddr, log)
+	default:
+		response = models.WSResponse{Success: true, Data: handleBatch(ctx, batch.Messages, clientAddr)}
+	}
+
+	response.ID = batch.ID
+	return response
+}
+
+// readOnlyActions are the actions that never write, so batch items using
+// them can run in parallel
+var readOnlyActions = map[string]bool{
+	constants.ActionGetAlbums:                  true,
+	constants.ActionGetAlbumByArtist:           true,
+	constants.ActionSearchAlbums:               true,
+	constants.ActionSearchCatalog:              true,
+	constants.ActionGetAlbumByID:               true,
+	constants.ActionGetUsers:                   true,
+	constants.ActionGetUserByID:                true,
+	constants.ActionGetPurchases:               true,
+	constants.ActionGetPurchasesByUserID:       true,
+	constants.ActionGetUserPurchaseSummary:     true,
+	constants.ActionGetAllUsersPurchaseSummary: true,
+	constants.ActionGetTopSellingAlbums:        true,
+	constants.ActionGetRevenueByArtist:         true,
+	constants.ActionGetSalesByPeriod:           true,
+	constants.ActionGetAverageOrderValue:       true,
+}
+
+// nonStoreActions are the actions with side effects outside the store. A
+// transaction cannot undo them, so atomic batches reject them.
+var nonStoreActions = map[string]bool{
+	constants.ActionSubscribe:   true,
+	constants.ActionUnsubscribe: true,
+}
+
+// checkBatchSize returns a BATCH_TOO_LARGE response and false when a batch of
+// size messages exceeds maxBatchSize
+func checkBatchSize(size int, clientAddr string) (models.WSResponse, bool) {
+	if size <= maxBatchSize {
+		return models.WSResponse{}, true
+	}
+	logger.Log.Warnw(constants.LogInvalidRequest, "error", "batch too large", "size", size, "max", maxBatchSize, "remote_addr", clientAddr)
+	return errorResponse(constants.CodeBatchTooLarge, constants.ErrBatchTooLarge, []string{constants.JSONFieldMessages}, map[string]interface{}{"size": size, "max": maxBatchSize}), false
+}
+
+// handleBatch processes the messages of a non-atomic batch. If every item is
+// read-only they run in parallel, at most batchConcurrency at a time;
+// otherwise they run in order so later items see earlier writes. Responses
+// keep the order of the messages either way.
+func handleBatch(ctx context.Context, messages []models.WSMessage, clientAddr string) []models.WSResponse {
+	responses := make([]models.WSResponse, len(messages))
+
+	for _, m := range messages {
+		if !readOnlyActions[m.Action] {
+			for i, m := range messages {
+				responses[i] = handleMessage(ctx, store, m, clientAddr)
+			}
+			return responses
+		}
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, batchConcurrency)
+	for i, m := range messages {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			responses[i] = handleMessage(ctx, store, m, clientAddr)
+		}()
+	}
+	wg.Wait()
+
+	return responses
+}
+
+// handleAtomicBatch runs messages in order inside one transaction. The first
+// failing item rolls the transaction back; earlier items are then reported as
+// rolled back and later ones as not executed. Batches holding a non-store
+// action are rejected before the transaction starts.
+func handleAtomicBatch(ctx context.Context, messages []models.WSMessage, clientAddr string, log *zap.SugaredLogger) models.WSResponse {
+	for i, m := range messages {
+		if nonStoreActions[m.Action] {
+			log.Warnw(constants.LogInvalidRequest, "error", "non-store action in atomic batch", "index", i, "action", m.Action)
+			return errorResponse(constants.CodeValidationFailed, constants.ErrBatchNotAtomic, []string{constants.JSONFieldMessages}, map[string]interface{}{"index": i, "action": m.Action})
+		}
+	}
+
+	startTime := time.Now()
+
+	items := make([]models.WSResponse, len(messages))
+	failed := -1
+	err := store.WithTx(ctx, func(tx repository.Store) error {
+		for i, m := range messages {
+			items[i] = handleMessage(ctx, tx, m, clientAddr)
+			if !items[i].Success {
+				failed = i
+				return errBatchItemFailed
+			}
+		}
+		return nil
+	})
+
+	duration := time.Since(startTime)
+	if err == nil {
+		log.Infow(constants.LogBatchCommitted, "items", len(messages), "duration_ms", duration.Milliseconds())
+		return models.WSResponse{Success: true, Data: items}
+	}
+
+	for i, m := range messages {
+		switch {
+		case i == failed:
+			continue
+		case failed < 0 || i < failed:
+			items[i] = rolledBackItem(m.ID, constants.CodeRolledBack, constants.ErrItemRolledBack, failed)
+		default:
+			items[i] = rolledBackItem(m.ID, constants.CodeNotExecuted, constants.ErrItemNotExecuted, failed)
+		}
+	}
+
+	if failed < 0 {
+		// Every item succeeded but the commit, or the transaction itself, failed
+		log.Errorw(constants.LogBatchRolledBack, "error", err, "items", len(messages), "duration_ms", duration.Milliseconds())
+		response := storeError(err)
+		response.Data = items
+		return response
+	}
+
+	log.Warnw(constants.LogBatchRolledBack, "failed_index", failed, "code", items[failed].Error.Code, "items", len(messages), "duration_ms", duration.Milliseconds())
+	response := errorResponse(constants.CodeBatchRolledBack, constants.ErrBatchRolledBack, nil, map[string]interface{}{"failed_index": failed})
+	response.Data = items
+	return response
+}
+
+// rolledBackItem is the response for a batch item that did not take effect
+// because item failed (-1 when the commit failed) rolled the batch back
+func rolledBackItem(id json.RawMessage, code, message string, failed int) models.WSResponse {
+	var details map[string]interface{}
+	if failed >= 0 {
+		details = map[string]interface{}{"failed_index": failed}
+	}
+	response := errorResponse(code, message, nil, details)
+	response.ID = id
+	return response
+}
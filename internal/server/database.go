@@ -1,41 +1,123 @@
 package server
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"os"
+	"net"
+	"strconv"
 
+	"example/data-access/internal/config"
+	"example/data-access/internal/constants"
 	"example/data-access/internal/logger"
+	"example/data-access/internal/migrations"
+	"example/data-access/internal/repository"
 
 	"github.com/go-sql-driver/mysql"
 )
 
 var db *sql.DB
 
-// InitDatabase initializes the database connection
-func InitDatabase() error {
-	logger.Log.Debug("Initializing database connection")
+// driver is the name of the backend db was opened with
+var driver string
 
-	cfg := mysql.NewConfig()
-	cfg.User = os.Getenv("DBUSER")
-	cfg.Passwd = os.Getenv("DBPASS")
-	cfg.Net = "tcp"
-	cfg.Addr = "127.0.0.1:3306"
-	cfg.DBName = "recordings"
+// store is the data backend used by the WebSocket handlers
+var store repository.Store
+
+// InitDatabase opens the configured database, applies pending migrations
+// when auto-migration is enabled and installs the matching store
+func InitDatabase(cfg config.Database) error {
+	if err := OpenDatabase(cfg); err != nil {
+		return err
+	}
+
+	if cfg.ShouldAutoMigrate() {
+		applied, err := migrations.Up(db, driver)
+		if err != nil {
+			logger.Log.Errorw("Failed to apply migrations", "driver", driver, "error", err)
+			return fmt.Errorf("failed to apply migrations: %v", err)
+		}
+		logger.Log.Infow("Migrations up to date", "driver", driver, "applied", applied)
+	}
+
+	switch driver {
+	case constants.DriverSQLite:
+		SetStore(repository.NewSQLiteStore(db))
+	default:
+		SetStore(repository.NewMySQLStore(db))
+	}
+	return nil
+}
+
+// OpenDatabase opens and pings the configured database without touching the schema
+func OpenDatabase(cfg config.Database) error {
+	logger.Log.Infow("Database configuration", cfg.SummaryFields()...)
 
 	var err error
-	db, err = sql.Open("mysql", cfg.FormatDSN())
+	switch cfg.Driver {
+	case constants.DriverMySQL:
+		err = openMySQL(cfg)
+	case constants.DriverSQLite:
+		err = openSQLite(cfg)
+	default:
+		err = fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
 	if err != nil {
-		logger.Log.Errorw("Failed to open database", "error", err)
-		return fmt.Errorf("failed to open database: %v", err)
+		logger.Log.Errorw("Failed to open database", "driver", cfg.Driver, "error", err)
+		return err
 	}
+	driver = cfg.Driver
 
-	if err := db.Ping(); err != nil {
+	// The :memory: pool keeps OpenSQLite's single, never recycled connection:
+	// closing it would discard the whole database
+	if cfg.Driver != constants.DriverSQLite || cfg.SQLitePath != constants.SQLiteMemoryPath {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime.Duration)
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout.Duration)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
 		logger.Log.Errorw("Failed to ping database", "error", err)
 		return fmt.Errorf("failed to ping database: %v", err)
 	}
 
-	logger.Log.Infow("Database connection established", "database", "recordings", "host", "127.0.0.1:3306")
+	logger.Log.Infow("Database connection established", "driver", driver)
+	return nil
+}
+
+// openMySQL opens a connection pool to the MySQL server
+func openMySQL(cfg config.Database) error {
+	mysqlCfg := mysql.NewConfig()
+	mysqlCfg.User = cfg.User
+	mysqlCfg.Passwd = cfg.Password
+	mysqlCfg.Net = "tcp"
+	mysqlCfg.Addr = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	mysqlCfg.DBName = cfg.Name
+	mysqlCfg.TLSConfig = cfg.TLS
+	mysqlCfg.Timeout = cfg.ConnectTimeout.Duration
+	// created_at is DATETIME: read it as time.Time and keep the session in UTC
+	// so that it and the from/to filters mean the same instant
+	mysqlCfg.ParseTime = true
+	mysqlCfg.Params = map[string]string{"time_zone": "'+00:00'"}
+
+	var err error
+	db, err = sql.Open("mysql", mysqlCfg.FormatDSN())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	return nil
+}
+
+// openSQLite opens the SQLite database file, or an in-memory database
+func openSQLite(cfg config.Database) error {
+	var err error
+	db, err = repository.OpenSQLite(cfg.SQLitePath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
 	return nil
 }
 
@@ -58,3 +140,14 @@ func CloseDatabase() error {
 func GetDB() *sql.DB {
 	return db
 }
+
+// GetDriver returns the name of the active database driver
+func GetDriver() string {
+	return driver
+}
+
+// SetStore replaces the data backend used by the WebSocket handlers. Changes
+// committed through it are pushed to subscribed clients.
+func SetStore(s repository.Store) {
+	store = repository.NewNotifyingStore(s, events)
+}
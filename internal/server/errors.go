@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"example/data-access/internal/constants"
+	"example/data-access/internal/models"
+	"example/data-access/internal/repository"
+)
+
+// errorResponse builds a failed response with a machine-readable error
+func errorResponse(code, message string, fields []string, details map[string]interface{}) models.WSResponse {
+	return models.WSResponse{Success: false, Error: &models.WSError{Code: code, Message: message, Fields: fields, Details: details}}
+}
+
+// validationError reports a request field that is invalid or missing. value
+// is the rejected input, or nil when there is nothing useful to echo back.
+func validationError(message, field string, value interface{}) models.WSResponse {
+	var details map[string]interface{}
+	if value != nil {
+		details = map[string]interface{}{"value": value}
+	}
+	return errorResponse(constants.CodeValidationFailed, message, []string{field}, details)
+}
+
+// storeError turns a store error into a response that is safe to send to
+// clients; the full error is only logged
+func storeError(err error) models.WSResponse {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return errorResponse(constants.CodeNotFound, constants.ErrRecordNotFound, nil, nil)
+	case errors.Is(err, repository.ErrInsufficientStock):
+		return errorResponse(constants.CodeInsufficientStock, constants.ErrInsufficientStock, nil, nil)
+	case errors.Is(err, repository.ErrDuplicate):
+		return errorResponse(constants.CodeAlreadyExists, constants.ErrDuplicateRecord, nil, nil)
+	case errors.Is(err, repository.ErrInvalidReference):
+		return errorResponse(constants.CodeInvalidReference, constants.ErrInvalidReference, nil, nil)
+	case errors.Is(err, repository.ErrInUse):
+		return errorResponse(constants.CodeInUse, constants.ErrRecordInUse, nil, nil)
+	case errors.Is(err, repository.ErrNotCancellable):
+		return errorResponse(constants.CodeNotCancellable, constants.ErrNotCancellable, []string{constants.JSONFieldQuantity}, nil)
+	case errors.Is(err, context.DeadlineExceeded):
+		return errorResponse(constants.CodeTimeout, constants.ErrRequestTimedOut, nil, nil)
+	default:
+		return errorResponse(constants.CodeInternal, constants.ErrInternal, nil, nil)
+	}
+}
+
+// purchaseError is storeError for addPurchase, naming the request field each
+// failure refers to
+func purchaseError(err error) models.WSResponse {
+	response := storeError(err)
+	switch response.Error.Code {
+	case constants.CodeNotFound:
+		response.Error.Fields = []string{constants.JSONFieldAlbumID}
+	case constants.CodeInsufficientStock:
+		response.Error.Fields = []string{constants.JSONFieldQuantity}
+	case constants.CodeInvalidReference:
+		response.Error.Fields = []string{constants.JSONFieldUserID}
+	}
+	return response
+}
+
+// orderError is storeError for placeOrder. A failing line is named in fields
+// as lines[i].album_id or lines[i].quantity, and details give its index and
+// album ID.
+func orderError(err error) models.WSResponse {
+	response := storeError(err)
+
+	var lineErr *repository.OrderLineError
+	if !errors.As(err, &lineErr) {
+		if response.Error.Code == constants.CodeInvalidReference {
+			response.Error.Fields = []string{constants.JSONFieldUserID}
+		}
+		return response
+	}
+
+	field := constants.JSONFieldAlbumID
+	if response.Error.Code == constants.CodeInsufficientStock {
+		field = constants.JSONFieldQuantity
+	}
+	response.Error.Fields = []string{orderLineField(lineErr.Line, field)}
+	response.Error.Details = map[string]interface{}{"line": lineErr.Line, constants.JSONFieldAlbumID: lineErr.AlbumID}
+	return response
+}
+
+// orderLineField names a field of one order line, e.g. lines[2].quantity
+func orderLineField(line int, field string) string {
+	return fmt.Sprintf("%s[%d].%s", constants.JSONFieldLines, line, field)
+}
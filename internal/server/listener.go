@@ -0,0 +1,153 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"example/data-access/internal/config"
+	"example/data-access/internal/logger"
+)
+
+// Listen opens the configured TCP address or Unix socket, wrapped in TLS when enabled
+func Listen(cfg config.Server) (net.Listener, error) {
+	var ln net.Listener
+	var err error
+
+	if cfg.UnixSocket != "" {
+		if err := removeStaleSocket(cfg.UnixSocket); err != nil {
+			return nil, err
+		}
+		ln, err = net.Listen("unix", cfg.UnixSocket)
+	} else {
+		ln, err = net.Listen("tcp", cfg.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listen: %v", err)
+	}
+
+	if !cfg.TLSEnabled() {
+		return ln, nil
+	}
+
+	tlsConfig, err := loadTLSConfig(cfg)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return tls.NewListener(ln, tlsConfig), nil
+}
+
+// removeStaleSocket removes a socket file left behind by a previous run, which
+// would make Listen fail. Anything else at path, or a socket another process
+// still accepts connections on, is left alone and reported as an error.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("check unix socket %s: %v", path, err)
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("unix socket path %s exists and is not a socket", path)
+	}
+
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("unix socket %s is in use by another process", path)
+	}
+
+	logger.Log.Infow("Removing stale unix socket", "path", path)
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove stale unix socket %s: %v", path, err)
+	}
+	return nil
+}
+
+// Endpoint returns the WebSocket URL clients should connect to
+func Endpoint(cfg config.Server) string {
+	scheme := "ws"
+	if cfg.TLSEnabled() {
+		scheme = "wss"
+	}
+
+	if cfg.UnixSocket != "" {
+		return fmt.Sprintf("%s+unix://%s:/ws", scheme, cfg.UnixSocket)
+	}
+
+	host, port, err := net.SplitHostPort(cfg.Addr)
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	return fmt.Sprintf("%s://%s/ws", scheme, net.JoinHostPort(host, port))
+}
+
+// loadTLSConfig reads the configured key pair or generates a self-signed one
+func loadTLSConfig(cfg config.Server) (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+
+	if cfg.TLSSelfSigned {
+		logger.Log.Warn("Serving TLS with a self-signed certificate; do not use in production")
+		cert, err = selfSignedCertificate()
+	} else {
+		cert, err = tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load tls certificate: %v", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// selfSignedCertificate generates a short-lived certificate for localhost
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	dnsNames := []string{"localhost"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		dnsNames = append(dnsNames, hostname)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"data-access development"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(30 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              dnsNames,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
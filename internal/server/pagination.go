@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+
+	"example/data-access/internal/constants"
+	"example/data-access/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// parsePageRequest reads the optional limit and cursor of a list action. No
+// data, or an object without them, asks for the first page of DefaultPageLimit.
+func parsePageRequest(action string, data interface{}, log *zap.SugaredLogger) (models.PageRequest, models.WSResponse, bool) {
+	page := models.PageRequest{Limit: constants.DefaultPageLimit}
+	if data == nil {
+		return page, models.WSResponse{}, true
+	}
+
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		log.Warnw(constants.LogInvalidRequest, "action", action, "error", "page data not an object")
+		return page, validationError(constants.ErrInvalidPageData, "data", nil), false
+	}
+
+	limit, response, ok := parsePageLimit(action, dataMap, log)
+	if !ok {
+		return page, response, false
+	}
+	page.Limit = limit
+
+	if raw, present := dataMap[constants.JSONFieldCursor]; present {
+		cursor, ok := raw.(string)
+		after, valid := decodeCursor(cursor)
+		if !ok || !valid {
+			log.Warnw(constants.LogInvalidRequest, "action", action, "error", "invalid cursor", "cursor", raw)
+			return page, validationError(constants.ErrInvalidCursor, constants.JSONFieldCursor, raw), false
+		}
+		page.After = after
+	}
+
+	return page, models.WSResponse{}, true
+}
+
+// parsePageLimit reads the optional limit of a list action, defaulting to DefaultPageLimit
+func parsePageLimit(action string, dataMap map[string]interface{}, log *zap.SugaredLogger) (int, models.WSResponse, bool) {
+	raw, present := dataMap[constants.JSONFieldLimit]
+	if !present {
+		return constants.DefaultPageLimit, models.WSResponse{}, true
+	}
+
+	limit, ok := raw.(float64)
+	if !ok || limit != float64(int(limit)) || limit < 1 || limit > constants.MaxPageLimit {
+		log.Warnw(constants.LogInvalidRequest, "action", action, "error", "invalid limit", "limit", raw)
+		return 0, validationError(constants.ErrInvalidPageLimit, constants.JSONFieldLimit, raw), false
+	}
+	return int(limit), models.WSResponse{}, true
+}
+
+// newPage builds the response page for items. When more follow, cursor
+// makes the next cursor from the last item.
+func newPage[T any](items []T, hasMore bool, cursor func(last T) string) models.Page[T] {
+	if items == nil {
+		items = []T{}
+	}
+	page := models.Page[T]{Items: items, HasMore: hasMore}
+	if hasMore && len(items) > 0 {
+		page.NextCursor = cursor(items[len(items)-1])
+	}
+	return page
+}
+
+// encodeCursor makes the opaque cursor for the page after the record with ID id
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// decodeCursor returns the record ID in cursor, and false if it is not one of ours
+func decodeCursor(cursor string) (int64, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// searchCursor is the position after the last album of a searchAlbums page.
+// It records the sort it was made for, so it cannot be reused with another.
+type searchCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	Key  string `json:"k,omitempty"` // the sort field's value; empty when sorting by ID
+	ID   int64  `json:"i"`
+}
+
+// encodeSearchCursor makes the cursor for the searchAlbums page after last
+func encodeSearchCursor(last models.Album, search models.AlbumSearch) string {
+	c := searchCursor{Sort: search.SortBy, Desc: search.Descending, ID: last.ID}
+	switch search.SortBy {
+	case constants.SortByTitle:
+		c.Key = last.Title
+	case constants.SortByArtist:
+		c.Key = last.Artist
+	case constants.SortByPrice:
+		c.Key = last.Price.String()
+	case constants.SortByStock:
+		c.Key = strconv.Itoa(last.Stock)
+	}
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeSearchCursor returns the album a searchAlbums cursor points after,
+// and false if the cursor is malformed or was made for a different sort
+func decodeSearchCursor(cursor string, search models.AlbumSearch) (*models.Album, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, false
+	}
+	var c searchCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID <= 0 || c.Sort != search.SortBy || c.Desc != search.Descending {
+		return nil, false
+	}
+
+	after := &models.Album{ID: c.ID}
+	switch c.Sort {
+	case constants.SortByTitle:
+		after.Title = c.Key
+	case constants.SortByArtist:
+		after.Artist = c.Key
+	case constants.SortByPrice:
+		if after.Price, err = models.ParseMoney(c.Key); err != nil {
+			return nil, false
+		}
+	case constants.SortByStock:
+		if after.Stock, err = strconv.Atoi(c.Key); err != nil {
+			return nil, false
+		}
+	}
+	return after, true
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+
+	"example/data-access/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// requestLogger returns the logger for one message, tagged with the client
+// address and, when the client sent one, the request ID
+func requestLogger(clientAddr string, id json.RawMessage) *zap.SugaredLogger {
+	log := logger.Log.With("remote_addr", clientAddr)
+	if len(id) > 0 {
+		log = log.With("request_id", requestIDString(id))
+	}
+	return log
+}
+
+// validRequestID reports whether id is absent, a JSON string or a JSON number
+func validRequestID(id json.RawMessage) bool {
+	if len(id) == 0 {
+		return true
+	}
+	var v interface{}
+	if err := json.Unmarshal(id, &v); err != nil {
+		return false
+	}
+	switch v.(type) {
+	case string, float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// requestIDString returns id for logging, without the quotes of a JSON string
+func requestIDString(id json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(id, &s); err == nil {
+		return s
+	}
+	return string(id)
+}
+
+// frameRequestID extracts the request ID from a frame that could not be
+// handled as a message, so even those errors can be matched by the client
+func frameRequestID(p []byte) json.RawMessage {
+	var frame struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(p, &frame); err != nil || !validRequestID(frame.ID) {
+		return nil
+	}
+	return frame.ID
+}
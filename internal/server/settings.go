@@ -0,0 +1,38 @@
+package server
+
+import (
+	"time"
+
+	"example/data-access/internal/config"
+	"example/data-access/internal/constants"
+)
+
+var (
+	// actionTimeouts holds the configured per-action timeout overrides
+	actionTimeouts map[string]time.Duration
+
+	// maxConcurrentMessages bounds the worker pool of each connection
+	maxConcurrentMessages = constants.DefaultMaxConcurrentMessages
+
+	// maxBatchSize and batchConcurrency limit batches
+	maxBatchSize     = constants.DefaultMaxBatchSize
+	batchConcurrency = constants.DefaultBatchConcurrency
+)
+
+// Configure applies the request handling settings from the server configuration
+func Configure(cfg config.Server) {
+	actionTimeouts = make(map[string]time.Duration, len(cfg.ActionTimeouts))
+	for action, timeout := range cfg.ActionTimeouts {
+		actionTimeouts[action] = timeout.Duration
+	}
+
+	if cfg.MaxConcurrentMessages > 0 {
+		maxConcurrentMessages = cfg.MaxConcurrentMessages
+	}
+	if cfg.MaxBatchSize > 0 {
+		maxBatchSize = cfg.MaxBatchSize
+	}
+	if cfg.BatchConcurrency > 0 {
+		batchConcurrency = cfg.BatchConcurrency
+	}
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"example/data-access/internal/constants"
+	"example/data-access/internal/logger"
+	"example/data-access/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// client is one upgraded WebSocket connection. gorilla/websocket allows only
+// one concurrent writer, so responses are queued on out, and subscribed
+// events on events, for the connection's writer goroutine, which is the only
+// one writing to conn. closing asks the writer to send the close frame.
+// ctx is cancelled when the client disconnects or shutdown gives up waiting,
+// and carries the client for the subscription actions.
+type client struct {
+	conn *websocket.Conn
+	addr string
+
+	out        chan outbound
+	events     chan models.AlbumEvent
+	closing    chan struct{}
+	closeOnce  sync.Once
+	writerDone chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// writeJSON sends v as a single JSON text frame
+func (c *client) writeJSON(v interface{}) error {
+	return c.conn.WriteJSON(v)
+}
+
+// closeGoingAway asks the writer to send a 1001 close frame telling the
+// client the server is going away, after the responses already queued
+func (c *client) closeGoingAway() {
+	c.closeOnce.Do(func() { close(c.closing) })
+}
+
+// writeGoingAway sends the 1001 close frame
+func (c *client) writeGoingAway() error {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, constants.ErrServerShuttingDown)
+	return c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(constants.CloseHandshakeTimeout))
+}
+
+// Server serves WebSocket connections and tracks them so Shutdown can drain
+// and close them. Once shut down it refuses new connections and messages for
+// good; serve a new listener with a new Server.
+type Server struct {
+	mu           sync.Mutex
+	clients      map[*client]struct{}
+	shuttingDown bool
+
+	// handlers counts running HandleWebSocket loops, inFlight counts messages being processed
+	handlers sync.WaitGroup
+	inFlight sync.WaitGroup
+}
+
+// NewServer returns a Server with no connections
+func NewServer() *Server {
+	return &Server{clients: make(map[*client]struct{})}
+}
+
+// registerClient tracks a new connection; it fails once shutdown has started
+func (s *Server) registerClient(conn *websocket.Conn) (*client, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shuttingDown {
+		return nil, false
+	}
+
+	c := &client{
+		conn:       conn,
+		addr:       conn.RemoteAddr().String(),
+		out:        make(chan outbound, maxConcurrentMessages),
+		events:     make(chan models.AlbumEvent, constants.EventQueueSize),
+		closing:    make(chan struct{}),
+		writerDone: make(chan struct{}),
+	}
+	c.ctx, c.cancel = context.WithCancel(context.WithValue(context.Background(), clientKey{}, c))
+	s.clients[c] = struct{}{}
+	s.handlers.Add(1)
+	return c, true
+}
+
+// unregisterClient stops tracking a connection whose handler is exiting
+func (s *Server) unregisterClient(c *client) {
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.mu.Unlock()
+	s.handlers.Done()
+}
+
+// isShuttingDown reports whether Shutdown has been called
+func (s *Server) isShuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shuttingDown
+}
+
+// beginRequest marks a message as in flight; it fails once shutdown has started
+func (s *Server) beginRequest() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shuttingDown {
+		return false
+	}
+	s.inFlight.Add(1)
+	return true
+}
+
+// endRequest marks an in-flight message as finished
+func (s *Server) endRequest() {
+	s.inFlight.Done()
+}
+
+// Shutdown stops accepting WebSocket upgrades and new messages, waits until
+// ctx expires for in-flight messages to finish (cancelling their database
+// work once it does) and then closes every connection with 1001 (going
+// away). Event delivery stops first, and each connection's writer sends the
+// close frame as its last write, after the responses already queued, since
+// no data frames may follow it. Connections that do not complete the close
+// handshake are closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shuttingDown = true
+	count := len(s.clients)
+	s.mu.Unlock()
+
+	logger.Log.Infow("Shutting down WebSocket server", "clients", count)
+
+	var err error
+	drained := waitOrDone(ctx, &s.inFlight)
+
+	s.mu.Lock()
+	open := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		open = append(open, c)
+	}
+	s.mu.Unlock()
+
+	// Cancel database work that outlived the grace period
+	if !drained {
+		err = fmt.Errorf("grace period expired with requests in flight: %w", ctx.Err())
+		logger.Log.Warnw("Grace period expired, cancelling requests in flight", "error", ctx.Err())
+		for _, c := range open {
+			c.cancel()
+		}
+		s.inFlight.Wait()
+	}
+
+	for _, c := range open {
+		events.remove(c)
+		c.closeGoingAway()
+	}
+
+	// Give clients a moment to answer the close frame, then force the rest closed
+	closeCtx, cancel := context.WithTimeout(context.Background(), constants.CloseHandshakeTimeout)
+	defer cancel()
+	if !waitOrDone(closeCtx, &s.handlers) {
+		s.mu.Lock()
+		for c := range s.clients {
+			c.conn.Close()
+		}
+		s.mu.Unlock()
+		s.handlers.Wait()
+	}
+
+	logger.Log.Infow("WebSocket server stopped", "clients_closed", len(open))
+	return err
+}
+
+// waitOrDone waits for wg and reports false if ctx finished first
+func waitOrDone(ctx context.Context, wg *sync.WaitGroup) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"example/data-access/internal/constants"
+	"example/data-access/internal/logger"
+	"example/data-access/internal/models"
+)
+
+// subscription is what one client receives events for
+type subscription struct {
+	catalog bool
+	albums  map[int64]bool
+}
+
+// eventHub routes committed album events to the clients subscribed to them.
+// It is the Publisher of the store installed by InitDatabase and SetStore.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[*client]*subscription
+}
+
+// events is the process-wide hub
+var events = &eventHub{subs: make(map[*client]*subscription)}
+
+// Publish queues event for every client subscribed to its album or the whole
+// catalog. albumAdded only reaches catalog subscribers, since nobody can
+// subscribe to an album before it exists. It never blocks: a client whose
+// queue is full misses the event.
+func (h *eventHub) Publish(event models.AlbumEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c, sub := range h.subs {
+		if !sub.catalog && !sub.albums[event.AlbumID] {
+			continue
+		}
+		select {
+		case c.events <- event:
+		default:
+			logger.Log.Warnw(constants.LogEventDropped, "event", event.Event, "album_id", event.AlbumID, "remote_addr", c.addr)
+		}
+	}
+}
+
+// subscribe adds albumIDs, and the whole catalog if catalog is set, to what c
+// receives. It fails if c would then follow more than MaxSubscribedAlbums albums.
+func (h *eventHub) subscribe(c *client, albumIDs []int64, catalog bool) (models.Subscription, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := h.subs[c]
+	if sub == nil {
+		sub = &subscription{albums: make(map[int64]bool)}
+	}
+
+	added := 0
+	for _, id := range albumIDs {
+		if !sub.albums[id] {
+			added++
+		}
+	}
+	if len(sub.albums)+added > constants.MaxSubscribedAlbums {
+		return sub.snapshot(), false
+	}
+
+	for _, id := range albumIDs {
+		sub.albums[id] = true
+	}
+	sub.catalog = sub.catalog || catalog
+	h.subs[c] = sub
+	return sub.snapshot(), true
+}
+
+// unsubscribe removes albumIDs, and the whole catalog if catalog is set, from
+// what c receives
+func (h *eventHub) unsubscribe(c *client, albumIDs []int64, catalog bool) models.Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub := h.subs[c]
+	if sub == nil {
+		return models.Subscription{AlbumIDs: []int64{}}
+	}
+
+	for _, id := range albumIDs {
+		delete(sub.albums, id)
+	}
+	if catalog {
+		sub.catalog = false
+	}
+	if !sub.catalog && len(sub.albums) == 0 {
+		delete(h.subs, c)
+	}
+	return sub.snapshot()
+}
+
+// remove drops every subscription of c. Once it returns no more events are
+// queued for c, so its connection can shut down.
+func (h *eventHub) remove(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, c)
+}
+
+// snapshot returns sub with the album IDs in ascending order
+func (sub *subscription) snapshot() models.Subscription {
+	ids := make([]int64, 0, len(sub.albums))
+	for id := range sub.albums {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return models.Subscription{AlbumIDs: ids, Catalog: sub.catalog}
+}
+
+// clientKey is the context key of the connection a message arrived on
+type clientKey struct{}
+
+// clientFromContext returns the connection a message arrived on, or nil
+func clientFromContext(ctx context.Context) *client {
+	c, _ := ctx.Value(clientKey{}).(*client)
+	return c
+}
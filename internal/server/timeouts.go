@@ -0,0 +1,29 @@
+package server
+
+import (
+	"time"
+
+	"example/data-access/internal/constants"
+)
+
+// defaultActionTimeouts are per-action database timeouts for actions that
+// need longer than constants.DBTimeout
+var defaultActionTimeouts = map[string]time.Duration{
+	constants.ActionGetUserPurchaseSummary:     10 * time.Second,
+	constants.ActionGetAllUsersPurchaseSummary: 30 * time.Second,
+	constants.ActionGetTopSellingAlbums:        30 * time.Second,
+	constants.ActionGetRevenueByArtist:         30 * time.Second,
+	constants.ActionGetSalesByPeriod:           30 * time.Second,
+	constants.ActionGetAverageOrderValue:       30 * time.Second,
+}
+
+// actionTimeout returns how long a single message with the given action may run
+func actionTimeout(action string) time.Duration {
+	if timeout, ok := actionTimeouts[action]; ok {
+		return timeout
+	}
+	if timeout, ok := defaultActionTimeouts[action]; ok {
+		return timeout
+	}
+	return constants.DBTimeout
+}
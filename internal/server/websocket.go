@@ -1,9 +1,15 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"example/data-access/internal/constants"
 	"example/data-access/internal/logger"
@@ -11,6 +17,7 @@ import (
 	"example/data-access/internal/repository"
 
 	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
 )
 
 var upgrader = websocket.Upgrader{
@@ -22,7 +29,13 @@ var upgrader = websocket.Upgrader{
 }
 
 // HandleWebSocket handles incoming WebSocket connections
-func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if s.isShuttingDown() {
+		logger.Log.Infow("Rejecting WebSocket upgrade during shutdown", "remote_addr", r.RemoteAddr)
+		http.Error(w, constants.ErrServerShuttingDown, http.StatusServiceUnavailable)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		logger.Log.Errorw("WebSocket upgrade error", "error", err, "remote_addr", r.RemoteAddr)
@@ -30,327 +43,935 @@ func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	clientAddr := conn.RemoteAddr().String()
-	logger.Log.Infow("Client connected", "remote_addr", clientAddr)
+	c, ok := s.registerClient(conn)
+	if !ok {
+		msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, constants.ErrServerShuttingDown)
+		conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(constants.CloseHandshakeTimeout))
+		return
+	}
+	defer s.unregisterClient(c)
 
-	for {
-		_, p, err := conn.ReadMessage()
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				logger.Log.Warnw("WebSocket error", "error", err, "remote_addr", clientAddr)
-			}
-			break
-		}
+	defer c.cancel()
+
+	clientAddr := c.addr
+	logger.Log.Infow("Client connected", "remote_addr", clientAddr)
 
-		// Try to unmarshal as an array (batch) of messages first
-		var batch []models.WSMessage
-		if err := json.Unmarshal(p, &batch); err == nil && len(batch) > 0 {
-			var responses []models.WSResponse
-			for _, m := range batch {
-				responses = append(responses, handleMessage(m, clientAddr))
+	// Read frames on a separate goroutine so a disconnect is noticed, and the
+	// connection context cancelled, while a message is still being processed
+	frames := make(chan []byte)
+	go func() {
+		defer close(frames)
+		defer c.cancel()
+		for {
+			_, p, err := conn.ReadMessage()
+			if err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+					logger.Log.Warnw("WebSocket error", "error", err, "remote_addr", clientAddr)
+				}
+				return
 			}
-			if err := conn.WriteJSON(responses); err != nil {
-				logger.Log.Errorw("Write error", "error", err, "remote_addr", clientAddr)
-				break
+			select {
+			case frames <- p:
+			case <-c.ctx.Done():
+				return
 			}
-			continue
 		}
+	}()
 
-		// Otherwise, try single message
-		var msg models.WSMessage
-		if err := json.Unmarshal(p, &msg); err != nil {
-			logger.Log.Warnw("Invalid message format", "remote_addr", clientAddr, "error", err)
-			response := models.WSResponse{Success: false, Error: constants.ErrInvalidMessageFormat}
-			if err := conn.WriteJSON(response); err != nil {
-				logger.Log.Errorw("Write error", "error", err, "remote_addr", clientAddr)
-				break
-			}
+	go c.writeLoop()
+
+	// Process up to maxConcurrentMessages messages at once; responses are
+	// written as they finish, so clients match them by request ID
+	var workers sync.WaitGroup
+	slots := make(chan struct{}, maxConcurrentMessages)
+	for p := range frames {
+		if !s.beginRequest() {
+			logger.Log.Infow("Rejecting message during shutdown", "remote_addr", clientAddr)
+			response := errorResponse(constants.CodeServerShuttingDown, constants.ErrServerShuttingDown, nil, nil)
+			response.ID = frameRequestID(p)
+			c.send(response, nil)
 			continue
 		}
 
-		response := handleMessage(msg, clientAddr)
-		if err := conn.WriteJSON(response); err != nil {
-			logger.Log.Errorw("Write error", "error", err, "remote_addr", clientAddr)
-			break
-		}
+		slots <- struct{}{}
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			defer func() { <-slots }()
+			// The request stays in flight until its response is written, so
+			// shutdown cannot send the close frame ahead of it
+			c.send(processFrame(c, p), s.endRequest)
+		}()
 	}
 
+	workers.Wait()
+	events.remove(c)
+	close(c.out)
+	<-c.writerDone
+
 	logger.Log.Infow("Client disconnected", "remote_addr", clientAddr)
 }
 
-// handleMessage processes a single WSMessage and returns a WSResponse
-func handleMessage(msg models.WSMessage, clientAddr string) models.WSResponse {
+// processFrame handles one text frame holding a single message or a batch
+// and returns the response to write
+func processFrame(c *client, p []byte) interface{} {
+	clientAddr := c.addr
+
+	// Try to unmarshal as an array (batch) of messages first
+	var batch []models.WSMessage
+	if err := json.Unmarshal(p, &batch); err == nil && len(batch) > 0 {
+		if response, ok := checkBatchSize(len(batch), clientAddr); !ok {
+			return response
+		}
+		return handleBatch(c.ctx, batch, clientAddr)
+	}
+
+	// Then as a batch envelope
+	var envelope models.WSBatch
+	if err := json.Unmarshal(p, &envelope); err == nil && envelope.Messages != nil {
+		return handleBatchEnvelope(c.ctx, envelope, clientAddr)
+	}
+
+	// Otherwise, try single message
+	var msg models.WSMessage
+	if err := json.Unmarshal(p, &msg); err != nil {
+		logger.Log.Warnw("Invalid message format", "remote_addr", clientAddr, "error", err)
+		response := errorResponse(constants.CodeInvalidMessage, constants.ErrInvalidMessageFormat, nil, map[string]interface{}{"reason": err.Error()})
+		response.ID = frameRequestID(p)
+		return response
+	}
+
+	return handleMessage(c.ctx, store, msg, clientAddr)
+}
+
+// handleMessage processes a single WSMessage against st and returns a
+// WSResponse, under a timeout for the action derived from the connection context
+func handleMessage(ctx context.Context, st repository.Store, msg models.WSMessage, clientAddr string) models.WSResponse {
 	startTime := time.Now()
-	logger.Log.Debugw("Processing action", "action", msg.Action, "remote_addr", clientAddr)
+	if !validRequestID(msg.ID) {
+		logger.Log.Warnw(constants.LogInvalidRequest, "action", msg.Action, "error", "invalid request ID", "remote_addr", clientAddr)
+		return validationError(constants.ErrInvalidRequestID, constants.JSONFieldRequestID, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, actionTimeout(msg.Action))
+	defer cancel()
+
+	// Every log line for this message, including the repository's, carries
+	// the client address and request ID
+	log := requestLogger(clientAddr, msg.ID)
+	ctx = logger.WithContext(ctx, log)
+	log.Debugw("Processing action", "action", msg.Action)
 
 	var response models.WSResponse
 	switch msg.Action {
 	case constants.ActionGetAlbums:
-		response = handleGetAlbums(startTime, clientAddr)
+		response = handleGetAlbums(ctx, st, msg.Data, startTime, log)
 	case constants.ActionGetAlbumByArtist:
-		response = handleGetAlbumByArtist(msg.Data, startTime, clientAddr)
+		response = handleGetAlbumByArtist(ctx, st, msg.Data, startTime, log)
+	case constants.ActionSearchAlbums:
+		response = handleSearchAlbums(ctx, st, msg.Data, startTime, log)
+	case constants.ActionSearchCatalog:
+		response = handleSearchCatalog(ctx, st, msg.Data, startTime, log)
 	case constants.ActionGetAlbumByID:
-		response = handleGetAlbumByID(msg.Data, startTime, clientAddr)
+		response = handleGetAlbumByID(ctx, st, msg.Data, startTime, log)
 	case constants.ActionAddAlbum:
-		response = handleAddAlbum(msg.Data, startTime, clientAddr)
+		response = handleAddAlbum(ctx, st, msg.Data, startTime, log)
+	case constants.ActionUpdateAlbum:
+		response = handleUpdateAlbum(ctx, st, msg.Data, startTime, log)
+	case constants.ActionRestockAlbum:
+		response = handleRestockAlbum(ctx, st, msg.Data, startTime, log)
+	case constants.ActionDeleteAlbum:
+		response = handleDeleteAlbum(ctx, st, msg.Data, startTime, log)
 	case constants.ActionGetUsers:
-		response = handleGetUsers(startTime, clientAddr)
+		response = handleGetUsers(ctx, st, msg.Data, startTime, log)
 	case constants.ActionGetUserByID:
-		response = handleGetUserByID(msg.Data, startTime, clientAddr)
+		response = handleGetUserByID(ctx, st, msg.Data, startTime, log)
 	case constants.ActionAddUser:
-		response = handleAddUser(msg.Data, startTime, clientAddr)
+		response = handleAddUser(ctx, st, msg.Data, startTime, log)
+	case constants.ActionUpdateUser:
+		response = handleUpdateUser(ctx, st, msg.Data, startTime, log)
+	case constants.ActionDeleteUser:
+		response = handleDeleteUser(ctx, st, msg.Data, startTime, log)
 	case constants.ActionGetPurchases:
-		response = handleGetPurchases(startTime, clientAddr)
+		response = handleGetPurchases(ctx, st, msg.Data, startTime, log)
 	case constants.ActionGetPurchasesByUserID:
-		response = handleGetPurchasesByUserID(msg.Data, startTime, clientAddr)
+		response = handleGetPurchasesByUserID(ctx, st, msg.Data, startTime, log)
 	case constants.ActionAddPurchase:
-		response = handleAddPurchase(msg.Data, startTime, clientAddr)
+		response = handleAddPurchase(ctx, st, msg.Data, startTime, log)
+	case constants.ActionCancelPurchase:
+		response = handleCancelPurchase(ctx, st, msg.Data, startTime, log)
+	case constants.ActionPlaceOrder:
+		response = handlePlaceOrder(ctx, st, msg.Data, startTime, log)
 	case constants.ActionGetUserPurchaseSummary:
-		response = handleGetUserPurchaseSummary(msg.Data, startTime, clientAddr)
+		response = handleGetUserPurchaseSummary(ctx, st, msg.Data, startTime, log)
 	case constants.ActionGetAllUsersPurchaseSummary:
-		response = handleGetAllUsersPurchaseSummary(startTime, clientAddr)
+		response = handleGetAllUsersPurchaseSummary(ctx, st, msg.Data, startTime, log)
+	case constants.ActionSubscribe:
+		response = handleSubscribe(ctx, msg.Data, startTime, log)
+	case constants.ActionUnsubscribe:
+		response = handleUnsubscribe(ctx, msg.Data, startTime, log)
+	case constants.ActionGetTopSellingAlbums:
+		response = handleGetTopSellingAlbums(ctx, st, msg.Data, startTime, log)
+	case constants.ActionGetRevenueByArtist:
+		response = handleGetRevenueByArtist(ctx, st, msg.Data, startTime, log)
+	case constants.ActionGetSalesByPeriod:
+		response = handleGetSalesByPeriod(ctx, st, msg.Data, startTime, log)
+	case constants.ActionGetAverageOrderValue:
+		response = handleGetAverageOrderValue(ctx, st, msg.Data, startTime, log)
 	default:
-		response = models.WSResponse{Success: false, Error: constants.ErrUnknownAction}
+		response = errorResponse(constants.CodeUnknownAction, constants.ErrUnknownAction, []string{"action"}, map[string]interface{}{"action": msg.Action})
 		duration := time.Since(startTime)
-		logger.Log.Warnw(constants.LogUnknownAction, "action", msg.Action, "duration_ms", duration.Milliseconds(), "remote_addr", clientAddr)
+		log.Warnw(constants.LogUnknownAction, "action", msg.Action, "duration_ms", duration.Milliseconds())
 	}
+	response.ID = msg.ID
 	return response
 }
 
-// handleGetAlbums retrieves all albums from the database
-func handleGetAlbums(startTime time.Time, clientAddr string) models.WSResponse {
-	albums, err := repository.GetAllAlbums(db)
+// handleGetAlbums retrieves one page of albums from the database
+func handleGetAlbums(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	page, response, ok := parsePageRequest(constants.ActionGetAlbums, data, log)
+	if !ok {
+		return response
+	}
+
+	albums, hasMore, err := st.ListAlbums(ctx, page)
 	if err != nil {
-		logger.Log.Errorw(constants.LogFailedToGetAlbums, "error", err, "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: err.Error()}
+		log.Errorw(constants.LogFailedToGetAlbums, "error", err)
+		return storeError(err)
 	}
 
 	duration := time.Since(startTime)
-	logger.Log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetAlbums, "duration_ms", duration.Milliseconds(), "album_count", len(albums), "remote_addr", clientAddr)
-	return models.WSResponse{Success: true, Data: albums}
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetAlbums, "duration_ms", duration.Milliseconds(), "album_count", len(albums), "has_more", hasMore)
+	return models.WSResponse{Success: true, Data: newPage(albums, hasMore, func(last models.Album) string { return encodeCursor(last.ID) })}
 }
 
-// handleGetAlbumByArtist retrieves albums by a specific artist
-func handleGetAlbumByArtist(data interface{}, startTime time.Time, clientAddr string) models.WSResponse {
-	artistName, ok := data.(string)
+// handleGetAlbumByArtist retrieves one page of the albums by a specific
+// artist. data is the artist name, or an object with artist and the optional
+// limit and cursor.
+func handleGetAlbumByArtist(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	page := models.PageRequest{Limit: constants.DefaultPageLimit}
+	rawName, field := data, "data"
+	if dataMap, isMap := data.(map[string]interface{}); isMap {
+		var response models.WSResponse
+		var ok bool
+		if page, response, ok = parsePageRequest(constants.ActionGetAlbumByArtist, dataMap, log); !ok {
+			return response
+		}
+		rawName, field = dataMap[constants.JSONFieldArtist], constants.JSONFieldArtist
+	}
+
+	artistName, ok := rawName.(string)
 	if !ok {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetAlbumByArtist, "error", "artist name not string", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrArtistNameNotString}
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetAlbumByArtist, "error", "artist name not string")
+		return validationError(constants.ErrArtistNameNotString, field, rawName)
 	}
 
 	if artistName == "" {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetAlbumByArtist, "error", "empty artist name", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrArtistNameEmpty}
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetAlbumByArtist, "error", "empty artist name")
+		return validationError(constants.ErrArtistNameEmpty, field, nil)
+	}
+
+	albums, hasMore, err := st.ListAlbumsByArtist(ctx, artistName, page)
+	if err != nil {
+		log.Errorw(constants.LogFailedToGetAlbumsByArtist, "artist", artistName, "error", err)
+		return storeError(err)
+	}
+
+	duration := time.Since(startTime)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetAlbumByArtist, "duration_ms", duration.Milliseconds(), "artist", artistName, "album_count", len(albums), "has_more", hasMore)
+	return models.WSResponse{Success: true, Data: newPage(albums, hasMore, func(last models.Album) string { return encodeCursor(last.ID) })}
+}
+
+// handleSearchAlbums retrieves one page of albums matching optional title and
+// artist substrings, a price range and in-stock filter, in the requested order
+func handleSearchAlbums(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	dataMap := map[string]interface{}{}
+	if data != nil {
+		var ok bool
+		if dataMap, ok = data.(map[string]interface{}); !ok {
+			log.Warnw(constants.LogInvalidRequest, "action", constants.ActionSearchAlbums, "error", "search data not an object")
+			return validationError(constants.ErrInvalidSearchData, "data", nil)
+		}
+	}
+
+	search, response, ok := parseAlbumSearch(dataMap, log)
+	if !ok {
+		return response
+	}
+
+	albums, hasMore, err := st.SearchAlbums(ctx, search)
+	if err != nil {
+		log.Errorw(constants.LogFailedToSearchAlbums, "error", err)
+		return storeError(err)
+	}
+
+	duration := time.Since(startTime)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionSearchAlbums, "duration_ms", duration.Milliseconds(), "album_count", len(albums), "has_more", hasMore, "sort", search.SortBy)
+	return models.WSResponse{Success: true, Data: newPage(albums, hasMore, func(last models.Album) string { return encodeSearchCursor(last, search) })}
+}
+
+// parseAlbumSearch validates the filters, sort, limit and cursor of a searchAlbums request
+func parseAlbumSearch(dataMap map[string]interface{}, log *zap.SugaredLogger) (models.AlbumSearch, models.WSResponse, bool) {
+	search := models.AlbumSearch{SortBy: constants.SortByID}
+	invalid := func(message, field, reason string) (models.AlbumSearch, models.WSResponse, bool) {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionSearchAlbums, "error", reason, field, dataMap[field])
+		return search, validationError(message, field, dataMap[field]), false
+	}
+
+	if raw, present := dataMap[constants.JSONFieldTitle]; present {
+		title, ok := raw.(string)
+		if !ok {
+			return invalid(constants.ErrInvalidTitleFilter, constants.JSONFieldTitle, "title not string")
+		}
+		search.Title = title
+	}
+
+	if raw, present := dataMap[constants.JSONFieldArtist]; present {
+		artist, ok := raw.(string)
+		if !ok {
+			return invalid(constants.ErrInvalidArtistFilter, constants.JSONFieldArtist, "artist not string")
+		}
+		search.Artist = artist
+	}
+
+	for _, field := range []string{constants.JSONFieldMinPrice, constants.JSONFieldMaxPrice} {
+		raw, present := dataMap[field]
+		if !present {
+			continue
+		}
+		price, ok := raw.(float64)
+		money, exact := models.MoneyFromFloat(price)
+		if !ok || !exact || money < 0 {
+			return invalid(constants.ErrInvalidPriceFilter, field, "invalid price filter")
+		}
+		if field == constants.JSONFieldMinPrice {
+			search.MinPrice = &money
+		} else {
+			search.MaxPrice = &money
+		}
+	}
+	if search.MinPrice != nil && search.MaxPrice != nil && *search.MinPrice > *search.MaxPrice {
+		return invalid(constants.ErrPriceRangeReversed, constants.JSONFieldMinPrice, "price range reversed")
+	}
+
+	if raw, present := dataMap[constants.JSONFieldInStock]; present {
+		inStock, ok := raw.(bool)
+		if !ok {
+			return invalid(constants.ErrInvalidInStock, constants.JSONFieldInStock, "in_stock not boolean")
+		}
+		search.InStockOnly = inStock
+	}
+
+	if raw, present := dataMap[constants.JSONFieldSort]; present {
+		switch raw {
+		case constants.SortByID, constants.SortByTitle, constants.SortByArtist, constants.SortByPrice, constants.SortByStock:
+			search.SortBy = raw.(string)
+		default:
+			return invalid(constants.ErrInvalidSort, constants.JSONFieldSort, "invalid sort")
+		}
 	}
 
-	albums, err := repository.GetAlbumsByArtist(db, artistName)
+	if raw, present := dataMap[constants.JSONFieldOrder]; present {
+		switch raw {
+		case constants.OrderAsc:
+		case constants.OrderDesc:
+			search.Descending = true
+		default:
+			return invalid(constants.ErrInvalidSortOrder, constants.JSONFieldOrder, "invalid order")
+		}
+	}
+
+	limit, response, ok := parsePageLimit(constants.ActionSearchAlbums, dataMap, log)
+	if !ok {
+		return search, response, false
+	}
+	search.Limit = limit
+
+	if raw, present := dataMap[constants.JSONFieldCursor]; present {
+		cursor, ok := raw.(string)
+		after, valid := decodeSearchCursor(cursor, search)
+		if !ok || !valid {
+			return invalid(constants.ErrInvalidCursor, constants.JSONFieldCursor, "invalid cursor")
+		}
+		search.After = after
+	}
+
+	return search, models.WSResponse{}, true
+}
+
+// handleSearchCatalog runs a full-text search over album titles and artists
+// and returns the best matches with highlighted words
+func handleSearchCatalog(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionSearchCatalog, "error", "search data not an object")
+		return validationError(constants.ErrInvalidSearchData, "data", nil)
+	}
+
+	query, ok := dataMap[constants.JSONFieldQuery].(string)
+	if !ok || strings.IndexFunc(query, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionSearchCatalog, "error", "missing or empty query")
+		return validationError(constants.ErrInvalidSearchQuery, constants.JSONFieldQuery, dataMap[constants.JSONFieldQuery])
+	}
+	if len(query) > constants.MaxSearchQueryLength {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionSearchCatalog, "error", "query too long", "length", len(query))
+		return errorResponse(constants.CodeValidationFailed, constants.ErrSearchQueryTooLong, []string{constants.JSONFieldQuery}, map[string]interface{}{"max_length": constants.MaxSearchQueryLength})
+	}
+
+	limit, response, ok := parsePageLimit(constants.ActionSearchCatalog, dataMap, log)
+	if !ok {
+		return response
+	}
+
+	matches, err := st.SearchCatalog(ctx, query, limit)
 	if err != nil {
-		logger.Log.Errorw(constants.LogFailedToGetAlbumsByArtist, "artist", artistName, "error", err, "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: err.Error()}
+		log.Errorw(constants.LogFailedToSearchCatalog, "query", query, "error", err)
+		return storeError(err)
+	}
+	if matches == nil {
+		matches = []models.CatalogMatch{}
 	}
 
 	duration := time.Since(startTime)
-	logger.Log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetAlbumByArtist, "duration_ms", duration.Milliseconds(), "artist", artistName, "album_count", len(albums), "remote_addr", clientAddr)
-	return models.WSResponse{Success: true, Data: albums}
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionSearchCatalog, "duration_ms", duration.Milliseconds(), "query", query, "match_count", len(matches))
+	return models.WSResponse{Success: true, Data: matches}
 }
 
 // handleGetAlbumByID retrieves a specific album by ID
-func handleGetAlbumByID(data interface{}, startTime time.Time, clientAddr string) models.WSResponse {
+func handleGetAlbumByID(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
 	idFloat, ok := data.(float64)
 	if !ok {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetAlbumByID, "error", "album ID not number", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrAlbumIDNotNumber}
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetAlbumByID, "error", "album ID not number")
+		return validationError(constants.ErrAlbumIDNotNumber, "data", data)
 	}
 
 	id := int64(idFloat)
 	if id <= 0 {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetAlbumByID, "album_id", id, "error", "invalid ID", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: "album " + constants.ErrIDMustBePositive}
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetAlbumByID, "album_id", id, "error", "invalid ID")
+		return validationError("album "+constants.ErrIDMustBePositive, "data", id)
 	}
 
-	alb, err := repository.GetAlbumByID(db, id)
+	alb, err := st.GetAlbumByID(ctx, id)
 	if err != nil {
-		logger.Log.Warnw(constants.LogAlbumNotFound, "album_id", id, "error", err, "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: err.Error()}
+		log.Warnw(constants.LogAlbumNotFound, "album_id", id, "error", err)
+		return storeError(err)
 	}
 
 	duration := time.Since(startTime)
-	logger.Log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetAlbumByID, "duration_ms", duration.Milliseconds(), "album_id", id, "remote_addr", clientAddr)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetAlbumByID, "duration_ms", duration.Milliseconds(), "album_id", id)
 	return models.WSResponse{Success: true, Data: alb}
 }
 
 // handleAddAlbum adds a new album to the database
-func handleAddAlbum(data interface{}, startTime time.Time, clientAddr string) models.WSResponse {
+func handleAddAlbum(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
 	dataMap, ok := data.(map[string]interface{})
 	if !ok {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddAlbum, "error", "album data not object", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrInvalidAlbumData}
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddAlbum, "error", "album data not object")
+		return validationError(constants.ErrInvalidAlbumData, "data", data)
 	}
 
-	var newAlbum models.Album
+	fields, response, ok := parseAlbumFields(constants.ActionAddAlbum, dataMap, false, log)
+	if !ok {
+		return response
+	}
+	newAlbum := models.Album{Title: *fields.Title, Artist: *fields.Artist, Price: *fields.Price, Stock: *fields.Stock}
+
+	id, err := st.AddAlbum(ctx, newAlbum)
+	if err != nil {
+		log.Errorw(constants.LogFailedToAddAlbum, "title", newAlbum.Title, "artist", newAlbum.Artist, "error", err)
+		return storeError(err)
+	}
+
+	duration := time.Since(startTime)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionAddAlbum, "duration_ms", duration.Milliseconds(), "album_id", id, "title", newAlbum.Title)
+	return models.WSResponse{Success: true, Data: map[string]interface{}{constants.JSONFieldID: id}}
+}
+
+// parseAlbumFields validates the album fields in dataMap. Every field is
+// required unless partial is set, in which case absent fields stay nil.
+func parseAlbumFields(action string, dataMap map[string]interface{}, partial bool, log *zap.SugaredLogger) (models.AlbumUpdate, models.WSResponse, bool) {
+	var fields models.AlbumUpdate
+	present := func(key string) bool {
+		_, ok := dataMap[key]
+		return ok || !partial
+	}
 
 	// Validate title
-	if title, ok := dataMap[constants.JSONFieldTitle].(string); ok && title != "" {
-		newAlbum.Title = title
-	} else {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddAlbum, "error", "missing or empty title", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrInvalidOrMissingTitle}
+	if present(constants.JSONFieldTitle) {
+		if title, ok := dataMap[constants.JSONFieldTitle].(string); ok && title != "" {
+			fields.Title = &title
+		} else {
+			log.Warnw(constants.LogInvalidRequest, "action", action, "error", "missing or empty title")
+			return fields, validationError(constants.ErrInvalidOrMissingTitle, constants.JSONFieldTitle, dataMap[constants.JSONFieldTitle]), false
+		}
 	}
 
 	// Validate artist
-	if artist, ok := dataMap[constants.JSONFieldArtist].(string); ok && artist != "" {
-		newAlbum.Artist = artist
-	} else {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddAlbum, "error", "missing or empty artist", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrInvalidOrMissingArtist}
+	if present(constants.JSONFieldArtist) {
+		if artist, ok := dataMap[constants.JSONFieldArtist].(string); ok && artist != "" {
+			fields.Artist = &artist
+		} else {
+			log.Warnw(constants.LogInvalidRequest, "action", action, "error", "missing or empty artist")
+			return fields, validationError(constants.ErrInvalidOrMissingArtist, constants.JSONFieldArtist, dataMap[constants.JSONFieldArtist]), false
+		}
 	}
 
 	// Validate price
-	if price, ok := dataMap[constants.JSONFieldPrice].(float64); ok && price > 0 {
-		newAlbum.Price = float32(price)
-	} else {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddAlbum, "price", dataMap[constants.JSONFieldPrice], "error", "invalid price", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrPriceMustBePositive}
+	if present(constants.JSONFieldPrice) {
+		price, ok := dataMap[constants.JSONFieldPrice].(float64)
+		if money, exact := models.MoneyFromFloat(price); ok && exact && money > 0 && money <= models.MaxPrice {
+			fields.Price = &money
+		} else {
+			log.Warnw(constants.LogInvalidRequest, "action", action, "price", dataMap[constants.JSONFieldPrice], "error", "invalid price")
+			return fields, validationError(constants.ErrPriceMustBePositive, constants.JSONFieldPrice, dataMap[constants.JSONFieldPrice]), false
+		}
 	}
 
 	// Validate stock
-	if stock, ok := dataMap[constants.JSONFieldStock].(float64); ok && stock >= 0 {
-		newAlbum.Stock = int(stock)
-	} else {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddAlbum, "stock", dataMap[constants.JSONFieldStock], "error", "invalid stock", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrStockMustBeNonNegative}
+	if present(constants.JSONFieldStock) {
+		if stock, ok := wholeNumber(dataMap[constants.JSONFieldStock], 0); ok {
+			fields.Stock = &stock
+		} else {
+			log.Warnw(constants.LogInvalidRequest, "action", action, "stock", dataMap[constants.JSONFieldStock], "error", "invalid stock")
+			return fields, validationError(constants.ErrStockMustBeNonNegative, constants.JSONFieldStock, dataMap[constants.JSONFieldStock]), false
+		}
+	}
+
+	return fields, models.WSResponse{}, true
+}
+
+// wholeNumber returns value as an int when it is a JSON number with no
+// fraction, at least min and within the INT columns that store counts
+func wholeNumber(value interface{}, min int) (int, bool) {
+	n, ok := value.(float64)
+	if !ok || n != math.Trunc(n) || n < float64(min) || n > math.MaxInt32 {
+		return 0, false
+	}
+	return int(n), true
+}
+
+// recordIDFromData validates the "id" field of an update or delete request object
+func recordIDFromData(action string, dataMap map[string]interface{}, log *zap.SugaredLogger) (int64, models.WSResponse, bool) {
+	if id, ok := dataMap[constants.JSONFieldID].(float64); ok && id > 0 {
+		return int64(id), models.WSResponse{}, true
 	}
+	log.Warnw(constants.LogInvalidRequest, "action", action, "id", dataMap[constants.JSONFieldID], "error", "invalid id")
+	return 0, validationError(constants.ErrInvalidIDField, constants.JSONFieldID, dataMap[constants.JSONFieldID]), false
+}
 
-	id, err := repository.AddAlbum(db, newAlbum)
+// handleUpdateAlbum changes some fields of an album
+func handleUpdateAlbum(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionUpdateAlbum, "error", "album data not object")
+		return validationError(constants.ErrInvalidAlbumData, "data", data)
+	}
+
+	id, response, ok := recordIDFromData(constants.ActionUpdateAlbum, dataMap, log)
+	if !ok {
+		return response
+	}
+
+	update, response, ok := parseAlbumFields(constants.ActionUpdateAlbum, dataMap, true, log)
+	if !ok {
+		return response
+	}
+	if update == (models.AlbumUpdate{}) {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionUpdateAlbum, "album_id", id, "error", "no fields to update")
+		return validationError(constants.ErrNoAlbumFieldsToUpdate, "data", nil)
+	}
+
+	alb, err := st.UpdateAlbum(ctx, id, update)
 	if err != nil {
-		logger.Log.Errorw(constants.LogFailedToAddAlbum, "title", newAlbum.Title, "artist", newAlbum.Artist, "error", err, "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: err.Error()}
+		log.Errorw(constants.LogFailedToUpdateAlbum, "album_id", id, "error", err)
+		return storeError(err)
 	}
 
 	duration := time.Since(startTime)
-	logger.Log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionAddAlbum, "duration_ms", duration.Milliseconds(), "album_id", id, "title", newAlbum.Title, "remote_addr", clientAddr)
-	return models.WSResponse{Success: true, Data: map[string]interface{}{constants.JSONFieldID: id}}
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionUpdateAlbum, "duration_ms", duration.Milliseconds(), "album_id", id)
+	return models.WSResponse{Success: true, Data: alb}
 }
 
-// handleGetUsers retrieves all users from the database
-func handleGetUsers(startTime time.Time, clientAddr string) models.WSResponse {
-	users, err := repository.GetAllUsers(db)
+// handleRestockAlbum adds stock to an album and records why
+func handleRestockAlbum(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionRestockAlbum, "error", "restock data not object")
+		return validationError(constants.ErrInvalidRestockData, "data", data)
+	}
+
+	id, response, ok := recordIDFromData(constants.ActionRestockAlbum, dataMap, log)
+	if !ok {
+		return response
+	}
+
+	// Validate quantity
+	quantity, ok := wholeNumber(dataMap[constants.JSONFieldQuantity], 1)
+	if !ok {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionRestockAlbum, "quantity", dataMap[constants.JSONFieldQuantity], "error", "invalid quantity")
+		return validationError(constants.ErrInvalidQuantityMustBePositive, constants.JSONFieldQuantity, dataMap[constants.JSONFieldQuantity])
+	}
+
+	// Validate reason
+	reason, ok := dataMap[constants.JSONFieldReason].(string)
+	if !ok || reason == "" {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionRestockAlbum, "error", "missing or empty reason")
+		return validationError(constants.ErrInvalidOrMissingReason, constants.JSONFieldReason, dataMap[constants.JSONFieldReason])
+	}
+
+	alb, err := st.RestockAlbum(ctx, id, quantity, reason)
+	if err != nil {
+		log.Errorw(constants.LogFailedToRestockAlbum, "album_id", id, "quantity", quantity, "error", err)
+		return storeError(err)
+	}
+
+	duration := time.Since(startTime)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionRestockAlbum, "duration_ms", duration.Milliseconds(), "album_id", id, "quantity", quantity, "reason", reason, "stock", alb.Stock)
+	return models.WSResponse{Success: true, Data: alb}
+}
+
+// handleDeleteAlbum deletes an album. Albums with purchases are refused
+// unless on_purchases is "soft_delete", which hides them instead.
+func handleDeleteAlbum(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionDeleteAlbum, "error", "delete data not object")
+		return validationError(constants.ErrInvalidDeleteData, "data", data)
+	}
+
+	id, response, ok := recordIDFromData(constants.ActionDeleteAlbum, dataMap, log)
+	if !ok {
+		return response
+	}
+
+	// Validate on_purchases
+	softIfPurchased := false
+	if policy, present := dataMap[constants.JSONFieldOnPurchases]; present {
+		switch policy {
+		case constants.OnPurchasesRefuse:
+		case constants.OnPurchasesSoftDelete:
+			softIfPurchased = true
+		default:
+			log.Warnw(constants.LogInvalidRequest, "action", constants.ActionDeleteAlbum, "on_purchases", policy, "error", "invalid on_purchases")
+			return validationError(constants.ErrInvalidOnPurchases, constants.JSONFieldOnPurchases, policy)
+		}
+	}
+
+	soft, err := st.DeleteAlbum(ctx, id, softIfPurchased)
+	if err != nil {
+		log.Errorw(constants.LogFailedToDeleteAlbum, "album_id", id, "error", err)
+		return storeError(err)
+	}
+
+	deleted := constants.DeletedHard
+	if soft {
+		deleted = constants.DeletedSoft
+	}
+
+	duration := time.Since(startTime)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionDeleteAlbum, "duration_ms", duration.Milliseconds(), "album_id", id, "deleted", deleted)
+	return models.WSResponse{Success: true, Data: map[string]interface{}{constants.JSONFieldID: id, constants.JSONFieldDeleted: deleted}}
+}
+
+// handleGetUsers retrieves one page of users from the database
+func handleGetUsers(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	page, response, ok := parsePageRequest(constants.ActionGetUsers, data, log)
+	if !ok {
+		return response
+	}
+
+	users, hasMore, err := st.ListUsers(ctx, page)
 	if err != nil {
-		logger.Log.Errorw(constants.LogFailedToGetUsers, "error", err, "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: err.Error()}
+		log.Errorw(constants.LogFailedToGetUsers, "error", err)
+		return storeError(err)
 	}
 
 	duration := time.Since(startTime)
-	logger.Log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetUsers, "duration_ms", duration.Milliseconds(), "user_count", len(users), "remote_addr", clientAddr)
-	return models.WSResponse{Success: true, Data: users}
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetUsers, "duration_ms", duration.Milliseconds(), "user_count", len(users), "has_more", hasMore)
+	return models.WSResponse{Success: true, Data: newPage(users, hasMore, func(last models.User) string { return encodeCursor(last.ID) })}
 }
 
 // handleGetUserByID retrieves a specific user by ID
-func handleGetUserByID(data interface{}, startTime time.Time, clientAddr string) models.WSResponse {
+func handleGetUserByID(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
 	idFloat, ok := data.(float64)
 	if !ok {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetUserByID, "error", "user ID not number", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrUserIDNotNumber}
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetUserByID, "error", "user ID not number")
+		return validationError(constants.ErrUserIDNotNumber, "data", data)
 	}
 
 	id := int64(idFloat)
 	if id <= 0 {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetUserByID, "user_id", id, "error", "invalid ID", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: "user " + constants.ErrIDMustBePositive}
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetUserByID, "user_id", id, "error", "invalid ID")
+		return validationError("user "+constants.ErrIDMustBePositive, "data", id)
 	}
 
-	user, err := repository.GetUserByID(db, id)
+	user, err := st.GetUserByID(ctx, id)
 	if err != nil {
-		logger.Log.Warnw(constants.LogUserNotFound, "user_id", id, "error", err, "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: err.Error()}
+		log.Warnw(constants.LogUserNotFound, "user_id", id, "error", err)
+		return storeError(err)
 	}
 
 	duration := time.Since(startTime)
-	logger.Log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetUserByID, "duration_ms", duration.Milliseconds(), "user_id", id, "remote_addr", clientAddr)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetUserByID, "duration_ms", duration.Milliseconds(), "user_id", id)
 	return models.WSResponse{Success: true, Data: user}
 }
 
 // handleAddUser adds a new user to the database
-func handleAddUser(data interface{}, startTime time.Time, clientAddr string) models.WSResponse {
+func handleAddUser(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
 	dataMap, ok := data.(map[string]interface{})
 	if !ok {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddUser, "error", "user data not object", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrInvalidUserData}
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddUser, "error", "user data not object")
+		return validationError(constants.ErrInvalidUserData, "data", data)
+	}
+
+	fields, response, ok := parseUserFields(constants.ActionAddUser, dataMap, false, log)
+	if !ok {
+		return response
+	}
+	newUser := models.User{Username: *fields.Username, Email: *fields.Email}
+
+	id, err := st.AddUser(ctx, newUser)
+	if err != nil {
+		log.Errorw(constants.LogFailedToAddUser, "username", newUser.Username, "error", err)
+		return storeError(err)
 	}
 
-	var newUser models.User
+	duration := time.Since(startTime)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionAddUser, "duration_ms", duration.Milliseconds(), "user_id", id, "username", newUser.Username)
+	return models.WSResponse{Success: true, Data: map[string]interface{}{constants.JSONFieldID: id}}
+}
+
+// parseUserFields validates the user fields in dataMap. Every field is
+// required unless partial is set, in which case absent fields stay nil.
+func parseUserFields(action string, dataMap map[string]interface{}, partial bool, log *zap.SugaredLogger) (models.UserUpdate, models.WSResponse, bool) {
+	var fields models.UserUpdate
+	present := func(key string) bool {
+		_, ok := dataMap[key]
+		return ok || !partial
+	}
 
 	// Validate username
-	if username, ok := dataMap[constants.JSONFieldUsername].(string); ok && username != "" {
-		newUser.Username = username
-	} else {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddUser, "error", "missing or empty username", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrInvalidOrMissingUsername}
+	if present(constants.JSONFieldUsername) {
+		if username, ok := dataMap[constants.JSONFieldUsername].(string); ok && username != "" {
+			fields.Username = &username
+		} else {
+			log.Warnw(constants.LogInvalidRequest, "action", action, "error", "missing or empty username")
+			return fields, validationError(constants.ErrInvalidOrMissingUsername, constants.JSONFieldUsername, dataMap[constants.JSONFieldUsername]), false
+		}
 	}
 
 	// Validate email
-	if email, ok := dataMap[constants.JSONFieldEmail].(string); ok && email != "" {
-		newUser.Email = email
-	} else {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddUser, "error", "missing or empty email", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrInvalidOrMissingEmail}
+	if present(constants.JSONFieldEmail) {
+		if email, ok := dataMap[constants.JSONFieldEmail].(string); ok && email != "" {
+			fields.Email = &email
+		} else {
+			log.Warnw(constants.LogInvalidRequest, "action", action, "error", "missing or empty email")
+			return fields, validationError(constants.ErrInvalidOrMissingEmail, constants.JSONFieldEmail, dataMap[constants.JSONFieldEmail]), false
+		}
 	}
 
-	id, err := repository.AddUser(db, newUser)
+	return fields, models.WSResponse{}, true
+}
+
+// handleUpdateUser changes a user's username and/or email
+func handleUpdateUser(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionUpdateUser, "error", "user data not object")
+		return validationError(constants.ErrInvalidUserData, "data", data)
+	}
+
+	id, response, ok := recordIDFromData(constants.ActionUpdateUser, dataMap, log)
+	if !ok {
+		return response
+	}
+
+	update, response, ok := parseUserFields(constants.ActionUpdateUser, dataMap, true, log)
+	if !ok {
+		return response
+	}
+	if update == (models.UserUpdate{}) {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionUpdateUser, "user_id", id, "error", "no fields to update")
+		return validationError(constants.ErrNoUserFieldsToUpdate, "data", nil)
+	}
+
+	user, err := st.UpdateUser(ctx, id, update)
 	if err != nil {
-		logger.Log.Errorw(constants.LogFailedToAddUser, "username", newUser.Username, "error", err, "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: err.Error()}
+		log.Errorw(constants.LogFailedToUpdateUser, "user_id", id, "error", err)
+		return storeError(err)
 	}
 
 	duration := time.Since(startTime)
-	logger.Log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionAddUser, "duration_ms", duration.Milliseconds(), "user_id", id, "username", newUser.Username, "remote_addr", clientAddr)
-	return models.WSResponse{Success: true, Data: map[string]interface{}{constants.JSONFieldID: id}}
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionUpdateUser, "duration_ms", duration.Milliseconds(), "user_id", id)
+	return models.WSResponse{Success: true, Data: user}
 }
 
-// handleGetPurchases retrieves all purchases from the database
-func handleGetPurchases(startTime time.Time, clientAddr string) models.WSResponse {
-	purchases, err := repository.GetAllPurchases(db)
+// handleDeleteUser deletes a user. on_purchases decides what happens to the
+// user's purchases: "block" (default), "anonymize" or "cascade".
+func handleDeleteUser(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionDeleteUser, "error", "delete data not object")
+		return validationError(constants.ErrInvalidDeleteData, "data", data)
+	}
+
+	id, response, ok := recordIDFromData(constants.ActionDeleteUser, dataMap, log)
+	if !ok {
+		return response
+	}
+
+	// Validate on_purchases
+	policy := repository.BlockIfPurchased
+	if value, present := dataMap[constants.JSONFieldOnPurchases]; present {
+		name, _ := value.(string)
+		switch p := repository.UserPurchasePolicy(name); p {
+		case repository.BlockIfPurchased, repository.AnonymizePurchaser, repository.CascadePurchases:
+			policy = p
+		default:
+			log.Warnw(constants.LogInvalidRequest, "action", constants.ActionDeleteUser, "on_purchases", value, "error", "invalid on_purchases")
+			return validationError(constants.ErrInvalidUserOnPurchases, constants.JSONFieldOnPurchases, value)
+		}
+	}
+
+	purchases, err := st.DeleteUser(ctx, id, policy)
 	if err != nil {
-		logger.Log.Errorw(constants.LogFailedToGetPurchases, "error", err, "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: err.Error()}
+		log.Errorw(constants.LogFailedToDeleteUser, "user_id", id, "on_purchases", policy, "error", err)
+		return storeError(err)
+	}
+
+	deleted := constants.DeletedHard
+	if purchases > 0 && policy == repository.AnonymizePurchaser {
+		deleted = constants.DeletedAnonymized
 	}
 
 	duration := time.Since(startTime)
-	logger.Log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetPurchases, "duration_ms", duration.Milliseconds(), "purchase_count", len(purchases), "remote_addr", clientAddr)
-	return models.WSResponse{Success: true, Data: purchases}
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionDeleteUser, "duration_ms", duration.Milliseconds(), "user_id", id, "deleted", deleted, "purchases", purchases)
+	return models.WSResponse{Success: true, Data: map[string]interface{}{constants.JSONFieldID: id, constants.JSONFieldDeleted: deleted, constants.JSONFieldPurchases: purchases}}
 }
 
-// handleGetPurchasesByUserID retrieves purchases for a specific user
-func handleGetPurchasesByUserID(data interface{}, startTime time.Time, clientAddr string) models.WSResponse {
-	userIDFloat, ok := data.(float64)
+// handleGetPurchases retrieves one page of purchases from the database,
+// optionally only those made between from and to
+func handleGetPurchases(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	page, response, ok := parsePageRequest(constants.ActionGetPurchases, data, log)
 	if !ok {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetPurchasesByUserID, "error", "user ID not number", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrUserIDNotNumber}
+		return response
 	}
 
-	userID := int64(userIDFloat)
-	if userID <= 0 {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetPurchasesByUserID, "user_id", userID, "error", "invalid ID", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: "user " + constants.ErrIDMustBePositive}
+	var filter models.PurchaseFilter
+	if dataMap, isMap := data.(map[string]interface{}); isMap {
+		if filter, response, ok = parsePurchaseFilter(constants.ActionGetPurchases, dataMap, log); !ok {
+			return response
+		}
+	}
+
+	purchases, hasMore, err := st.ListPurchases(ctx, filter, page)
+	if err != nil {
+		log.Errorw(constants.LogFailedToGetPurchases, "error", err)
+		return storeError(err)
+	}
+
+	duration := time.Since(startTime)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetPurchases, "duration_ms", duration.Milliseconds(), "purchase_count", len(purchases), "has_more", hasMore)
+	return models.WSResponse{Success: true, Data: newPage(purchases, hasMore, func(last models.Purchase) string { return encodeCursor(last.ID) })}
+}
+
+// handleGetPurchasesByUserID retrieves one page of the purchases of a specific
+// user. data is the user ID, or an object with user_id and the optional from,
+// to, limit and cursor.
+func handleGetPurchasesByUserID(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	var userID int64
+	var filter models.PurchaseFilter
+	page := models.PageRequest{Limit: constants.DefaultPageLimit}
+
+	switch v := data.(type) {
+	case float64:
+		userID = int64(v)
+		if userID <= 0 {
+			log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetPurchasesByUserID, "user_id", userID, "error", "invalid ID")
+			return validationError("user "+constants.ErrIDMustBePositive, "data", userID)
+		}
+	case map[string]interface{}:
+		id, ok := v[constants.JSONFieldUserID].(float64)
+		if !ok || id <= 0 {
+			log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetPurchasesByUserID, "user_id", v[constants.JSONFieldUserID], "error", "invalid user_id")
+			return validationError(constants.ErrInvalidUserIDMustBePositive, constants.JSONFieldUserID, v[constants.JSONFieldUserID])
+		}
+		userID = int64(id)
+
+		var response models.WSResponse
+		if filter, response, ok = parsePurchaseFilter(constants.ActionGetPurchasesByUserID, v, log); !ok {
+			return response
+		}
+		if page, response, ok = parsePageRequest(constants.ActionGetPurchasesByUserID, v, log); !ok {
+			return response
+		}
+	default:
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetPurchasesByUserID, "error", "user ID not number or object")
+		return validationError(constants.ErrInvalidPurchasesByUserData, "data", data)
 	}
 
-	purchases, err := repository.GetPurchasesByUserID(db, userID)
+	purchases, hasMore, err := st.ListPurchasesByUserID(ctx, userID, filter, page)
 	if err != nil {
-		logger.Log.Errorw(constants.LogFailedToGetPurchasesByUser, "user_id", userID, "error", err, "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: err.Error()}
+		log.Errorw(constants.LogFailedToGetPurchasesByUser, "user_id", userID, "error", err)
+		return storeError(err)
 	}
 
 	duration := time.Since(startTime)
-	logger.Log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetPurchasesByUserID, "duration_ms", duration.Milliseconds(), "user_id", userID, "purchase_count", len(purchases), "remote_addr", clientAddr)
-	return models.WSResponse{Success: true, Data: purchases}
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetPurchasesByUserID, "duration_ms", duration.Milliseconds(), "user_id", userID, "purchase_count", len(purchases), "has_more", hasMore)
+	return models.WSResponse{Success: true, Data: newPage(purchases, hasMore, func(last models.Purchase) string { return encodeCursor(last.ID) })}
+}
+
+// parsePurchaseFilter reads the optional from and to of a purchase query. from
+// is inclusive and to exclusive; a bare date means midnight UTC.
+func parsePurchaseFilter(action string, dataMap map[string]interface{}, log *zap.SugaredLogger) (models.PurchaseFilter, models.WSResponse, bool) {
+	var filter models.PurchaseFilter
+
+	if raw, present := dataMap[constants.JSONFieldFrom]; present {
+		from, ok := parseFilterTime(raw)
+		if !ok {
+			log.Warnw(constants.LogInvalidRequest, "action", action, "error", "invalid from", "from", raw)
+			return filter, validationError(constants.ErrInvalidFromTime, constants.JSONFieldFrom, raw), false
+		}
+		filter.From = from
+	}
+
+	if raw, present := dataMap[constants.JSONFieldTo]; present {
+		to, ok := parseFilterTime(raw)
+		if !ok {
+			log.Warnw(constants.LogInvalidRequest, "action", action, "error", "invalid to", "to", raw)
+			return filter, validationError(constants.ErrInvalidToTime, constants.JSONFieldTo, raw), false
+		}
+		filter.To = to
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		log.Warnw(constants.LogInvalidRequest, "action", action, "error", "time range reversed", "from", filter.From, "to", filter.To)
+		return filter, validationError(constants.ErrTimeRangeReversed, constants.JSONFieldTo, dataMap[constants.JSONFieldTo]), false
+	}
+
+	return filter, models.WSResponse{}, true
+}
+
+// parseFilterTime parses an RFC 3339 time or a YYYY-MM-DD date, in UTC
+func parseFilterTime(raw interface{}) (time.Time, bool) {
+	text, ok := raw.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
 }
 
 // handleAddPurchase adds a new purchase to the database
-func handleAddPurchase(data interface{}, startTime time.Time, clientAddr string) models.WSResponse {
+func handleAddPurchase(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
 	dataMap, ok := data.(map[string]interface{})
 	if !ok {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddPurchase, "error", "purchase data not object", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrInvalidPurchaseData}
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddPurchase, "error", "purchase data not object")
+		return validationError(constants.ErrInvalidPurchaseData, "data", data)
 	}
 
 	var newPurchase models.Purchase
@@ -359,73 +980,383 @@ func handleAddPurchase(data interface{}, startTime time.Time, clientAddr string)
 	if userID, ok := dataMap[constants.JSONFieldUserID].(float64); ok && userID > 0 {
 		newPurchase.UserID = int64(userID)
 	} else {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddPurchase, "user_id", dataMap[constants.JSONFieldUserID], "error", "invalid user_id", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrInvalidUserIDMustBePositive}
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddPurchase, "user_id", dataMap[constants.JSONFieldUserID], "error", "invalid user_id")
+		return validationError(constants.ErrInvalidUserIDMustBePositive, constants.JSONFieldUserID, dataMap[constants.JSONFieldUserID])
 	}
 
 	// Validate album_id
 	if albumID, ok := dataMap[constants.JSONFieldAlbumID].(float64); ok && albumID > 0 {
 		newPurchase.AlbumID = int64(albumID)
 	} else {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddPurchase, "album_id", dataMap[constants.JSONFieldAlbumID], "error", "invalid album_id", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrInvalidAlbumIDMustBePositive}
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddPurchase, "album_id", dataMap[constants.JSONFieldAlbumID], "error", "invalid album_id")
+		return validationError(constants.ErrInvalidAlbumIDMustBePositive, constants.JSONFieldAlbumID, dataMap[constants.JSONFieldAlbumID])
 	}
 
 	// Validate quantity
-	if quantity, ok := dataMap[constants.JSONFieldQuantity].(float64); ok && quantity > 0 {
-		newPurchase.Quantity = int(quantity)
+	if quantity, ok := wholeNumber(dataMap[constants.JSONFieldQuantity], 1); ok {
+		newPurchase.Quantity = quantity
 	} else {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddPurchase, "quantity", dataMap[constants.JSONFieldQuantity], "error", "invalid quantity", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrInvalidQuantityMustBePositive}
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionAddPurchase, "quantity", dataMap[constants.JSONFieldQuantity], "error", "invalid quantity")
+		return validationError(constants.ErrInvalidQuantityMustBePositive, constants.JSONFieldQuantity, dataMap[constants.JSONFieldQuantity])
 	}
 
-	logger.Log.Infow(constants.LogAttemptingPurchase, "user_id", newPurchase.UserID, "album_id", newPurchase.AlbumID, "quantity", newPurchase.Quantity, "remote_addr", clientAddr)
+	log.Infow(constants.LogAttemptingPurchase, "user_id", newPurchase.UserID, "album_id", newPurchase.AlbumID, "quantity", newPurchase.Quantity)
 
-	id, err := repository.AddPurchase(db, newPurchase)
+	id, _, err := st.AddPurchase(ctx, newPurchase)
 	if err != nil {
-		logger.Log.Warnw(constants.LogPurchaseFailed, "user_id", newPurchase.UserID, "album_id", newPurchase.AlbumID, "quantity", newPurchase.Quantity, "error", err, "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: err.Error()}
+		log.Warnw(constants.LogPurchaseFailed, "user_id", newPurchase.UserID, "album_id", newPurchase.AlbumID, "quantity", newPurchase.Quantity, "error", err)
+		return purchaseError(err)
 	}
 
 	duration := time.Since(startTime)
-	logger.Log.Infow(constants.LogPurchaseSuccessful, "purchase_id", id, "user_id", newPurchase.UserID, "album_id", newPurchase.AlbumID, "quantity", newPurchase.Quantity, "duration_ms", duration.Milliseconds(), "remote_addr", clientAddr)
+	log.Infow(constants.LogPurchaseSuccessful, "purchase_id", id, "user_id", newPurchase.UserID, "album_id", newPurchase.AlbumID, "quantity", newPurchase.Quantity, "duration_ms", duration.Milliseconds())
 	return models.WSResponse{Success: true, Data: map[string]interface{}{constants.JSONFieldID: id}}
 }
 
+// handleCancelPurchase cancels some or all units of a purchase and returns
+// them to stock. Without a quantity every unit not yet cancelled is cancelled.
+func handleCancelPurchase(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionCancelPurchase, "error", "cancel data not object")
+		return validationError(constants.ErrInvalidCancelData, "data", data)
+	}
+
+	id, response, ok := recordIDFromData(constants.ActionCancelPurchase, dataMap, log)
+	if !ok {
+		return response
+	}
+
+	// Validate quantity
+	quantity := 0
+	if value, present := dataMap[constants.JSONFieldQuantity]; present {
+		if quantity, ok = wholeNumber(value, 1); !ok {
+			log.Warnw(constants.LogInvalidRequest, "action", constants.ActionCancelPurchase, "quantity", value, "error", "invalid quantity")
+			return validationError(constants.ErrInvalidCancelQuantity, constants.JSONFieldQuantity, value)
+		}
+	}
+
+	purchase, _, err := st.CancelPurchase(ctx, id, quantity)
+	if err != nil {
+		log.Warnw(constants.LogFailedToCancelPurchase, "purchase_id", id, "quantity", quantity, "error", err)
+		return storeError(err)
+	}
+
+	duration := time.Since(startTime)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionCancelPurchase, "duration_ms", duration.Milliseconds(), "purchase_id", id, "quantity", quantity, "cancelled_quantity", purchase.CancelledQuantity)
+	return models.WSResponse{Success: true, Data: purchase}
+}
+
+// handlePlaceOrder places an order for several albums at once. Either every
+// line is purchased or, if any line fails, none is.
+func handlePlaceOrder(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionPlaceOrder, "error", "order data not object")
+		return validationError(constants.ErrInvalidOrderData, "data", data)
+	}
+
+	var order models.Order
+
+	// Validate user_id
+	if userID, ok := dataMap[constants.JSONFieldUserID].(float64); ok && userID > 0 {
+		order.UserID = int64(userID)
+	} else {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionPlaceOrder, "user_id", dataMap[constants.JSONFieldUserID], "error", "invalid user_id")
+		return validationError(constants.ErrInvalidUserIDMustBePositive, constants.JSONFieldUserID, dataMap[constants.JSONFieldUserID])
+	}
+
+	// Validate lines
+	lines, ok := dataMap[constants.JSONFieldLines].([]interface{})
+	if !ok || len(lines) == 0 {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionPlaceOrder, "error", "missing or empty lines")
+		return validationError(constants.ErrInvalidOrderLines, constants.JSONFieldLines, nil)
+	}
+	if len(lines) > constants.MaxOrderLines {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionPlaceOrder, "lines", len(lines), "max", constants.MaxOrderLines, "error", "too many lines")
+		return errorResponse(constants.CodeValidationFailed, constants.ErrTooManyOrderLines, []string{constants.JSONFieldLines}, map[string]interface{}{"size": len(lines), "max": constants.MaxOrderLines})
+	}
+
+	for i, item := range lines {
+		lineMap, ok := item.(map[string]interface{})
+		if !ok {
+			log.Warnw(constants.LogInvalidRequest, "action", constants.ActionPlaceOrder, "line", i, "error", "line not object")
+			return validationError(constants.ErrInvalidOrderLine, fmt.Sprintf("%s[%d]", constants.JSONFieldLines, i), item)
+		}
+
+		var line models.OrderLine
+		if albumID, ok := lineMap[constants.JSONFieldAlbumID].(float64); ok && albumID > 0 {
+			line.AlbumID = int64(albumID)
+		} else {
+			log.Warnw(constants.LogInvalidRequest, "action", constants.ActionPlaceOrder, "line", i, "album_id", lineMap[constants.JSONFieldAlbumID], "error", "invalid album_id")
+			return validationError(constants.ErrInvalidAlbumIDMustBePositive, orderLineField(i, constants.JSONFieldAlbumID), lineMap[constants.JSONFieldAlbumID])
+		}
+		if quantity, ok := wholeNumber(lineMap[constants.JSONFieldQuantity], 1); ok {
+			line.Quantity = quantity
+		} else {
+			log.Warnw(constants.LogInvalidRequest, "action", constants.ActionPlaceOrder, "line", i, "quantity", lineMap[constants.JSONFieldQuantity], "error", "invalid quantity")
+			return validationError(constants.ErrInvalidQuantityMustBePositive, orderLineField(i, constants.JSONFieldQuantity), lineMap[constants.JSONFieldQuantity])
+		}
+		order.Lines = append(order.Lines, line)
+	}
+
+	placed, _, err := st.PlaceOrder(ctx, order)
+	if err != nil {
+		log.Warnw(constants.LogOrderFailed, "user_id", order.UserID, "lines", len(order.Lines), "error", err)
+		return orderError(err)
+	}
+
+	duration := time.Since(startTime)
+	log.Infow(constants.LogOrderPlaced, "order_id", placed.ID, "user_id", placed.UserID, "lines", len(placed.Lines), "duration_ms", duration.Milliseconds())
+	return models.WSResponse{Success: true, Data: placed}
+}
+
 // handleGetUserPurchaseSummary retrieves purchase summary for a specific user
-func handleGetUserPurchaseSummary(data interface{}, startTime time.Time, clientAddr string) models.WSResponse {
+func handleGetUserPurchaseSummary(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
 	userIDFloat, ok := data.(float64)
 	if !ok {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetUserPurchaseSummary, "error", "user ID not number", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: constants.ErrUserIDNotNumber}
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetUserPurchaseSummary, "error", "user ID not number")
+		return validationError(constants.ErrUserIDNotNumber, "data", data)
 	}
 
 	userID := int64(userIDFloat)
 	if userID <= 0 {
-		logger.Log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetUserPurchaseSummary, "user_id", userID, "error", "invalid ID", "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: "user " + constants.ErrIDMustBePositive}
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetUserPurchaseSummary, "user_id", userID, "error", "invalid ID")
+		return validationError("user "+constants.ErrIDMustBePositive, "data", userID)
 	}
 
-	summary, err := repository.GetUserPurchaseSummary(db, userID)
+	summary, err := st.GetUserPurchaseSummary(ctx, userID)
 	if err != nil {
-		logger.Log.Errorw(constants.LogFailedToGetUserPurchaseSummary, "user_id", userID, "error", err, "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: err.Error()}
+		log.Errorw(constants.LogFailedToGetUserPurchaseSummary, "user_id", userID, "error", err)
+		return storeError(err)
 	}
 
 	duration := time.Since(startTime)
-	logger.Log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetUserPurchaseSummary, "duration_ms", duration.Milliseconds(), "user_id", userID, "purchase_count", len(summary.Purchases), "total_cost", summary.TotalCost, "remote_addr", clientAddr)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetUserPurchaseSummary, "duration_ms", duration.Milliseconds(), "user_id", userID, "purchase_count", len(summary.Purchases), "total_cost", summary.TotalCost)
 	return models.WSResponse{Success: true, Data: summary}
 }
 
-// handleGetAllUsersPurchaseSummary retrieves purchase summaries for all users
-func handleGetAllUsersPurchaseSummary(startTime time.Time, clientAddr string) models.WSResponse {
-	summaries, err := repository.GetAllUsersPurchaseSummary(db)
+// handleGetAllUsersPurchaseSummary retrieves purchase summaries for one page of users
+func handleGetAllUsersPurchaseSummary(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	page, response, ok := parsePageRequest(constants.ActionGetAllUsersPurchaseSummary, data, log)
+	if !ok {
+		return response
+	}
+
+	summaries, hasMore, err := st.ListUsersPurchaseSummary(ctx, page)
 	if err != nil {
-		logger.Log.Errorw(constants.LogFailedToGetAllUsersPurchaseSummary, "error", err, "remote_addr", clientAddr)
-		return models.WSResponse{Success: false, Error: err.Error()}
+		log.Errorw(constants.LogFailedToGetAllUsersPurchaseSummary, "error", err)
+		return storeError(err)
 	}
 
 	duration := time.Since(startTime)
-	logger.Log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetAllUsersPurchaseSummary, "duration_ms", duration.Milliseconds(), "user_count", len(summaries), "remote_addr", clientAddr)
-	return models.WSResponse{Success: true, Data: summaries}
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetAllUsersPurchaseSummary, "duration_ms", duration.Milliseconds(), "user_count", len(summaries), "has_more", hasMore)
+	return models.WSResponse{Success: true, Data: newPage(summaries, hasMore, func(last models.UserPurchaseSummary) string { return encodeCursor(last.UserID) })}
+}
+
+// handleGetTopSellingAlbums reports the albums with the most units sold
+func handleGetTopSellingAlbums(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	filter, limit, response, ok := parseReportRequest(constants.ActionGetTopSellingAlbums, data, log)
+	if !ok {
+		return response
+	}
+
+	sales, err := st.TopSellingAlbums(ctx, filter, limit)
+	if err != nil {
+		log.Errorw(constants.LogFailedToGetTopSellingAlbums, "error", err)
+		return storeError(err)
+	}
+
+	duration := time.Since(startTime)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetTopSellingAlbums, "duration_ms", duration.Milliseconds(), "album_count", len(sales))
+	return models.WSResponse{Success: true, Data: sales}
+}
+
+// handleGetRevenueByArtist reports the artists with the most revenue
+func handleGetRevenueByArtist(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	filter, limit, response, ok := parseReportRequest(constants.ActionGetRevenueByArtist, data, log)
+	if !ok {
+		return response
+	}
+
+	revenue, err := st.RevenueByArtist(ctx, filter, limit)
+	if err != nil {
+		log.Errorw(constants.LogFailedToGetRevenueByArtist, "error", err)
+		return storeError(err)
+	}
+
+	duration := time.Since(startTime)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetRevenueByArtist, "duration_ms", duration.Milliseconds(), "artist_count", len(revenue))
+	return models.WSResponse{Success: true, Data: revenue}
+}
+
+// handleGetSalesByPeriod reports units and revenue per day, week or month
+func handleGetSalesByPeriod(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetSalesByPeriod, "error", "report data not object")
+		return validationError(constants.ErrInvalidReportData, "data", data)
+	}
+
+	period, _ := dataMap[constants.JSONFieldPeriod].(string)
+	switch period {
+	case constants.ReportPeriodDay, constants.ReportPeriodWeek, constants.ReportPeriodMonth:
+	default:
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetSalesByPeriod, "period", dataMap[constants.JSONFieldPeriod], "error", "invalid period")
+		return validationError(constants.ErrInvalidReportPeriod, constants.JSONFieldPeriod, dataMap[constants.JSONFieldPeriod])
+	}
+
+	filter, response, ok := parsePurchaseFilter(constants.ActionGetSalesByPeriod, dataMap, log)
+	if !ok {
+		return response
+	}
+
+	sales, err := st.SalesByPeriod(ctx, period, filter)
+	if err != nil {
+		log.Errorw(constants.LogFailedToGetSalesByPeriod, "period", period, "error", err)
+		return storeError(err)
+	}
+
+	duration := time.Since(startTime)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetSalesByPeriod, "duration_ms", duration.Milliseconds(), "period", period, "period_count", len(sales))
+	return models.WSResponse{Success: true, Data: sales}
+}
+
+// handleGetAverageOrderValue reports the number of orders and their average value
+func handleGetAverageOrderValue(ctx context.Context, st repository.Store, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	var filter models.PurchaseFilter
+	if data != nil {
+		dataMap, ok := data.(map[string]interface{})
+		if !ok {
+			log.Warnw(constants.LogInvalidRequest, "action", constants.ActionGetAverageOrderValue, "error", "report data not object")
+			return validationError(constants.ErrInvalidReportData, "data", data)
+		}
+		var response models.WSResponse
+		if filter, response, ok = parsePurchaseFilter(constants.ActionGetAverageOrderValue, dataMap, log); !ok {
+			return response
+		}
+	}
+
+	report, err := st.OrderValue(ctx, filter)
+	if err != nil {
+		log.Errorw(constants.LogFailedToGetAverageOrderValue, "error", err)
+		return storeError(err)
+	}
+
+	duration := time.Since(startTime)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionGetAverageOrderValue, "duration_ms", duration.Milliseconds(), "orders", report.Orders, "average_order_value", report.AverageOrderValue)
+	return models.WSResponse{Success: true, Data: report}
+}
+
+// parseReportRequest reads the optional from, to and limit of a ranked report.
+// No data asks for the top DefaultReportLimit rows of all time.
+func parseReportRequest(action string, data interface{}, log *zap.SugaredLogger) (models.PurchaseFilter, int, models.WSResponse, bool) {
+	var filter models.PurchaseFilter
+	if data == nil {
+		return filter, constants.DefaultReportLimit, models.WSResponse{}, true
+	}
+
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		log.Warnw(constants.LogInvalidRequest, "action", action, "error", "report data not object")
+		return filter, 0, validationError(constants.ErrInvalidReportData, "data", data), false
+	}
+
+	limit := constants.DefaultReportLimit
+	if raw, present := dataMap[constants.JSONFieldLimit]; present {
+		value, ok := raw.(float64)
+		if !ok || value != float64(int(value)) || value < 1 || value > constants.MaxReportLimit {
+			log.Warnw(constants.LogInvalidRequest, "action", action, "error", "invalid limit", "limit", raw)
+			return filter, 0, validationError(constants.ErrInvalidReportLimit, constants.JSONFieldLimit, raw), false
+		}
+		limit = int(value)
+	}
+
+	filter, response, ok := parsePurchaseFilter(action, dataMap, log)
+	if !ok {
+		return filter, 0, response, false
+	}
+	return filter, limit, models.WSResponse{}, true
+}
+
+// handleSubscribe starts pushing events for the given albums, or the whole
+// catalog, to the connection the message arrived on
+func handleSubscribe(ctx context.Context, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	albumIDs, catalog, response, ok := parseSubscription(constants.ActionSubscribe, data, log)
+	if !ok {
+		return response
+	}
+
+	sub, ok := events.subscribe(clientFromContext(ctx), albumIDs, catalog)
+	if !ok {
+		log.Warnw(constants.LogInvalidRequest, "action", constants.ActionSubscribe, "error", "too many subscribed albums", "subscribed", len(sub.AlbumIDs), "requested", len(albumIDs))
+		return errorResponse(constants.CodeValidationFailed, constants.ErrTooManySubscribedAlbums, []string{constants.JSONFieldAlbumIDs}, map[string]interface{}{"max": constants.MaxSubscribedAlbums})
+	}
+
+	duration := time.Since(startTime)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionSubscribe, "duration_ms", duration.Milliseconds(), "album_count", len(sub.AlbumIDs), "catalog", sub.Catalog)
+	return models.WSResponse{Success: true, Data: sub}
+}
+
+// handleUnsubscribe stops pushing events for the given albums, or the whole
+// catalog. No data stops every event for the connection.
+func handleUnsubscribe(ctx context.Context, data interface{}, startTime time.Time, log *zap.SugaredLogger) models.WSResponse {
+	c := clientFromContext(ctx)
+
+	var sub models.Subscription
+	if data == nil {
+		events.remove(c)
+		sub = models.Subscription{AlbumIDs: []int64{}}
+	} else {
+		albumIDs, catalog, response, ok := parseSubscription(constants.ActionUnsubscribe, data, log)
+		if !ok {
+			return response
+		}
+		sub = events.unsubscribe(c, albumIDs, catalog)
+	}
+
+	duration := time.Since(startTime)
+	log.Infow(constants.LogActionCompletedSuccessfully, "action", constants.ActionUnsubscribe, "duration_ms", duration.Milliseconds(), "album_count", len(sub.AlbumIDs), "catalog", sub.Catalog)
+	return models.WSResponse{Success: true, Data: sub}
+}
+
+// parseSubscription reads the album_ids and catalog of a subscribe or
+// unsubscribe request; at least one of them must be set
+func parseSubscription(action string, data interface{}, log *zap.SugaredLogger) ([]int64, bool, models.WSResponse, bool) {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		log.Warnw(constants.LogInvalidRequest, "action", action, "error", "subscription data not object")
+		return nil, false, validationError(constants.ErrInvalidSubscribeData, "data", data), false
+	}
+
+	var albumIDs []int64
+	if raw, present := dataMap[constants.JSONFieldAlbumIDs]; present {
+		items, ok := raw.([]interface{})
+		if !ok {
+			log.Warnw(constants.LogInvalidRequest, "action", action, "error", "album_ids not array", "album_ids", raw)
+			return nil, false, validationError(constants.ErrInvalidSubscribedAlbumIDs, constants.JSONFieldAlbumIDs, raw), false
+		}
+		for _, item := range items {
+			id, ok := item.(float64)
+			if !ok || id != float64(int64(id)) || id <= 0 {
+				log.Warnw(constants.LogInvalidRequest, "action", action, "error", "invalid album ID", "album_id", item)
+				return nil, false, validationError(constants.ErrInvalidSubscribedAlbumIDs, constants.JSONFieldAlbumIDs, item), false
+			}
+			albumIDs = append(albumIDs, int64(id))
+		}
+	}
+
+	var catalog bool
+	if raw, present := dataMap[constants.JSONFieldCatalog]; present {
+		if catalog, ok = raw.(bool); !ok {
+			log.Warnw(constants.LogInvalidRequest, "action", action, "error", "catalog not boolean", "catalog", raw)
+			return nil, false, validationError(constants.ErrInvalidCatalogFlag, constants.JSONFieldCatalog, raw), false
+		}
+	}
+
+	if len(albumIDs) == 0 && !catalog {
+		log.Warnw(constants.LogInvalidRequest, "action", action, "error", "nothing to subscribe to")
+		return nil, false, validationError(constants.ErrInvalidSubscribeData, "data", nil), false
+	}
+	return albumIDs, catalog, models.WSResponse{}, true
 }
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"example/data-access/internal/logger"
+)
+
+// outbound is one queued response; done, if set, runs once it has been
+// written or dropped
+type outbound struct {
+	v    interface{}
+	done func()
+}
+
+// send queues v for the connection's writer goroutine
+func (c *client) send(v interface{}, done func()) {
+	c.out <- outbound{v: v, done: done}
+}
+
+// writeLoop writes queued responses and events until out is closed. After a
+// write error the connection is closed and the rest of the queue is dropped,
+// so workers never block on a dead client. Once closeGoingAway is called it
+// writes the responses already queued and then the close frame, and drops
+// everything after it. Events still queued then, or when out is closed, are
+// dropped.
+func (c *client) writeLoop() {
+	defer close(c.writerDone)
+
+	stopped := false
+	write := func(v interface{}) {
+		if stopped {
+			return
+		}
+		if err := c.writeJSON(v); err != nil {
+			logger.Log.Errorw("Write error", "error", err, "remote_addr", c.addr)
+			stopped = true
+			c.cancel()
+			c.conn.Close()
+		}
+	}
+	writeOut := func(o outbound) {
+		write(o.v)
+		if o.done != nil {
+			o.done()
+		}
+	}
+
+	closing := c.closing
+	for {
+		select {
+		case o, ok := <-c.out:
+			if !ok {
+				return
+			}
+			writeOut(o)
+		case event := <-c.events:
+			write(event)
+		case <-closing:
+			closing = nil
+			open := c.flush(writeOut)
+			if !stopped {
+				if err := c.writeGoingAway(); err != nil {
+					logger.Log.Debugw("Failed to send close frame", "error", err, "remote_addr", c.addr)
+				}
+				stopped = true
+			}
+			if !open {
+				return
+			}
+		}
+	}
+}
+
+// flush hands every response already queued on out to writeOut without
+// waiting for more, and reports false if out was closed
+func (c *client) flush(writeOut func(outbound)) bool {
+	for {
+		select {
+		case o, ok := <-c.out:
+			if !ok {
+				return false
+			}
+			writeOut(o)
+		default:
+			return true
+		}
+	}
+}
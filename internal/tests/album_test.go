@@ -0,0 +1,253 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"example/data-access/internal/models"
+	"example/data-access/internal/repository"
+)
+
+// TestUpdateAndRestockAlbum tests partial updates and restocking with a reason
+func TestUpdateAndRestockAlbum(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	albumID, _ := store.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 2})
+
+	price := models.Money(1250)
+	album, err := store.UpdateAlbum(ctx, albumID, models.AlbumUpdate{Price: &price})
+	if err != nil {
+		t.Fatalf("UpdateAlbum failed: %v", err)
+	}
+	if album.Title != "Blue Train" || album.Price != 1250 || album.Stock != 2 {
+		t.Errorf("Expected only the price to change, got %+v", album)
+	}
+
+	album, err = store.RestockAlbum(ctx, albumID, 3, "supplier delivery")
+	if err != nil {
+		t.Fatalf("RestockAlbum failed: %v", err)
+	}
+	if album.Stock != 5 {
+		t.Errorf("Expected stock 5 after restock, got %d", album.Stock)
+	}
+
+	var reason string
+	db.QueryRow("SELECT reason FROM album_restock WHERE album_id = ?", albumID).Scan(&reason)
+	if reason != "supplier delivery" {
+		t.Errorf("Expected restock reason to be recorded, got %q", reason)
+	}
+
+	if _, err := store.UpdateAlbum(ctx, 999, models.AlbumUpdate{Price: &price}); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound updating a missing album, got %v", err)
+	}
+	if _, err := store.RestockAlbum(ctx, 999, 1, "count"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound restocking a missing album, got %v", err)
+	}
+}
+
+// TestDeleteAlbum tests hard deletes, refusals and soft deletes of purchased albums
+func TestDeleteAlbum(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	userID, _ := store.AddUser(ctx, models.User{Username: "buyer", Email: "buyer@example.com"})
+	unsold, _ := store.AddAlbum(ctx, models.Album{Title: "Jeru", Artist: "Gerry Mulligan", Price: 2000, Stock: 1})
+	sold, _ := store.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 5})
+	if _, _, err := store.AddPurchase(ctx, models.Purchase{UserID: userID, AlbumID: sold, Quantity: 1}); err != nil {
+		t.Fatalf("Purchase failed: %v", err)
+	}
+
+	soft, err := store.DeleteAlbum(ctx, unsold, false)
+	if err != nil || soft {
+		t.Errorf("Expected hard delete of unsold album, got soft=%v err=%v", soft, err)
+	}
+
+	if _, err := store.DeleteAlbum(ctx, sold, false); !errors.Is(err, repository.ErrInUse) {
+		t.Errorf("Expected ErrInUse deleting a purchased album, got %v", err)
+	}
+
+	soft, err = store.DeleteAlbum(ctx, sold, true)
+	if err != nil || !soft {
+		t.Fatalf("Expected soft delete of purchased album, got soft=%v err=%v", soft, err)
+	}
+
+	albums, _ := store.GetAllAlbums(ctx)
+	if len(albums) != 0 {
+		t.Errorf("Expected deleted albums to be hidden, got %+v", albums)
+	}
+	if _, _, err := store.AddPurchase(ctx, models.Purchase{UserID: userID, AlbumID: sold, Quantity: 1}); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound purchasing a soft-deleted album, got %v", err)
+	}
+
+	summary, err := store.GetUserPurchaseSummary(ctx, userID)
+	if err != nil || len(summary.Purchases) != 1 {
+		t.Errorf("Expected purchase history to keep the soft-deleted album, got %+v (err: %v)", summary, err)
+	}
+}
+
+// TestAlbumMaintenanceActions tests the updateAlbum, restockAlbum and deleteAlbum actions
+func TestAlbumMaintenanceActions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userResult, _ := db.Exec("INSERT INTO user (username, email) VALUES (?, ?)", "buyer", "buyer@example.com")
+	userID, _ := userResult.LastInsertId()
+	albumResult, _ := db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", "Blue Train", "John Coltrane", 1000, 3)
+	albumID, _ := albumResult.LastInsertId()
+	db.Exec("INSERT INTO purchase (user_id, album_id, quantity) VALUES (?, ?, ?)", userID, albumID, 1)
+
+	conn := dialTestServer(t, repository.NewSQLiteStore(db))
+
+	send := func(action string, data map[string]interface{}) models.WSResponse {
+		t.Helper()
+		if err := conn.WriteJSON(models.WSMessage{Action: action, Data: data}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		var response models.WSResponse
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return response
+	}
+
+	if r := send("updateAlbum", map[string]interface{}{"id": albumID}); r.Error == nil || r.Error.Code != "VALIDATION_FAILED" {
+		t.Errorf("Expected VALIDATION_FAILED for an update without fields, got %+v", r)
+	}
+	if r := send("updateAlbum", map[string]interface{}{"id": albumID, "title": ""}); r.Error == nil || len(r.Error.Fields) != 1 || r.Error.Fields[0] != "title" {
+		t.Errorf("Expected title validation error, got %+v", r)
+	}
+	if r := send("updateAlbum", map[string]interface{}{"id": albumID, "title": "Blue Train (Remastered)"}); !r.Success {
+		t.Errorf("Expected update to succeed, got %+v", r.Error)
+	}
+	if r := send("restockAlbum", map[string]interface{}{"id": albumID, "quantity": 2}); r.Error == nil || r.Error.Fields[0] != "reason" {
+		t.Errorf("Expected reason validation error, got %+v", r)
+	}
+	if r := send("deleteAlbum", map[string]interface{}{"id": albumID}); r.Error == nil || r.Error.Code != "IN_USE" {
+		t.Errorf("Expected IN_USE deleting a purchased album, got %+v", r)
+	}
+
+	r := send("deleteAlbum", map[string]interface{}{"id": albumID, "on_purchases": "soft_delete"})
+	if data, _ := r.Data.(map[string]interface{}); !r.Success || data["deleted"] != "soft" {
+		t.Errorf("Expected soft delete, got %+v", r)
+	}
+}
+
+// TestSearchAlbums tests substring, price and stock filters, sorting, and
+// paging through ties in the sort column
+func TestSearchAlbums(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	for _, alb := range []models.Album{
+		{Title: "Blue Train", Artist: "John Coltrane", Price: 1999, Stock: 2},
+		{Title: "Giant Steps", Artist: "John Coltrane", Price: 1499, Stock: 0},
+		{Title: "Kind of Blue", Artist: "Miles Davis", Price: 1999, Stock: 5},
+		{Title: "100% Blue", Artist: "Various", Price: 999, Stock: 1},
+		{Title: "Jeru", Artist: "Gerry Mulligan", Price: 1999, Stock: 3},
+	} {
+		store.AddAlbum(ctx, alb)
+	}
+
+	titles := func(search models.AlbumSearch) []string {
+		t.Helper()
+		albums, _, err := store.SearchAlbums(ctx, search)
+		if err != nil {
+			t.Fatalf("SearchAlbums failed: %v", err)
+		}
+		var titles []string
+		for _, album := range albums {
+			titles = append(titles, album.Title)
+		}
+		return titles
+	}
+
+	if got := fmt.Sprint(titles(models.AlbumSearch{Title: "BLUE", Limit: 10})); got != "[Blue Train Kind of Blue 100% Blue]" {
+		t.Errorf("Expected case-insensitive title matches, got %v", got)
+	}
+	if got := fmt.Sprint(titles(models.AlbumSearch{Title: "0%", Limit: 10})); got != "[100% Blue]" {
+		t.Errorf("Expected %% to match literally, got %v", got)
+	}
+	minPrice, maxPrice := models.Money(1000), models.Money(1999)
+	if got := fmt.Sprint(titles(models.AlbumSearch{Artist: "coltrane", MinPrice: &minPrice, MaxPrice: &maxPrice, InStockOnly: true, Limit: 10})); got != "[Blue Train]" {
+		t.Errorf("Expected only the in-stock Coltrane album in range, got %v", got)
+	}
+	if got := fmt.Sprint(titles(models.AlbumSearch{SortBy: "stock", Descending: true, Limit: 2})); got != "[Kind of Blue Jeru]" {
+		t.Errorf("Expected the two best-stocked albums, got %v", got)
+	}
+
+	// Three albums share the top price; pages must not skip or repeat them
+	var seen []string
+	search := models.AlbumSearch{SortBy: "price", Descending: true, Limit: 2}
+	for {
+		albums, hasMore, err := store.SearchAlbums(ctx, search)
+		if err != nil {
+			t.Fatalf("SearchAlbums failed: %v", err)
+		}
+		for _, album := range albums {
+			seen = append(seen, album.Title)
+		}
+		if !hasMore {
+			break
+		}
+		search.After = &albums[len(albums)-1]
+	}
+	if got := fmt.Sprint(seen); got != "[Blue Train Kind of Blue Jeru Giant Steps 100% Blue]" {
+		t.Errorf("Expected every album once by price then ID, got %v", got)
+	}
+}
+
+// TestSearchAlbumsAction tests searchAlbums validation and that a cursor only fits the sort it came from
+func TestSearchAlbumsAction(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	for _, title := range []string{"Blue Train", "Kind of Blue", "Jeru"} {
+		db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", title, "Artist", 1000, 1)
+	}
+	conn := dialTestServer(t, repository.NewSQLiteStore(db))
+
+	search := func(data map[string]interface{}) (models.WSResponse, models.Page[models.Album]) {
+		t.Helper()
+		if err := conn.WriteJSON(models.WSMessage{Action: "searchAlbums", Data: data}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		var response struct {
+			models.WSResponse
+			Data models.Page[models.Album] `json:"data"`
+		}
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return response.WSResponse, response.Data
+	}
+
+	response, page := search(map[string]interface{}{"sort": "title", "limit": 1})
+	if !response.Success || len(page.Items) != 1 || page.Items[0].Title != "Blue Train" || page.NextCursor == "" {
+		t.Fatalf("Expected Blue Train first by title, got %+v", page)
+	}
+	if _, next := search(map[string]interface{}{"sort": "title", "limit": 1, "cursor": page.NextCursor}); len(next.Items) != 1 || next.Items[0].Title != "Jeru" {
+		t.Errorf("Expected Jeru on the second page, got %+v", next)
+	}
+
+	for field, data := range map[string]map[string]interface{}{
+		"sort":      {"sort": "price; DROP TABLE album"},
+		"order":     {"order": "sideways"},
+		"min_price": {"min_price": 20, "max_price": 10},
+		"max_price": {"max_price": 9.999},
+		"in_stock":  {"in_stock": "yes"},
+		"cursor":    {"sort": "artist", "cursor": page.NextCursor},
+	} {
+		response, _ := search(data)
+		if response.Success || response.Error.Code != "VALIDATION_FAILED" || response.Error.Fields[0] != field {
+			t.Errorf("Expected %v to be rejected on %s, got %+v", data, field, response.Error)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"example/data-access/internal/config"
+	"example/data-access/internal/models"
+	"example/data-access/internal/repository"
+	"example/data-access/internal/server"
+)
+
+// TestAtomicBatchRollsBack tests that a failing item rolls back the items before it
+func TestAtomicBatchRollsBack(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userResult, _ := db.Exec("INSERT INTO user (username, email) VALUES (?, ?)", "buyer", "buyer@example.com")
+	userID, _ := userResult.LastInsertId()
+	albumResult, _ := db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", "Blue Train", "John Coltrane", 1000, 3)
+	albumID, _ := albumResult.LastInsertId()
+
+	conn := dialTestServer(t, repository.NewSQLiteStore(db))
+
+	purchase := func(quantity int) models.WSMessage {
+		return models.WSMessage{Action: "addPurchase", Data: map[string]interface{}{"user_id": userID, "album_id": albumID, "quantity": quantity}}
+	}
+	batch := models.WSBatch{Atomic: true, Messages: []models.WSMessage{purchase(1), purchase(5), purchase(1)}}
+	if err := conn.WriteJSON(batch); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var response struct {
+		Success bool
+		Data    []models.WSResponse
+		Error   *models.WSError
+	}
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if response.Success || response.Error == nil || response.Error.Code != "BATCH_ROLLED_BACK" {
+		t.Fatalf("Expected BATCH_ROLLED_BACK, got %+v", response)
+	}
+	if response.Error.Details["failed_index"] != float64(1) {
+		t.Errorf("Expected failed_index 1, got %v", response.Error.Details)
+	}
+
+	wantCodes := []string{"ROLLED_BACK", "INSUFFICIENT_STOCK", "NOT_EXECUTED"}
+	if len(response.Data) != len(wantCodes) {
+		t.Fatalf("Expected %d item results, got %d", len(wantCodes), len(response.Data))
+	}
+	for i, want := range wantCodes {
+		if item := response.Data[i]; item.Success || item.Error == nil || item.Error.Code != want {
+			t.Errorf("Item %d: expected %s, got %+v", i, want, item)
+		}
+	}
+
+	var stock, count int
+	db.QueryRow("SELECT stock FROM album WHERE id = ?", albumID).Scan(&stock)
+	db.QueryRow("SELECT COUNT(*) FROM purchase").Scan(&count)
+	if stock != 3 || count != 0 {
+		t.Errorf("Expected stock 3 and no purchases after rollback, got stock %d and %d purchases", stock, count)
+	}
+}
+
+// TestAtomicBatchCommits tests that a batch whose items all succeed is committed
+func TestAtomicBatchCommits(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	conn := dialTestServer(t, repository.NewSQLiteStore(db))
+
+	batch := models.WSBatch{Atomic: true, Messages: []models.WSMessage{
+		{Action: "addUser", Data: map[string]interface{}{"username": "buyer", "email": "buyer@example.com"}},
+		{Action: "addAlbum", Data: map[string]interface{}{"title": "Jeru", "artist": "Gerry Mulligan", "price": 17.99, "stock": 2}},
+		{Action: "getUsers"},
+	}}
+	if err := conn.WriteJSON(batch); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var response struct {
+		Success bool
+		Data    []models.WSResponse
+	}
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if !response.Success || len(response.Data) != 3 {
+		t.Fatalf("Expected committed batch with 3 results, got %+v", response)
+	}
+	page, _ := response.Data[2].Data.(map[string]interface{})
+	if users, ok := page["items"].([]interface{}); !ok || len(users) != 1 {
+		t.Errorf("Expected getUsers to see the user added earlier in the batch, got %+v", response.Data[2].Data)
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM album").Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected committed album, got %d albums", count)
+	}
+}
+
+// TestAtomicBatchRejectsSubscriptions tests that an atomic batch holding a
+// subscription change is rejected before any item runs
+func TestAtomicBatchRejectsSubscriptions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	conn := dialTestServer(t, repository.NewSQLiteStore(db))
+
+	batch := models.WSBatch{Atomic: true, Messages: []models.WSMessage{
+		{Action: "addUser", Data: map[string]interface{}{"username": "buyer", "email": "buyer@example.com"}},
+		{Action: "subscribe", Data: map[string]interface{}{"catalog": true}},
+	}}
+	if err := conn.WriteJSON(batch); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var response models.WSResponse
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if response.Success || response.Error == nil || response.Error.Code != "VALIDATION_FAILED" {
+		t.Fatalf("Expected VALIDATION_FAILED, got %+v", response)
+	}
+	if response.Error.Details["index"] != float64(1) || response.Error.Details["action"] != "subscribe" {
+		t.Errorf("Expected index 1 and action subscribe in details, got %v", response.Error.Details)
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM user").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected no user added, got %d users", count)
+	}
+}
+
+// TestBatchTooLarge tests that batches over the configured size are rejected
+func TestBatchTooLarge(t *testing.T) {
+	server.Configure(config.Server{MaxBatchSize: 2})
+	t.Cleanup(func() { server.Configure(config.Default().Server) })
+
+	conn := dialTestServer(t, &stubStore{})
+
+	batch := []models.WSMessage{{Action: "getAlbums"}, {Action: "getAlbums"}, {Action: "getAlbums"}}
+	if err := conn.WriteJSON(batch); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var response models.WSResponse
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if response.Success || response.Error == nil || response.Error.Code != "BATCH_TOO_LARGE" {
+		t.Fatalf("Expected BATCH_TOO_LARGE, got %+v", response)
+	}
+	if response.Error.Details["max"] != float64(2) || response.Error.Details["size"] != float64(3) {
+		t.Errorf("Expected size 3 and max 2 in details, got %v", response.Error.Details)
+	}
+}
+
+// barrierStore makes ListAlbums wait until n calls are running at once
+type barrierStore struct {
+	stubStore
+	arrived chan struct{}
+	n       int
+}
+
+func (s *barrierStore) ListAlbums(ctx context.Context, page models.PageRequest) ([]models.Album, bool, error) {
+	s.arrived <- struct{}{}
+	for len(s.arrived) < s.n {
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+	return nil, false, nil
+}
+
+// TestReadOnlyBatchRunsInParallel tests that read-only batch items run concurrently
+func TestReadOnlyBatchRunsInParallel(t *testing.T) {
+	store := &barrierStore{arrived: make(chan struct{}, 3), n: 3}
+	conn := dialTestServer(t, store)
+
+	batch := []models.WSMessage{{Action: "getAlbums"}, {Action: "getAlbums"}, {Action: "getAlbums"}}
+	if err := conn.WriteJSON(batch); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var responses []models.WSResponse
+	if err := conn.ReadJSON(&responses); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	for i, r := range responses {
+		if !r.Success {
+			t.Errorf("Item %d: expected all items to run concurrently and succeed, got %+v", i, r.Error)
+		}
+	}
+}
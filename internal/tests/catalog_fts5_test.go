@@ -0,0 +1,57 @@
+//go:build sqlite_fts5
+
+package tests
+
+import (
+	"testing"
+
+	"example/data-access/internal/migrations"
+	"example/data-access/internal/repository"
+)
+
+// TestCatalogIndexMigrationSQLite tests that FTS5 builds create the catalog
+// index with a migration, keep it in step with the album table and drop it on
+// rollback
+func TestCatalogIndexMigrationSQLite(t *testing.T) {
+	db, err := repository.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := migrations.Up(db, "sqlite"); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	statuses, err := migrations.GetStatus(db, "sqlite")
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	last := statuses[len(statuses)-1]
+	if last.Name != "catalog_index" || !last.Applied {
+		t.Fatalf("Expected catalog_index as the last applied migration, got %+v", last)
+	}
+
+	if _, err := db.Exec("INSERT INTO album (title, artist, price, stock) VALUES ('Blue Train', 'John Coltrane', 1000, 1)"); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM album_fts WHERE album_fts MATCH 'train'").Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected the new album in album_fts, got %d matches", count)
+	}
+	db.QueryRow("SELECT COUNT(*) FROM album_trigram WHERE album_trigram MATCH 'oltr'").Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected the new album in album_trigram, got %d matches", count)
+	}
+
+	if _, err := migrations.Down(db, "sqlite", 1); err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name IN ('album_fts', 'album_trigram')").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected the catalog index dropped, %d tables remain", count)
+	}
+	if _, err := db.Exec("UPDATE album SET title = 'Giant Steps'"); err != nil {
+		t.Errorf("Expected album updates to work without the index, got %v", err)
+	}
+}
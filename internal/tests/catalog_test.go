@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"example/data-access/internal/models"
+	"example/data-access/internal/repository"
+)
+
+// TestSearchCatalog tests ranking, prefix, typo and short word matching,
+// highlighting and that the index follows album changes. It runs against FTS5 with -tags
+// sqlite_fts5 and against the catalog_score scan without.
+func TestSearchCatalog(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	var ids []int64
+	for _, alb := range []models.Album{
+		{Title: "Kind of Blue", Artist: "Miles Davis", Price: 1999, Stock: 1},
+		{Title: "Blue Train", Artist: "John Coltrane", Price: 1999, Stock: 1},
+		{Title: "Giant Steps", Artist: "John Coltrane", Price: 1499, Stock: 1},
+		{Title: "Rock & <Roll>", Artist: "Various", Price: 999, Stock: 1},
+	} {
+		id, _ := store.AddAlbum(ctx, alb)
+		ids = append(ids, id)
+	}
+
+	matches, err := store.SearchCatalog(ctx, "blue TRAIN", 10)
+	if err != nil {
+		t.Fatalf("SearchCatalog failed: %v", err)
+	}
+	if len(matches) != 2 || matches[0].Album.Title != "Blue Train" || matches[0].Score <= matches[1].Score {
+		t.Fatalf("Expected Blue Train ranked above Kind of Blue, got %+v", matches)
+	}
+	if matches[0].TitleHighlight != "<mark>Blue</mark> <mark>Train</mark>" || matches[1].TitleHighlight != "Kind of <mark>Blue</mark>" {
+		t.Errorf("Unexpected title highlights %q and %q", matches[0].TitleHighlight, matches[1].TitleHighlight)
+	}
+
+	matches, _ = store.SearchCatalog(ctx, "coltr", 10)
+	if len(matches) != 2 || matches[0].ArtistHighlight != "John <mark>Coltrane</mark>" {
+		t.Errorf("Expected a partial word to match both Coltrane albums, got %+v", matches)
+	}
+
+	matches, _ = store.SearchCatalog(ctx, "coltrain", 10)
+	if len(matches) != 2 || matches[0].ArtistHighlight != "John <mark>Coltrane</mark>" {
+		t.Errorf("Expected a misspelled word to match both Coltrane albums, got %+v", matches)
+	}
+	matches, _ = store.SearchCatalog(ctx, "giant coltrain", 10)
+	if len(matches) != 2 || matches[0].Album.ID != ids[2] || matches[0].Score <= matches[1].Score {
+		t.Errorf("Expected Giant Steps ranked above the album matched by a typo only, got %+v", matches)
+	}
+	if matches, _ = store.SearchCatalog(ctx, "blux", 10); len(matches) != 2 {
+		t.Errorf("Expected one typo in a short word to match both Blue albums, got %+v", matches)
+	}
+
+	matches, _ = store.SearchCatalog(ctx, "roll", 10)
+	if len(matches) != 1 || matches[0].TitleHighlight != "Rock &amp; &lt;<mark>Roll</mark>&gt;" {
+		t.Errorf("Expected an escaped highlight, got %+v", matches)
+	}
+
+	// Short words and stopwords, which the MySQL FULLTEXT index leaves out
+	matches, _ = store.SearchCatalog(ctx, "of", 10)
+	if len(matches) != 1 || matches[0].TitleHighlight != "Kind <mark>of</mark> Blue" {
+		t.Errorf("Expected a stopword to match Kind of Blue, got %+v", matches)
+	}
+
+	title := "Ballads"
+	store.UpdateAlbum(ctx, ids[2], models.AlbumUpdate{Title: &title})
+	db.Exec("UPDATE album SET deleted_at = CURRENT_TIMESTAMP WHERE id = ?", ids[1])
+	matches, _ = store.SearchCatalog(ctx, "ballads coltrane", 10)
+	if len(matches) != 1 || matches[0].Album.ID != ids[2] {
+		t.Errorf("Expected only the renamed album, got %+v", matches)
+	}
+}
+
+// TestSearchCatalogAction tests the searchCatalog response and query validation
+func TestSearchCatalogAction(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	store.AddAlbum(context.Background(), models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1999, Stock: 1})
+	conn := dialTestServer(t, store)
+
+	send := func(data interface{}) (models.WSResponse, []models.CatalogMatch) {
+		t.Helper()
+		if err := conn.WriteJSON(models.WSMessage{Action: "searchCatalog", Data: data}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		var response struct {
+			models.WSResponse
+			Data []models.CatalogMatch `json:"data"`
+		}
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return response.WSResponse, response.Data
+	}
+
+	response, matches := send(map[string]interface{}{"query": "train", "limit": 5})
+	if !response.Success || len(matches) != 1 || matches[0].Album.Title != "Blue Train" {
+		t.Fatalf("Expected Blue Train, got %+v", response)
+	}
+	if response, matches := send(map[string]interface{}{"query": "zzz"}); !response.Success || matches == nil || len(matches) != 0 {
+		t.Errorf("Expected an empty list for no matches, got %+v", response)
+	}
+
+	for _, data := range []interface{}{"train", map[string]interface{}{"query": " -*- "}, map[string]interface{}{"query": 7}} {
+		if response, _ := send(data); response.Success || response.Error.Code != "VALIDATION_FAILED" {
+			t.Errorf("Expected %v to be rejected, got %+v", data, response)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"example/data-access/internal/config"
+)
+
+// TestConfigPrecedence tests that flags override env vars, which override the config file
+func TestConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{"database": {"host": "file-host", "port": 3307, "name": "file-db", "max_open_conns": 40, "conn_max_lifetime": "1m"}}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	t.Setenv("DBHOST", "env-host")
+	t.Setenv("DBPORT", "3308")
+	t.Setenv("DB_CONNECT_TIMEOUT", "3s")
+
+	cfg, args, err := config.Load([]string{"-config", path, "-db-port", "3309", "migrate", "up"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	d := cfg.Database
+	if d.Name != "file-db" || d.MaxOpenConns != 40 || d.ConnMaxLifetime.Duration != time.Minute {
+		t.Errorf("Expected file values for name, max open conns and lifetime, got %+v", d)
+	}
+	if d.Host != "env-host" {
+		t.Errorf("Expected env to override file host, got %q", d.Host)
+	}
+	if d.ConnectTimeout.Duration != 3*time.Second {
+		t.Errorf("Expected env connect timeout 3s, got %s", d.ConnectTimeout)
+	}
+	if d.Port != 3309 {
+		t.Errorf("Expected flag to override env port, got %d", d.Port)
+	}
+	if len(args) != 2 || args[0] != "migrate" {
+		t.Errorf("Expected remaining args [migrate up], got %v", args)
+	}
+}
+
+// TestConfigValidation tests that every invalid setting is reported
+func TestConfigValidation(t *testing.T) {
+	_, _, err := config.Load([]string{"-db-port", "0", "-db-tls", "maybe", "-db-max-open-conns", "5", "-db-max-idle-conns", "10"})
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+
+	for _, want := range []string{"port", "tls", "max idle conns"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got %v", want, err)
+		}
+	}
+}
+
+// TestConfigSummaryRedactsPassword tests that the startup summary never contains the password
+func TestConfigSummaryRedactsPassword(t *testing.T) {
+	cfg := config.Default()
+	cfg.Database.Password = "hunter2"
+
+	for _, field := range cfg.Database.SummaryFields() {
+		if s, ok := field.(string); ok && strings.Contains(s, "hunter2") {
+			t.Fatal("Summary contains the database password")
+		}
+	}
+}
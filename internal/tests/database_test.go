@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"example/data-access/internal/config"
+	"example/data-access/internal/server"
+)
+
+// TestMemoryDatabaseOutlivesConnLifetime tests that the pool settings do not
+// recycle the single :memory: connection and with it the whole database
+func TestMemoryDatabaseOutlivesConnLifetime(t *testing.T) {
+	autoMigrate := true
+	cfg := config.Database{
+		Driver:          "sqlite",
+		SQLitePath:      ":memory:",
+		ConnectTimeout:  config.Duration{Duration: time.Second},
+		MaxOpenConns:    25,
+		MaxIdleConns:    0,
+		ConnMaxLifetime: config.Duration{Duration: 10 * time.Millisecond},
+		AutoMigrate:     &autoMigrate,
+	}
+	if err := server.InitDatabase(cfg); err != nil {
+		t.Fatalf("InitDatabase failed: %v", err)
+	}
+	defer server.CloseDatabase()
+
+	db := server.GetDB()
+	if _, err := db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", "Blue Train", "John Coltrane", 1000, 1); err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM album").Scan(&count); err != nil || count != 1 {
+		t.Errorf("Expected the album to survive past the connection lifetime, got %d (err: %v)", count, err)
+	}
+}
@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"example/data-access/internal/models"
+	"example/data-access/internal/repository"
+)
+
+// TestStoreTypedErrors tests that SQLite failures are mapped to the repository's domain errors
+func TestStoreTypedErrors(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	userID, _ := store.AddUser(ctx, models.User{Username: "buyer", Email: "buyer@example.com"})
+	albumID, _ := store.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 1})
+
+	tests := []struct {
+		name string
+		run  func() error
+		want error
+	}{
+		{"missing album", func() error { _, err := store.GetAlbumByID(ctx, 999); return err }, repository.ErrNotFound},
+		{"missing user", func() error { _, err := store.GetUserByID(ctx, 999); return err }, repository.ErrNotFound},
+		{"purchase of missing album", func() error {
+			_, _, err := store.AddPurchase(ctx, models.Purchase{UserID: userID, AlbumID: 999, Quantity: 1})
+			return err
+		}, repository.ErrNotFound},
+		{"purchase over stock", func() error {
+			_, _, err := store.AddPurchase(ctx, models.Purchase{UserID: userID, AlbumID: albumID, Quantity: 2})
+			return err
+		}, repository.ErrInsufficientStock},
+		{"purchase by missing user", func() error {
+			_, _, err := store.AddPurchase(ctx, models.Purchase{UserID: 999, AlbumID: albumID, Quantity: 1})
+			return err
+		}, repository.ErrInvalidReference},
+		{"duplicate username", func() error {
+			_, err := store.AddUser(ctx, models.User{Username: "buyer", Email: "other@example.com"})
+			return err
+		}, repository.ErrDuplicate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
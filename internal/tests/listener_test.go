@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"example/data-access/internal/config"
+	"example/data-access/internal/models"
+	"example/data-access/internal/server"
+
+	"github.com/gorilla/websocket"
+)
+
+// serveListener serves the WebSocket handler on the configured listener
+func serveListener(t *testing.T, cfg config.Server) net.Listener {
+	server.SetStore(&stubStore{albums: []models.Album{{ID: 1, Title: "Giant Steps"}}})
+
+	ln, err := server.Listen(cfg)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	srv := &http.Server{Handler: http.HandlerFunc(server.NewServer().HandleWebSocket)}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	return ln
+}
+
+// assertGetAlbums sends getAlbums over conn and checks for a successful response
+func assertGetAlbums(t *testing.T, conn *websocket.Conn) {
+	if err := conn.WriteJSON(models.WSMessage{Action: "getAlbums"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var response models.WSResponse
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !response.Success {
+		t.Errorf("Expected success response, got %+v", response)
+	}
+}
+
+// TestListenSelfSignedTLS tests serving wss:// with a generated certificate
+func TestListenSelfSignedTLS(t *testing.T) {
+	cfg := config.Server{Addr: "127.0.0.1:0", TLSSelfSigned: true}
+	ln := serveListener(t, cfg)
+
+	dialer := websocket.Dialer{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	conn, _, err := dialer.Dial("wss://"+ln.Addr().String()+"/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to dial wss: %v", err)
+	}
+	defer conn.Close()
+
+	assertGetAlbums(t, conn)
+}
+
+// TestListenUnixSocketPathInUse tests that Listen refuses a socket path that
+// holds a regular file or the socket of a running server, and leaves it alone
+func TestListenUnixSocketPathInUse(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "data.db")
+	if err := os.WriteFile(file, []byte("keep me"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if ln, err := server.Listen(config.Server{UnixSocket: file}); err == nil {
+		ln.Close()
+		t.Fatal("Expected Listen to fail on a regular file")
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "keep me" {
+		t.Errorf("Expected the file to be left alone, got %q, %v", data, err)
+	}
+
+	socket := filepath.Join(dir, "api.sock")
+	serveListener(t, config.Server{UnixSocket: socket})
+	if ln, err := server.Listen(config.Server{UnixSocket: socket}); err == nil {
+		ln.Close()
+		t.Fatal("Expected Listen to fail on the socket of a running server")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		t.Fatalf("Expected the running server to keep its socket: %v", err)
+	}
+	conn.Close()
+}
+
+// TestListenUnixSocketStale tests that Listen replaces a socket nobody listens on
+func TestListenUnixSocketStale(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: socket, Net: "unix"})
+	if err != nil {
+		t.Fatalf("Failed to create socket: %v", err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+	if _, err := os.Lstat(socket); err != nil {
+		t.Fatalf("Expected the stale socket to be left behind: %v", err)
+	}
+
+	ln, err := server.Listen(config.Server{UnixSocket: socket})
+	if err != nil {
+		t.Fatalf("Listen failed on a stale socket: %v", err)
+	}
+	ln.Close()
+}
+
+// TestListenUnixSocket tests serving the WebSocket API on a Unix domain socket
+func TestListenUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	serveListener(t, config.Server{UnixSocket: socket})
+
+	dialer := websocket.Dialer{
+		NetDialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	conn, _, err := dialer.Dial("ws://unix/ws", nil)
+	if err != nil {
+		t.Fatalf("Failed to dial unix socket: %v", err)
+	}
+	defer conn.Close()
+
+	assertGetAlbums(t, conn)
+}
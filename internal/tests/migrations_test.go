@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"testing"
+
+	"example/data-access/internal/migrations"
+	"example/data-access/internal/repository"
+)
+
+// TestMigrationsUpDownSQLite tests applying, inspecting and rolling back the SQLite migrations
+func TestMigrationsUpDownSQLite(t *testing.T) {
+	db, err := repository.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+
+	all, err := migrations.Load("sqlite")
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	applied, err := migrations.Up(db, "sqlite")
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if applied != len(all) {
+		t.Errorf("Expected %d migrations applied, got %d", len(all), applied)
+	}
+
+	// Running again is a no-op
+	applied, err = migrations.Up(db, "sqlite")
+	if err != nil || applied != 0 {
+		t.Errorf("Expected second Up to apply nothing, got %d (err: %v)", applied, err)
+	}
+
+	statuses, err := migrations.GetStatus(db, "sqlite")
+	if err != nil {
+		t.Fatalf("GetStatus failed: %v", err)
+	}
+	for _, s := range statuses {
+		if !s.Applied {
+			t.Errorf("Expected migration %04d_%s to be applied", s.Version, s.Name)
+		}
+	}
+
+	rolledBack, err := migrations.Down(db, "sqlite", len(all))
+	if err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	if rolledBack != len(all) {
+		t.Errorf("Expected %d migrations rolled back, got %d", len(all), rolledBack)
+	}
+
+	var tables int
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('album', 'user', 'purchase')").Scan(&tables)
+	if tables != 0 {
+		t.Errorf("Expected all tables dropped, %d remain", tables)
+	}
+}
+
+// TestMigrationsLoadMySQL tests that every MySQL migration has both directions
+func TestMigrationsLoadMySQL(t *testing.T) {
+	all, err := migrations.Load("mysql")
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+
+	for i, m := range all {
+		if m.Up == "" || m.Down == "" {
+			t.Errorf("Migration %04d_%s is missing an up or down script", m.Version, m.Name)
+		}
+		if i > 0 && all[i-1].Version >= m.Version {
+			t.Errorf("Migrations out of order at %04d_%s", m.Version, m.Name)
+		}
+	}
+}
+
+// TestMigrationMoneyCentsSQLite tests that prices stored as REAL are converted
+// to INTEGER cents and back
+func TestMigrationMoneyCentsSQLite(t *testing.T) {
+	db, err := repository.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+
+	all, err := migrations.Load("sqlite")
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	// Roll back to just before 0008_money_cents
+	since := 0
+	for _, m := range all {
+		if m.Version >= 8 {
+			since++
+		}
+	}
+
+	if _, err := migrations.Up(db, "sqlite"); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if _, err := migrations.Down(db, "sqlite", since); err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", "Blue Train", "John Coltrane", 29.99, 1)
+	db.Exec("INSERT INTO user (username, email) VALUES (?, ?)", "buyer", "buyer@example.com")
+	db.Exec("INSERT INTO purchase (user_id, album_id, quantity, unit_price) VALUES (?, ?, ?, ?)", 1, 1, 1, 0.29)
+
+	if _, err := migrations.Up(db, "sqlite"); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	var priceType, unitPriceType string
+	var price, unitPrice int64
+	err = db.QueryRow("SELECT typeof(a.price), a.price, typeof(p.unit_price), p.unit_price FROM album a JOIN purchase p ON p.album_id = a.id").
+		Scan(&priceType, &price, &unitPriceType, &unitPrice)
+	if err != nil {
+		t.Fatalf("Failed to read prices: %v", err)
+	}
+	if priceType != "integer" || price != 2999 || unitPriceType != "integer" || unitPrice != 29 {
+		t.Errorf("Expected integer cents 2999 and 29, got %s %d and %s %d", priceType, price, unitPriceType, unitPrice)
+	}
+
+	if _, err := migrations.Down(db, "sqlite", since); err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	var restored float64
+	db.QueryRow("SELECT price FROM album").Scan(&restored)
+	if restored != 29.99 {
+		t.Errorf("Expected price 29.99 after rolling back, got %v", restored)
+	}
+}
@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"example/data-access/internal/models"
+)
+
+// TestMoneyParseAndFormat tests decimal parsing, formatting and JSON round trips
+func TestMoneyParseAndFormat(t *testing.T) {
+	cases := map[string]models.Money{"29.99": 2999, "12.5": 1250, "7": 700, "-0.05": -5, "3.100": 310}
+	for text, want := range cases {
+		got, err := models.ParseMoney(text)
+		if err != nil || got != want {
+			t.Errorf("ParseMoney(%q) = %d, %v; want %d", text, got, err, want)
+		}
+	}
+	if _, err := models.ParseMoney("1.999"); err == nil {
+		t.Error("Expected an error for more than 2 decimal places")
+	}
+
+	data, _ := json.Marshal(models.Album{Price: 1250})
+	var album models.Album
+	if err := json.Unmarshal(data, &album); err != nil || album.Price != 1250 {
+		t.Errorf("Expected 12.50 to survive a JSON round trip, got %s (err: %v)", data, err)
+	}
+	if models.Money(-5).String() != "-0.05" {
+		t.Errorf("Expected -0.05, got %s", models.Money(-5))
+	}
+}
+
+// TestMoneySumsExactly tests that repeated sums do not drift the way floats do
+func TestMoneySumsExactly(t *testing.T) {
+	price, _ := models.ParseMoney("29.99")
+	var total models.Money
+	for i := 0; i < 1000; i++ {
+		total += price.Times(3)
+	}
+	if total.String() != "89970.00" {
+		t.Errorf("Expected 89970.00, got %s", total)
+	}
+
+	if _, ok := models.MoneyFromFloat(0.1 + 0.2); !ok {
+		t.Error("Expected 0.1 + 0.2 to round to 0.30")
+	}
+	if _, ok := models.MoneyFromFloat(9.999); ok {
+		t.Error("Expected 9.999 to be rejected")
+	}
+}
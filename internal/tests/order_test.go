@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"example/data-access/internal/models"
+	"example/data-access/internal/repository"
+)
+
+// TestPlaceOrder tests that every line of an order is purchased and stock decremented
+func TestPlaceOrder(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	userID, _ := store.AddUser(ctx, models.User{Username: "buyer", Email: "buyer@example.com"})
+	album1, _ := store.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 5})
+	album2, _ := store.AddAlbum(ctx, models.Album{Title: "Jeru", Artist: "Gerry Mulligan", Price: 2000, Stock: 5})
+
+	// Lines out of album order still come back in request order
+	order, stock, err := store.PlaceOrder(ctx, models.Order{UserID: userID, Lines: []models.OrderLine{
+		{AlbumID: album2, Quantity: 1},
+		{AlbumID: album1, Quantity: 2},
+	}})
+	if err != nil {
+		t.Fatalf("PlaceOrder failed: %v", err)
+	}
+	if order.ID == 0 || len(order.Lines) != 2 || order.Lines[0].AlbumID != album2 {
+		t.Fatalf("Unexpected order: %+v", order)
+	}
+	if len(stock) != 2 || stock[album1] != 3 || stock[album2] != 4 {
+		t.Errorf("Expected stock left of 3 and 4, got %v", stock)
+	}
+
+	for _, line := range order.Lines {
+		var orderID, quantity int64
+		db.QueryRow("SELECT order_id, quantity FROM purchase WHERE id = ?", line.ID).Scan(&orderID, &quantity)
+		if orderID != order.ID || quantity != int64(line.Quantity) {
+			t.Errorf("Line %d: expected purchase of %d in order %d, got %d in order %d", line.ID, line.Quantity, order.ID, quantity, orderID)
+		}
+	}
+
+	summary, _ := store.GetUserPurchaseSummary(ctx, userID)
+	if summary.TotalCost != 4000 {
+		t.Errorf("Expected order lines in the summary total of 40.00, got %v", summary.TotalCost)
+	}
+}
+
+// TestPlaceOrderRollsBack tests that one failing line leaves no order, purchase or stock change
+func TestPlaceOrderRollsBack(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	userID, _ := store.AddUser(ctx, models.User{Username: "buyer", Email: "buyer@example.com"})
+	album1, _ := store.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 5})
+	album2, _ := store.AddAlbum(ctx, models.Album{Title: "Jeru", Artist: "Gerry Mulligan", Price: 2000, Stock: 1})
+
+	_, _, err := store.PlaceOrder(ctx, models.Order{UserID: userID, Lines: []models.OrderLine{
+		{AlbumID: album1, Quantity: 2},
+		{AlbumID: album2, Quantity: 3},
+	}})
+	if !errors.Is(err, repository.ErrInsufficientStock) {
+		t.Fatalf("Expected ErrInsufficientStock, got %v", err)
+	}
+	var lineErr *repository.OrderLineError
+	if !errors.As(err, &lineErr) || lineErr.Line != 1 || lineErr.AlbumID != album2 {
+		t.Errorf("Expected the error to name line 1, got %v", err)
+	}
+
+	var orders, purchases, stock int
+	db.QueryRow("SELECT COUNT(*) FROM orders").Scan(&orders)
+	db.QueryRow("SELECT COUNT(*) FROM purchase").Scan(&purchases)
+	db.QueryRow("SELECT stock FROM album WHERE id = ?", album1).Scan(&stock)
+	if orders != 0 || purchases != 0 || stock != 5 {
+		t.Errorf("Expected nothing stored, got %d orders, %d purchases and stock %d", orders, purchases, stock)
+	}
+
+	if _, _, err := store.PlaceOrder(ctx, models.Order{UserID: 999, Lines: []models.OrderLine{{AlbumID: album1, Quantity: 1}}}); !errors.Is(err, repository.ErrInvalidReference) {
+		t.Errorf("Expected ErrInvalidReference for an unknown user, got %v", err)
+	}
+}
+
+// TestPlaceOrderAction tests that a failing line is named in the error fields
+func TestPlaceOrderAction(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userResult, _ := db.Exec("INSERT INTO user (username, email) VALUES (?, ?)", "buyer", "buyer@example.com")
+	userID, _ := userResult.LastInsertId()
+	albumResult, _ := db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", "Blue Train", "John Coltrane", 1000, 3)
+	albumID, _ := albumResult.LastInsertId()
+
+	conn := dialTestServer(t, repository.NewSQLiteStore(db))
+
+	msg := models.WSMessage{Action: "placeOrder", Data: map[string]interface{}{
+		"user_id": userID,
+		"lines": []map[string]interface{}{
+			{"album_id": albumID, "quantity": 1},
+			{"album_id": 999, "quantity": 1},
+		},
+	}}
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var response models.WSResponse
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if response.Success || response.Error == nil || response.Error.Code != "NOT_FOUND" {
+		t.Fatalf("Expected NOT_FOUND, got %+v", response)
+	}
+	if len(response.Error.Fields) != 1 || response.Error.Fields[0] != "lines[1].album_id" {
+		t.Errorf("Expected field lines[1].album_id, got %v", response.Error.Fields)
+	}
+	if response.Error.Details["line"] != float64(1) {
+		t.Errorf("Expected line 1 in details, got %v", response.Error.Details)
+	}
+}
@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"example/data-access/internal/models"
+	"example/data-access/internal/repository"
+)
+
+// TestListAlbumsPages tests that keyset pages cover every visible album once
+func TestListAlbumsPages(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		store.AddAlbum(ctx, models.Album{Title: fmt.Sprintf("Album %d", i), Artist: "Artist", Price: 1000, Stock: 1})
+	}
+	store.DeleteAlbum(ctx, 3, false)
+
+	var ids []int64
+	page := models.PageRequest{Limit: 2}
+	for {
+		albums, hasMore, err := store.ListAlbums(ctx, page)
+		if err != nil {
+			t.Fatalf("ListAlbums failed: %v", err)
+		}
+		if len(albums) > page.Limit {
+			t.Fatalf("Expected at most %d albums, got %d", page.Limit, len(albums))
+		}
+		for _, album := range albums {
+			ids = append(ids, album.ID)
+		}
+		if !hasMore {
+			break
+		}
+		page.After = albums[len(albums)-1].ID
+	}
+
+	if fmt.Sprint(ids) != "[1 2 4 5]" {
+		t.Errorf("Expected albums [1 2 4 5] across pages, got %v", ids)
+	}
+}
+
+// TestListUsersPurchaseSummaryLimitsUsers tests that the summary limit counts users, not purchase rows
+func TestListUsersPurchaseSummaryLimitsUsers(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	albumID, _ := store.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 10})
+	first, _ := store.AddUser(ctx, models.User{Username: "first", Email: "first@example.com"})
+	store.AddUser(ctx, models.User{Username: "second", Email: "second@example.com"})
+	for i := 0; i < 3; i++ {
+		store.AddPurchase(ctx, models.Purchase{UserID: first, AlbumID: albumID, Quantity: 1})
+	}
+
+	summaries, hasMore, err := store.ListUsersPurchaseSummary(ctx, models.PageRequest{Limit: 1})
+	if err != nil {
+		t.Fatalf("ListUsersPurchaseSummary failed: %v", err)
+	}
+	if len(summaries) != 1 || len(summaries[0].Purchases) != 3 || !hasMore {
+		t.Fatalf("Expected the first user with all 3 purchases and more to follow, got %+v (has_more %v)", summaries, hasMore)
+	}
+
+	summaries, hasMore, _ = store.ListUsersPurchaseSummary(ctx, models.PageRequest{After: first, Limit: 1})
+	if len(summaries) != 1 || summaries[0].Username != "second" || hasMore {
+		t.Errorf("Expected only the second user on the last page, got %+v (has_more %v)", summaries, hasMore)
+	}
+}
+
+// TestGetAlbumsPagination tests following next_cursor and rejecting a bad limit or cursor
+func TestGetAlbumsPagination(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	for i := 1; i <= 3; i++ {
+		db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", fmt.Sprintf("Album %d", i), "Artist", 1000, 1)
+	}
+	conn := dialTestServer(t, repository.NewSQLiteStore(db))
+
+	getAlbums := func(data map[string]interface{}) (models.WSResponse, models.Page[models.Album]) {
+		t.Helper()
+		if err := conn.WriteJSON(models.WSMessage{Action: "getAlbums", Data: data}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		var response struct {
+			models.WSResponse
+			Data models.Page[models.Album] `json:"data"`
+		}
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return response.WSResponse, response.Data
+	}
+
+	response, first := getAlbums(map[string]interface{}{"limit": 2})
+	if !response.Success || len(first.Items) != 2 || !first.HasMore || first.NextCursor == "" {
+		t.Fatalf("Expected a full first page with a cursor, got %+v", first)
+	}
+
+	_, second := getAlbums(map[string]interface{}{"limit": 2, "cursor": first.NextCursor})
+	if len(second.Items) != 1 || second.Items[0].Title != "Album 3" || second.HasMore || second.NextCursor != "" {
+		t.Errorf("Expected only Album 3 on the last page, got %+v", second)
+	}
+
+	for _, data := range []map[string]interface{}{{"limit": 0}, {"limit": 2.5}, {"limit": 501}} {
+		if response, _ := getAlbums(data); response.Success || response.Error.Fields[0] != "limit" {
+			t.Errorf("Expected limit %v to be rejected, got %+v", data["limit"], response)
+		}
+	}
+	if response, _ := getAlbums(map[string]interface{}{"cursor": "not-a-cursor"}); response.Success || response.Error.Fields[0] != "cursor" {
+		t.Errorf("Expected a bad cursor to be rejected, got %+v", response)
+	}
+}
+
+// TestGetAlbumByArtistPagination tests that the bare and object forms of
+// getAlbumByArtist return pages that follow next_cursor
+func TestGetAlbumByArtistPagination(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	for i := 1; i <= 3; i++ {
+		db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", fmt.Sprintf("Album %d", i), "Artist", 1000, 1)
+	}
+	db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", "Other", "Someone Else", 1000, 1)
+	conn := dialTestServer(t, repository.NewSQLiteStore(db))
+
+	getAlbumByArtist := func(data interface{}) (models.WSResponse, models.Page[models.Album]) {
+		t.Helper()
+		if err := conn.WriteJSON(models.WSMessage{Action: "getAlbumByArtist", Data: data}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		var response struct {
+			models.WSResponse
+			Data models.Page[models.Album] `json:"data"`
+		}
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return response.WSResponse, response.Data
+	}
+
+	if response, all := getAlbumByArtist("Artist"); !response.Success || len(all.Items) != 3 || all.HasMore {
+		t.Fatalf("Expected all 3 albums on one page for a bare artist name, got %+v", all)
+	}
+
+	_, first := getAlbumByArtist(map[string]interface{}{"artist": "Artist", "limit": 2})
+	if len(first.Items) != 2 || !first.HasMore || first.NextCursor == "" {
+		t.Fatalf("Expected a full first page with a cursor, got %+v", first)
+	}
+	_, second := getAlbumByArtist(map[string]interface{}{"artist": "Artist", "limit": 2, "cursor": first.NextCursor})
+	if len(second.Items) != 1 || second.Items[0].Title != "Album 3" || second.HasMore {
+		t.Errorf("Expected only Album 3 on the last page, got %+v", second)
+	}
+
+	for _, tc := range []struct {
+		data  map[string]interface{}
+		field string
+	}{
+		{map[string]interface{}{"limit": 2}, "artist"},
+		{map[string]interface{}{"artist": "Artist", "limit": 0}, "limit"},
+		{map[string]interface{}{"artist": "Artist", "cursor": "not-a-cursor"}, "cursor"},
+	} {
+		if response, _ := getAlbumByArtist(tc.data); response.Success || response.Error.Fields[0] != tc.field {
+			t.Errorf("Expected %v to be rejected on %s, got %+v", tc.data, tc.field, response)
+		}
+	}
+}
+
+// TestGetPurchasesByUserIDPagination tests that getPurchasesByUserID pages
+// through one user's purchases only
+func TestGetPurchasesByUserIDPagination(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	albumID, _ := store.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 10})
+	buyer, _ := store.AddUser(ctx, models.User{Username: "buyer", Email: "buyer@example.com"})
+	other, _ := store.AddUser(ctx, models.User{Username: "other", Email: "other@example.com"})
+	for _, userID := range []int64{buyer, other, buyer, buyer} {
+		store.AddPurchase(ctx, models.Purchase{UserID: userID, AlbumID: albumID, Quantity: 1})
+	}
+	conn := dialTestServer(t, store)
+
+	getPurchases := func(data map[string]interface{}) (models.WSResponse, models.Page[models.Purchase]) {
+		t.Helper()
+		if err := conn.WriteJSON(models.WSMessage{Action: "getPurchasesByUserID", Data: data}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		var response struct {
+			models.WSResponse
+			Data models.Page[models.Purchase] `json:"data"`
+		}
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return response.WSResponse, response.Data
+	}
+
+	response, first := getPurchases(map[string]interface{}{"user_id": buyer, "limit": 2})
+	if !response.Success || len(first.Items) != 2 || !first.HasMore || first.Items[1].ID != 3 {
+		t.Fatalf("Expected the buyer's first 2 purchases with more to follow, got %+v", first)
+	}
+	_, second := getPurchases(map[string]interface{}{"user_id": buyer, "limit": 2, "cursor": first.NextCursor})
+	if len(second.Items) != 1 || second.Items[0].ID != 4 || second.HasMore {
+		t.Errorf("Expected only purchase 4 on the last page, got %+v", second)
+	}
+
+	if response, _ := getPurchases(map[string]interface{}{"user_id": buyer, "limit": 2.5}); response.Success || response.Error.Fields[0] != "limit" {
+		t.Errorf("Expected a fractional limit to be rejected, got %+v", response)
+	}
+}
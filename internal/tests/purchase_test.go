@@ -1,15 +1,17 @@
 package tests
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"example/data-access/internal/logger"
+	"example/data-access/internal/migrations"
 	"example/data-access/internal/models"
 	"example/data-access/internal/repository"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 func init() {
@@ -19,42 +21,13 @@ func init() {
 
 // setupTestDB creates an in-memory SQLite database for testing
 func setupTestDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("sqlite3", ":memory:")
+	db, err := repository.OpenSQLite(":memory:")
 	if err != nil {
 		t.Fatalf("Failed to open test database: %v", err)
 	}
 
-	// Enable WAL mode for better concurrency support
-	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
-		t.Fatalf("Failed to enable WAL: %v", err)
-	}
-
-	// Create tables
-	schema := `
-	CREATE TABLE user (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT NOT NULL,
-		email TEXT NOT NULL
-	);
-	CREATE TABLE album (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		title TEXT NOT NULL,
-		artist TEXT NOT NULL,
-		price REAL NOT NULL,
-		stock INTEGER NOT NULL
-	);
-	CREATE TABLE purchase (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id INTEGER NOT NULL,
-		album_id INTEGER NOT NULL,
-		quantity INTEGER NOT NULL,
-		FOREIGN KEY(user_id) REFERENCES user(id),
-		FOREIGN KEY(album_id) REFERENCES album(id)
-	);
-	`
-
-	if _, err := db.Exec(schema); err != nil {
-		t.Fatalf("Failed to create schema: %v", err)
+	if _, err := migrations.Up(db, "sqlite"); err != nil {
+		t.Fatalf("Failed to apply migrations: %v", err)
 	}
 
 	return db
@@ -64,17 +37,18 @@ func setupTestDB(t *testing.T) *sql.DB {
 func TestAddPurchaseSequential(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
+	store := repository.NewSQLiteStore(db)
 
 	// Create test data
 	userResult, _ := db.Exec("INSERT INTO user (username, email) VALUES (?, ?)", "testuser", "test@example.com")
 	userID, _ := userResult.LastInsertId()
 
-	albumResult, _ := db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", "Album", "Artist", 29.99, 5)
+	albumResult, _ := db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", "Album", "Artist", 2999, 5)
 	albumID, _ := albumResult.LastInsertId()
 
 	// First purchase
 	purchase1 := models.Purchase{UserID: userID, AlbumID: albumID, Quantity: 2}
-	id1, err := repository.AddPurchase(db, purchase1)
+	id1, _, err := store.AddPurchase(context.Background(), purchase1)
 	if err != nil {
 		t.Fatalf("First purchase failed: %v", err)
 	}
@@ -91,7 +65,7 @@ func TestAddPurchaseSequential(t *testing.T) {
 
 	// Second purchase
 	purchase2 := models.Purchase{UserID: userID, AlbumID: albumID, Quantity: 2}
-	id2, err := repository.AddPurchase(db, purchase2)
+	id2, _, err := store.AddPurchase(context.Background(), purchase2)
 	if err != nil {
 		t.Fatalf("Second purchase failed: %v", err)
 	}
@@ -111,12 +85,13 @@ func TestAddPurchaseSequential(t *testing.T) {
 func TestAddPurchaseConcurrentOutOfStock(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
+	store := repository.NewSQLiteStore(db)
 
 	// Create test data
 	userResult, _ := db.Exec("INSERT INTO user (username, email) VALUES (?, ?)", "testuser", "test@example.com")
 	userID, _ := userResult.LastInsertId()
 
-	albumResult, _ := db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", "Limited Album", "Artist", 29.99, 3)
+	albumResult, _ := db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", "Limited Album", "Artist", 2999, 3)
 	albumID, _ := albumResult.LastInsertId()
 
 	var wg sync.WaitGroup
@@ -127,7 +102,7 @@ func TestAddPurchaseConcurrentOutOfStock(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		purchase := models.Purchase{UserID: userID, AlbumID: albumID, Quantity: 2}
-		_, purchase1Err = repository.AddPurchase(db, purchase)
+		_, _, purchase1Err = store.AddPurchase(context.Background(), purchase)
 	}()
 
 	// Second concurrent purchase (should fail due to insufficient stock)
@@ -135,7 +110,7 @@ func TestAddPurchaseConcurrentOutOfStock(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		purchase := models.Purchase{UserID: userID, AlbumID: albumID, Quantity: 2}
-		_, purchase2Err = repository.AddPurchase(db, purchase)
+		_, _, purchase2Err = store.AddPurchase(context.Background(), purchase)
 	}()
 
 	wg.Wait()
@@ -172,17 +147,18 @@ func TestAddPurchaseConcurrentOutOfStock(t *testing.T) {
 func TestAddPurchaseOutOfStock(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
+	store := repository.NewSQLiteStore(db)
 
 	// Create test data
 	userResult, _ := db.Exec("INSERT INTO user (username, email) VALUES (?, ?)", "testuser", "test@example.com")
 	userID, _ := userResult.LastInsertId()
 
-	albumResult, _ := db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", "Album", "Artist", 29.99, 1)
+	albumResult, _ := db.Exec("INSERT INTO album (title, artist, price, stock) VALUES (?, ?, ?, ?)", "Album", "Artist", 2999, 1)
 	albumID, _ := albumResult.LastInsertId()
 
 	// Try to purchase more than available stock
 	purchase := models.Purchase{UserID: userID, AlbumID: albumID, Quantity: 5}
-	_, err := repository.AddPurchase(db, purchase)
+	_, _, err := store.AddPurchase(context.Background(), purchase)
 
 	if err == nil {
 		t.Error("Expected error for out of stock purchase")
@@ -202,3 +178,275 @@ func TestAddPurchaseOutOfStock(t *testing.T) {
 		t.Errorf("Expected stock 1 (unchanged), got %d", stock)
 	}
 }
+
+// TestUserPurchaseSummary tests that summaries join album details and total the subtotals
+func TestUserPurchaseSummary(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+
+	userID, _ := store.AddUser(context.Background(), models.User{Username: "buyer", Email: "buyer@example.com"})
+	idleUserID, _ := store.AddUser(context.Background(), models.User{Username: "browser", Email: "browser@example.com"})
+	album1, _ := store.AddAlbum(context.Background(), models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 5})
+	album2, _ := store.AddAlbum(context.Background(), models.Album{Title: "Jeru", Artist: "Gerry Mulligan", Price: 2000, Stock: 5})
+
+	if _, _, err := store.AddPurchase(context.Background(), models.Purchase{UserID: userID, AlbumID: album1, Quantity: 2}); err != nil {
+		t.Fatalf("Purchase failed: %v", err)
+	}
+	if _, _, err := store.AddPurchase(context.Background(), models.Purchase{UserID: userID, AlbumID: album2, Quantity: 1}); err != nil {
+		t.Fatalf("Purchase failed: %v", err)
+	}
+
+	summary, err := store.GetUserPurchaseSummary(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetUserPurchaseSummary failed: %v", err)
+	}
+	if summary.Username != "buyer" || len(summary.Purchases) != 2 {
+		t.Fatalf("Unexpected summary: %+v", summary)
+	}
+	if summary.Purchases[0].AlbumTitle != "Blue Train" || summary.Purchases[0].Subtotal != 2000 {
+		t.Errorf("Unexpected first purchase detail: %+v", summary.Purchases[0])
+	}
+	if summary.TotalCost != 4000 {
+		t.Errorf("Expected total cost 40.00, got %v", summary.TotalCost)
+	}
+
+	summaries, err := store.GetAllUsersPurchaseSummary(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllUsersPurchaseSummary failed: %v", err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("Expected 2 user summaries, got %d", len(summaries))
+	}
+	if summaries[1].UserID != idleUserID || len(summaries[1].Purchases) != 0 {
+		t.Errorf("Expected user without purchases to have an empty summary, got %+v", summaries[1])
+	}
+}
+
+// TestUserPurchaseSummaryUnknownUser tests that the summary of a user that does
+// not exist is NOT_FOUND rather than an empty summary
+func TestUserPurchaseSummaryUnknownUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+
+	if _, err := store.GetUserPurchaseSummary(context.Background(), 999); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for an unknown user, got %v", err)
+	}
+
+	conn := dialTestServer(t, store)
+	if err := conn.WriteJSON(models.WSMessage{Action: "getUserPurchaseSummary", Data: 999}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var response models.WSResponse
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if response.Success || response.Error == nil || response.Error.Code != "NOT_FOUND" {
+		t.Errorf("Expected NOT_FOUND, got %+v", response)
+	}
+}
+
+// TestCancelPurchase tests partial and full cancellation with stock restoration
+func TestCancelPurchase(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	userID, _ := store.AddUser(ctx, models.User{Username: "buyer", Email: "buyer@example.com"})
+	albumID, _ := store.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 5})
+	purchaseID, _, err := store.AddPurchase(ctx, models.Purchase{UserID: userID, AlbumID: albumID, Quantity: 3})
+	if err != nil {
+		t.Fatalf("Purchase failed: %v", err)
+	}
+
+	p, _, err := store.CancelPurchase(ctx, purchaseID, 1)
+	if err != nil {
+		t.Fatalf("Partial cancel failed: %v", err)
+	}
+	if p.Quantity != 3 || p.CancelledQuantity != 1 {
+		t.Errorf("Expected 1 of 3 units cancelled, got %+v", p)
+	}
+
+	var stock int
+	db.QueryRow("SELECT stock FROM album WHERE id = ?", albumID).Scan(&stock)
+	if stock != 3 {
+		t.Errorf("Expected stock 3 after cancelling 1 unit, got %d", stock)
+	}
+
+	summary, _ := store.GetUserPurchaseSummary(ctx, userID)
+	if len(summary.Purchases) != 1 || summary.Purchases[0].Quantity != 2 || summary.TotalCost != 2000 {
+		t.Errorf("Expected summary to count 2 remaining units, got %+v", summary)
+	}
+
+	if _, _, err := store.CancelPurchase(ctx, purchaseID, 5); !errors.Is(err, repository.ErrNotCancellable) {
+		t.Errorf("Expected ErrNotCancellable cancelling more than remains, got %v", err)
+	}
+
+	// Quantity 0 cancels the rest
+	if p, _, err = store.CancelPurchase(ctx, purchaseID, 0); err != nil || p.CancelledQuantity != 3 {
+		t.Fatalf("Expected full cancel, got %+v (err: %v)", p, err)
+	}
+	db.QueryRow("SELECT stock FROM album WHERE id = ?", albumID).Scan(&stock)
+	if stock != 5 {
+		t.Errorf("Expected stock 5 after full cancel, got %d", stock)
+	}
+
+	summaries, _ := store.GetAllUsersPurchaseSummary(ctx)
+	if len(summaries) != 1 || len(summaries[0].Purchases) != 0 || summaries[0].TotalCost != 0 {
+		t.Errorf("Expected cancelled purchase excluded from summaries, got %+v", summaries)
+	}
+
+	if _, _, err := store.CancelPurchase(ctx, purchaseID, 0); !errors.Is(err, repository.ErrNotCancellable) {
+		t.Errorf("Expected ErrNotCancellable for a fully cancelled purchase, got %v", err)
+	}
+	if _, _, err := store.CancelPurchase(ctx, 999, 1); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing purchase, got %v", err)
+	}
+}
+
+// TestSummaryUsesPurchasePrice tests that summaries price purchases as they
+// were bought, not at the album's current price
+func TestSummaryUsesPurchasePrice(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	userID, _ := store.AddUser(ctx, models.User{Username: "buyer", Email: "buyer@example.com"})
+	albumID, _ := store.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 5})
+	if _, _, err := store.AddPurchase(ctx, models.Purchase{UserID: userID, AlbumID: albumID, Quantity: 2}); err != nil {
+		t.Fatalf("Purchase failed: %v", err)
+	}
+
+	price := models.Money(2500)
+	if _, err := store.UpdateAlbum(ctx, albumID, models.AlbumUpdate{Price: &price}); err != nil {
+		t.Fatalf("UpdateAlbum failed: %v", err)
+	}
+	if _, _, err := store.AddPurchase(ctx, models.Purchase{UserID: userID, AlbumID: albumID, Quantity: 1}); err != nil {
+		t.Fatalf("Purchase failed: %v", err)
+	}
+
+	summary, err := store.GetUserPurchaseSummary(ctx, userID)
+	if err != nil {
+		t.Fatalf("GetUserPurchaseSummary failed: %v", err)
+	}
+	if len(summary.Purchases) != 2 || summary.Purchases[0].Price != 1000 || summary.Purchases[1].Price != 2500 {
+		t.Fatalf("Expected unit prices 10 and 25, got %+v", summary.Purchases)
+	}
+	if summary.Purchases[0].Currency != "USD" {
+		t.Errorf("Expected currency USD, got %q", summary.Purchases[0].Currency)
+	}
+	if summary.TotalCost != 4500 {
+		t.Errorf("Expected total cost 45.00, got %v", summary.TotalCost)
+	}
+
+	summaries, _ := store.GetAllUsersPurchaseSummary(ctx)
+	if len(summaries) != 1 || summaries[0].TotalCost != 4500 {
+		t.Errorf("Expected all-users total cost 45.00, got %+v", summaries)
+	}
+}
+
+// TestPurchaseDateFilter tests that purchases get a creation time and that
+// from is inclusive and to exclusive
+func TestPurchaseDateFilter(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	userID, _ := store.AddUser(ctx, models.User{Username: "buyer", Email: "buyer@example.com"})
+	albumID, _ := store.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 5})
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		id, _, err := store.AddPurchase(ctx, models.Purchase{UserID: userID, AlbumID: albumID, Quantity: 1})
+		if err != nil {
+			t.Fatalf("Purchase failed: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	purchases, _ := store.GetPurchasesByUserID(ctx, userID, models.PurchaseFilter{})
+	if len(purchases) != 3 || time.Since(purchases[0].CreatedAt) > time.Minute {
+		t.Fatalf("Expected 3 purchases created just now, got %+v", purchases)
+	}
+
+	db.Exec("UPDATE purchase SET created_at = ? WHERE id = ?", "2024-01-01 00:00:00", ids[0])
+	db.Exec("UPDATE purchase SET created_at = ? WHERE id = ?", "2024-01-15 12:30:00", ids[1])
+	db.Exec("UPDATE purchase SET created_at = ? WHERE id = ?", "2024-02-01 00:00:00", ids[2])
+
+	january := models.PurchaseFilter{
+		From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	purchases, err := store.GetPurchasesByUserID(ctx, userID, january)
+	if err != nil {
+		t.Fatalf("GetPurchasesByUserID failed: %v", err)
+	}
+	if len(purchases) != 2 || purchases[0].ID != ids[0] || purchases[1].ID != ids[1] {
+		t.Fatalf("Expected the two January purchases, got %+v", purchases)
+	}
+	if want := time.Date(2024, 1, 15, 12, 30, 0, 0, time.UTC); !purchases[1].CreatedAt.Equal(want) {
+		t.Errorf("Expected created_at %v, got %v", want, purchases[1].CreatedAt)
+	}
+
+	page, _, _ := store.ListPurchases(ctx, models.PurchaseFilter{From: january.To}, models.PageRequest{Limit: 10})
+	if len(page) != 1 || page[0].ID != ids[2] {
+		t.Errorf("Expected only the February purchase, got %+v", page)
+	}
+}
+
+// TestGetPurchasesByUserIDDateFilterAction tests the object form of
+// getPurchasesByUserID and the from/to validation
+func TestGetPurchasesByUserIDDateFilterAction(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	userID, _ := store.AddUser(ctx, models.User{Username: "buyer", Email: "buyer@example.com"})
+	albumID, _ := store.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 5})
+	oldID, _, _ := store.AddPurchase(ctx, models.Purchase{UserID: userID, AlbumID: albumID, Quantity: 1})
+	store.AddPurchase(ctx, models.Purchase{UserID: userID, AlbumID: albumID, Quantity: 1})
+	db.Exec("UPDATE purchase SET created_at = ? WHERE id = ?", "2024-01-15 12:30:00", oldID)
+
+	conn := dialTestServer(t, store)
+	getPurchases := func(data interface{}) (models.WSResponse, []models.Purchase) {
+		t.Helper()
+		if err := conn.WriteJSON(models.WSMessage{Action: "getPurchasesByUserID", Data: data}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		var response struct {
+			models.WSResponse
+			Data models.Page[models.Purchase] `json:"data"`
+		}
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return response.WSResponse, response.Data.Items
+	}
+
+	if response, purchases := getPurchases(userID); !response.Success || len(purchases) != 2 {
+		t.Fatalf("Expected both purchases for a bare user ID, got %+v", response)
+	}
+
+	response, purchases := getPurchases(map[string]interface{}{"user_id": userID, "from": "2024-01-01", "to": "2024-01-15T13:00:00+00:00"})
+	if !response.Success || len(purchases) != 1 || purchases[0].ID != oldID {
+		t.Fatalf("Expected only the January purchase, got %+v", purchases)
+	}
+
+	for _, tc := range []struct {
+		data  map[string]interface{}
+		field string
+	}{
+		{map[string]interface{}{"user_id": userID, "from": "15/01/2024"}, "from"},
+		{map[string]interface{}{"user_id": userID, "to": 20240201}, "to"},
+		{map[string]interface{}{"user_id": userID, "from": "2024-02-01", "to": "2024-01-01"}, "to"},
+		{map[string]interface{}{"from": "2024-01-01"}, "user_id"},
+	} {
+		if response, _ := getPurchases(tc.data); response.Success || response.Error.Fields[0] != tc.field {
+			t.Errorf("Expected %v to be rejected on %s, got %+v", tc.data, tc.field, response)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"example/data-access/internal/models"
+	"example/data-access/internal/repository"
+)
+
+// seedSales stores purchases across three weeks of January and one in
+// February, including an order, a partial and a full cancellation:
+//
+//	2024-01-01  Blue Train x3 at 10.00
+//	2024-01-03  order of Blue Train x1 and Jeru x2 at 15.00
+//	2024-01-10  Giant Steps x2 at 20.00, one cancelled
+//	2024-01-10  Jeru x1, cancelled
+//	2024-02-05  Giant Steps x1
+func seedSales(t *testing.T, db *sql.DB, store repository.Store) (blueTrain, giantSteps, jeru int64) {
+	t.Helper()
+	ctx := context.Background()
+
+	userID, _ := store.AddUser(ctx, models.User{Username: "buyer", Email: "buyer@example.com"})
+	blueTrain, _ = store.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 100})
+	giantSteps, _ = store.AddAlbum(ctx, models.Album{Title: "Giant Steps", Artist: "John Coltrane", Price: 2000, Stock: 100})
+	jeru, _ = store.AddAlbum(ctx, models.Album{Title: "Jeru", Artist: "Gerry Mulligan", Price: 1500, Stock: 100})
+
+	purchase := func(albumID int64, quantity int, createdAt string) int64 {
+		t.Helper()
+		id, _, err := store.AddPurchase(ctx, models.Purchase{UserID: userID, AlbumID: albumID, Quantity: quantity})
+		if err != nil {
+			t.Fatalf("Purchase failed: %v", err)
+		}
+		db.Exec("UPDATE purchase SET created_at = ? WHERE id = ?", createdAt, id)
+		return id
+	}
+
+	purchase(blueTrain, 3, "2024-01-01 10:00:00")
+
+	order, _, err := store.PlaceOrder(ctx, models.Order{UserID: userID, Lines: []models.OrderLine{
+		{AlbumID: blueTrain, Quantity: 1},
+		{AlbumID: jeru, Quantity: 2},
+	}})
+	if err != nil {
+		t.Fatalf("PlaceOrder failed: %v", err)
+	}
+	db.Exec("UPDATE purchase SET created_at = ? WHERE order_id = ?", "2024-01-03 15:00:00", order.ID)
+
+	partial := purchase(giantSteps, 2, "2024-01-10 09:00:00")
+	if _, _, err := store.CancelPurchase(ctx, partial, 1); err != nil {
+		t.Fatalf("CancelPurchase failed: %v", err)
+	}
+	cancelled := purchase(jeru, 1, "2024-01-10 09:30:00")
+	if _, _, err := store.CancelPurchase(ctx, cancelled, 0); err != nil {
+		t.Fatalf("CancelPurchase failed: %v", err)
+	}
+
+	purchase(giantSteps, 1, "2024-02-05 18:00:00")
+	return blueTrain, giantSteps, jeru
+}
+
+// TestSalesReports tests each report against hand-computed totals
+func TestSalesReports(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+	blueTrain, giantSteps, jeru := seedSales(t, db, store)
+
+	albums, err := store.TopSellingAlbums(ctx, models.PurchaseFilter{}, 10)
+	if err != nil {
+		t.Fatalf("TopSellingAlbums failed: %v", err)
+	}
+	wantAlbums := []models.AlbumSales{
+		{AlbumID: blueTrain, Title: "Blue Train", Artist: "John Coltrane", Units: 4, Revenue: 4000},
+		{AlbumID: giantSteps, Title: "Giant Steps", Artist: "John Coltrane", Units: 2, Revenue: 4000},
+		{AlbumID: jeru, Title: "Jeru", Artist: "Gerry Mulligan", Units: 2, Revenue: 3000},
+	}
+	if len(albums) != len(wantAlbums) {
+		t.Fatalf("Expected %d albums, got %+v", len(wantAlbums), albums)
+	}
+	for i, want := range wantAlbums {
+		if albums[i] != want {
+			t.Errorf("Album %d: expected %+v, got %+v", i, want, albums[i])
+		}
+	}
+	if top, _ := store.TopSellingAlbums(ctx, models.PurchaseFilter{}, 1); len(top) != 1 || top[0].AlbumID != blueTrain {
+		t.Errorf("Expected limit 1 to return only Blue Train, got %+v", top)
+	}
+
+	artists, _ := store.RevenueByArtist(ctx, models.PurchaseFilter{}, 10)
+	if len(artists) != 2 || artists[0] != (models.ArtistRevenue{Artist: "John Coltrane", Units: 6, Revenue: 8000}) ||
+		artists[1] != (models.ArtistRevenue{Artist: "Gerry Mulligan", Units: 2, Revenue: 3000}) {
+		t.Errorf("Unexpected revenue by artist: %+v", artists)
+	}
+
+	for period, want := range map[string][]models.PeriodSales{
+		"day":   {{Period: "2024-01-01", Units: 3, Revenue: 3000}, {Period: "2024-01-03", Units: 3, Revenue: 4000}, {Period: "2024-01-10", Units: 1, Revenue: 2000}, {Period: "2024-02-05", Units: 1, Revenue: 2000}},
+		"week":  {{Period: "2024-01-01", Units: 6, Revenue: 7000}, {Period: "2024-01-08", Units: 1, Revenue: 2000}, {Period: "2024-02-05", Units: 1, Revenue: 2000}},
+		"month": {{Period: "2024-01-01", Units: 7, Revenue: 9000}, {Period: "2024-02-01", Units: 1, Revenue: 2000}},
+	} {
+		sales, err := store.SalesByPeriod(ctx, period, models.PurchaseFilter{})
+		if err != nil {
+			t.Fatalf("SalesByPeriod %s failed: %v", period, err)
+		}
+		if len(sales) != len(want) {
+			t.Errorf("By %s: expected %+v, got %+v", period, want, sales)
+			continue
+		}
+		for i := range want {
+			if sales[i] != want[i] {
+				t.Errorf("By %s: expected %+v, got %+v", period, want[i], sales[i])
+			}
+		}
+	}
+
+	report, _ := store.OrderValue(ctx, models.PurchaseFilter{})
+	if report != (models.OrderValueReport{Orders: 4, Units: 8, Revenue: 11000, AverageOrderValue: 2750}) {
+		t.Errorf("Unexpected order value: %+v", report)
+	}
+
+	january := models.PurchaseFilter{From: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)}
+	report, _ = store.OrderValue(ctx, january)
+	if report != (models.OrderValueReport{Orders: 3, Units: 7, Revenue: 9000, AverageOrderValue: 3000}) {
+		t.Errorf("Unexpected January order value: %+v", report)
+	}
+
+	empty := models.PurchaseFilter{From: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if report, err := store.OrderValue(ctx, empty); err != nil || report != (models.OrderValueReport{}) {
+		t.Errorf("Expected an empty report with no sales, got %+v (err: %v)", report, err)
+	}
+}
+
+// TestSalesReportActions tests the report actions and their validation
+func TestSalesReportActions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	seedSales(t, db, store)
+	conn := dialTestServer(t, store)
+
+	send := func(action string, data interface{}, result interface{}) models.WSResponse {
+		t.Helper()
+		if err := conn.WriteJSON(models.WSMessage{Action: action, Data: data}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		response := struct {
+			models.WSResponse
+			Data interface{} `json:"data"`
+		}{Data: result}
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return response.WSResponse
+	}
+
+	var weeks []models.PeriodSales
+	response := send("getSalesByPeriod", map[string]interface{}{"period": "week", "from": "2024-01-08"}, &weeks)
+	if !response.Success || len(weeks) != 2 || weeks[0].Period != "2024-01-08" || weeks[0].Revenue != 2000 {
+		t.Errorf("Expected two weeks from 2024-01-08, got %+v (%+v)", weeks, response)
+	}
+
+	var albums []models.AlbumSales
+	if response := send("getTopSellingAlbums", map[string]interface{}{"limit": 2}, &albums); !response.Success || len(albums) != 2 {
+		t.Errorf("Expected two albums, got %+v (%+v)", albums, response)
+	}
+
+	var report models.OrderValueReport
+	if response := send("getAverageOrderValue", nil, &report); !response.Success || report.AverageOrderValue != 2750 {
+		t.Errorf("Expected average order value 27.50, got %+v (%+v)", report, response)
+	}
+
+	for _, tc := range []struct {
+		action string
+		data   interface{}
+		field  string
+	}{
+		{"getSalesByPeriod", map[string]interface{}{"period": "year"}, "period"},
+		{"getSalesByPeriod", nil, "data"},
+		{"getTopSellingAlbums", map[string]interface{}{"limit": 101}, "limit"},
+		{"getRevenueByArtist", map[string]interface{}{"from": "2024-02-01", "to": "2024-01-01"}, "to"},
+		{"getAverageOrderValue", "2024", "data"},
+	} {
+		if response := send(tc.action, tc.data, nil); response.Success || response.Error.Fields[0] != tc.field {
+			t.Errorf("Expected %s with %v to be rejected on %s, got %+v", tc.action, tc.data, tc.field, response)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"example/data-access/internal/models"
+	"example/data-access/internal/server"
+
+	"github.com/gorilla/websocket"
+)
+
+// slowStore delays ListAlbums so a request is in flight during shutdown
+type slowStore struct {
+	stubStore
+	delay time.Duration
+}
+
+func (s *slowStore) ListAlbums(ctx context.Context, page models.PageRequest) ([]models.Album, bool, error) {
+	time.Sleep(s.delay)
+	return s.stubStore.ListAlbums(ctx, page)
+}
+
+// TestShutdownDrainsInFlightRequests tests that shutdown lets a running request
+// finish, answers messages sent meanwhile with SERVER_SHUTTING_DOWN ahead of
+// the 1001 going away close frame, and then refuses new connections
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	store := &slowStore{stubStore: stubStore{albums: []models.Album{{ID: 1, Title: "Kind of Blue"}}}, delay: 200 * time.Millisecond}
+	ws := server.NewServer()
+	conn := dialServer(t, ws, store)
+
+	if err := conn.WriteJSON(models.WSMessage{ID: json.RawMessage(`"slow"`), Action: "getAlbums"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Start shutting down while the request is still running
+	time.Sleep(50 * time.Millisecond)
+	shutdownErr := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		shutdownErr <- ws.Shutdown(ctx)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if err := conn.WriteJSON(models.WSMessage{ID: json.RawMessage(`"late"`), Action: "getAlbums"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	responses := make(map[string]models.WSResponse)
+	for len(responses) < 2 {
+		var response models.WSResponse
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Expected both responses before close, got error: %v", err)
+		}
+		responses[string(response.ID)] = response
+	}
+	if !responses[`"slow"`].Success {
+		t.Errorf("Expected in-flight request to succeed, got %+v", responses[`"slow"`])
+	}
+	if late := responses[`"late"`]; late.Success || late.Error == nil || late.Error.Code != "SERVER_SHUTTING_DOWN" {
+		t.Errorf("Expected SERVER_SHUTTING_DOWN for a message sent during shutdown, got %+v", late)
+	}
+
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("Expected 1001 going away close, got %v", err)
+	}
+
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown returned error: %v", err)
+	}
+
+	// The server stays shut down; new connections get a fresh one
+	srv := httptest.NewServer(http.HandlerFunc(ws.HandleWebSocket))
+	defer srv.Close()
+	if _, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil); err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for an upgrade after shutdown, got %v", err)
+	}
+}
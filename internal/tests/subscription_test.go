@@ -0,0 +1,244 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"example/data-access/internal/models"
+	"example/data-access/internal/repository"
+
+	"github.com/gorilla/websocket"
+)
+
+// subscriptionMessage is either a response or a pushed event
+type subscriptionMessage struct {
+	models.WSResponse
+	models.AlbumEvent
+}
+
+// readSubscriptionMessage reads the next frame on conn
+func readSubscriptionMessage(t *testing.T, conn *websocket.Conn) subscriptionMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg subscriptionMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	return msg
+}
+
+// request sends msg on conn and returns its response
+func request(t *testing.T, conn *websocket.Conn, msg models.WSMessage) models.WSResponse {
+	t.Helper()
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var response models.WSResponse
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	return response
+}
+
+// TestSubscriptionPushesEvents tests that a subscriber sees another
+// connection's committed changes to the albums it follows, and nothing else
+func TestSubscriptionPushesEvents(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	userID, _ := store.AddUser(ctx, models.User{Username: "buyer", Email: "buyer@example.com"})
+	followed, _ := store.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 5})
+	other, _ := store.AddAlbum(ctx, models.Album{Title: "Jeru", Artist: "Gerry Mulligan", Price: 1500, Stock: 5})
+
+	subscriber := dialTestServer(t, store)
+	actor := dialTestServer(t, store)
+
+	response := request(t, subscriber, models.WSMessage{Action: "subscribe", Data: map[string]interface{}{"album_ids": []int64{followed}}})
+	if !response.Success {
+		t.Fatalf("Subscribe failed: %+v", response)
+	}
+
+	// A purchase of an album not followed, then one of the followed album:
+	// only the second reaches the subscriber
+	purchase := func(albumID int64) {
+		t.Helper()
+		data := map[string]interface{}{"user_id": userID, "album_id": albumID, "quantity": 2}
+		if response := request(t, actor, models.WSMessage{Action: "addPurchase", Data: data}); !response.Success {
+			t.Fatalf("Purchase failed: %+v", response)
+		}
+	}
+	purchase(other)
+	purchase(followed)
+
+	event := readSubscriptionMessage(t, subscriber)
+	if event.Event != "stockChanged" || event.AlbumID != followed || event.Stock == nil || *event.Stock != 3 {
+		t.Fatalf("Expected stockChanged to 3 for album %d, got %+v", followed, event.AlbumEvent)
+	}
+
+	// A rolled-back atomic batch publishes nothing; the restock after it does
+	batch := models.WSBatch{Atomic: true, Messages: []models.WSMessage{
+		{Action: "addPurchase", Data: map[string]interface{}{"user_id": userID, "album_id": followed, "quantity": 1}},
+		{Action: "addPurchase", Data: map[string]interface{}{"user_id": userID, "album_id": followed, "quantity": 99}},
+	}}
+	if err := actor.WriteJSON(batch); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var batchResponse models.WSResponse
+	if err := actor.ReadJSON(&batchResponse); err != nil || batchResponse.Success {
+		t.Fatalf("Expected the batch to roll back, got %+v (err: %v)", batchResponse, err)
+	}
+	restock := map[string]interface{}{"id": followed, "quantity": 10, "reason": "delivery"}
+	if response := request(t, actor, models.WSMessage{Action: "restockAlbum", Data: restock}); !response.Success {
+		t.Fatalf("Restock failed: %+v", response)
+	}
+
+	event = readSubscriptionMessage(t, subscriber)
+	if event.Event != "stockChanged" || *event.Stock != 13 {
+		t.Fatalf("Expected only the restock to stock 13, got %+v", event.AlbumEvent)
+	}
+
+	// Catalog subscribers also hear about new albums
+	if response := request(t, subscriber, models.WSMessage{Action: "subscribe", Data: map[string]interface{}{"catalog": true}}); !response.Success {
+		t.Fatalf("Catalog subscribe failed: %+v", response)
+	}
+	album := map[string]interface{}{"title": "Giant Steps", "artist": "John Coltrane", "price": 20, "stock": 4}
+	if response := request(t, actor, models.WSMessage{Action: "addAlbum", Data: album}); !response.Success {
+		t.Fatalf("AddAlbum failed: %+v", response)
+	}
+	event = readSubscriptionMessage(t, subscriber)
+	if event.Event != "albumAdded" || event.Album == nil || event.Album.Title != "Giant Steps" || event.Album.ID != event.AlbumID {
+		t.Fatalf("Expected albumAdded for Giant Steps, got %+v", event.AlbumEvent)
+	}
+
+	// Deleting an album with purchases soft-deletes it, and says so
+	deletion := map[string]interface{}{"id": followed, "on_purchases": "soft_delete"}
+	if response := request(t, actor, models.WSMessage{Action: "deleteAlbum", Data: deletion}); !response.Success {
+		t.Fatalf("DeleteAlbum failed: %+v", response)
+	}
+	event = readSubscriptionMessage(t, subscriber)
+	if event.Event != "albumDeleted" || event.AlbumID != followed {
+		t.Fatalf("Expected albumDeleted for album %d, got %+v", followed, event.AlbumEvent)
+	}
+
+	// After unsubscribing from everything, the subscriber's next frame is
+	// the response to its own request
+	if response := request(t, subscriber, models.WSMessage{Action: "unsubscribe"}); !response.Success {
+		t.Fatalf("Unsubscribe failed: %+v", response)
+	}
+	purchase(other)
+	if response := request(t, subscriber, models.WSMessage{Action: "getAlbumByID", Data: other}); !response.Success || response.Data == nil {
+		t.Errorf("Expected the getAlbumByID response and no event, got %+v", response)
+	}
+}
+
+// recordingPublisher keeps every published event
+type recordingPublisher struct {
+	events []models.AlbumEvent
+}
+
+func (p *recordingPublisher) Publish(event models.AlbumEvent) {
+	p.events = append(p.events, event)
+}
+
+// unreadableAlbumsStore fails every album read, so events can only carry the
+// stock the change itself returned
+type unreadableAlbumsStore struct {
+	*repository.SQLiteStore
+}
+
+func (s unreadableAlbumsStore) GetAlbumByID(ctx context.Context, id int64) (models.Album, error) {
+	return models.Album{}, errors.New("album reads disabled")
+}
+
+// TestNotifyingStoreStockFromChange tests that stock events carry the stock
+// returned by the purchase, cancellation or order, without reading it again
+func TestNotifyingStoreStockFromChange(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	base := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	userID, _ := base.AddUser(ctx, models.User{Username: "buyer", Email: "buyer@example.com"})
+	album1, _ := base.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 5})
+	album2, _ := base.AddAlbum(ctx, models.Album{Title: "Jeru", Artist: "Gerry Mulligan", Price: 1500, Stock: 5})
+
+	pub := &recordingPublisher{}
+	store := repository.NewNotifyingStore(unreadableAlbumsStore{base}, pub)
+
+	purchaseID, _, err := store.AddPurchase(ctx, models.Purchase{UserID: userID, AlbumID: album1, Quantity: 2})
+	if err != nil {
+		t.Fatalf("Purchase failed: %v", err)
+	}
+	if _, _, err := store.CancelPurchase(ctx, purchaseID, 1); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	_, _, err = store.PlaceOrder(ctx, models.Order{UserID: userID, Lines: []models.OrderLine{
+		{AlbumID: album2, Quantity: 1},
+		{AlbumID: album1, Quantity: 1},
+		{AlbumID: album2, Quantity: 2},
+	}})
+	if err != nil {
+		t.Fatalf("PlaceOrder failed: %v", err)
+	}
+
+	want := []struct {
+		albumID int64
+		stock   int
+	}{{album1, 3}, {album1, 4}, {album2, 2}, {album1, 3}}
+	if len(pub.events) != len(want) {
+		t.Fatalf("Expected %d events, got %+v", len(want), pub.events)
+	}
+	for i, w := range want {
+		event := pub.events[i]
+		if event.Event != "stockChanged" || event.AlbumID != w.albumID || event.Stock == nil || *event.Stock != w.stock {
+			t.Errorf("Event %d: expected stockChanged to %d for album %d, got %+v", i, w.stock, w.albumID, event)
+		}
+	}
+}
+
+// TestSubscribeValidation tests the subscribe data checks and the returned state
+func TestSubscribeValidation(t *testing.T) {
+	conn := dialTestServer(t, &stubStore{})
+
+	for _, tc := range []struct {
+		data  interface{}
+		field string
+	}{
+		{nil, "data"},
+		{map[string]interface{}{}, "data"},
+		{map[string]interface{}{"album_ids": []interface{}{1, -2}}, "album_ids"},
+		{map[string]interface{}{"album_ids": "1"}, "album_ids"},
+		{map[string]interface{}{"catalog": "yes"}, "catalog"},
+	} {
+		if response := request(t, conn, models.WSMessage{Action: "subscribe", Data: tc.data}); response.Success || response.Error.Fields[0] != tc.field {
+			t.Errorf("Expected %v to be rejected on %s, got %+v", tc.data, tc.field, response)
+		}
+	}
+
+	if err := conn.WriteJSON(models.WSMessage{Action: "subscribe", Data: map[string]interface{}{"album_ids": []int{3, 1, 3}}}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var response struct {
+		models.WSResponse
+		Data models.Subscription `json:"data"`
+	}
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if !response.Success || len(response.Data.AlbumIDs) != 2 || response.Data.AlbumIDs[0] != 1 || response.Data.Catalog {
+		t.Errorf("Expected albums [1 3] without the catalog, got %+v", response)
+	}
+
+	ids := make([]int, 1001)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+	if response := request(t, conn, models.WSMessage{Action: "subscribe", Data: map[string]interface{}{"album_ids": ids}}); response.Success || response.Error.Code != "VALIDATION_FAILED" {
+		t.Errorf("Expected too many albums to be rejected, got %+v", response)
+	}
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"example/data-access/internal/models"
+	"example/data-access/internal/repository"
+)
+
+// TestUpdateUser tests partial updates and the unique username and email constraints
+func TestUpdateUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	userID, _ := store.AddUser(ctx, models.User{Username: "buyer", Email: "buyer@example.com"})
+	store.AddUser(ctx, models.User{Username: "browser", Email: "browser@example.com"})
+
+	email := "new@example.com"
+	user, err := store.UpdateUser(ctx, userID, models.UserUpdate{Email: &email})
+	if err != nil {
+		t.Fatalf("UpdateUser failed: %v", err)
+	}
+	if user.Username != "buyer" || user.Email != "new@example.com" {
+		t.Errorf("Expected only the email to change, got %+v", user)
+	}
+
+	taken := "browser"
+	if _, err := store.UpdateUser(ctx, userID, models.UserUpdate{Username: &taken}); !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate for a taken username, got %v", err)
+	}
+	if _, err := store.UpdateUser(ctx, 999, models.UserUpdate{Email: &email}); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound updating a missing user, got %v", err)
+	}
+}
+
+// TestDeleteUserPolicies tests the block, anonymize and cascade purchase policies
+func TestDeleteUserPolicies(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	store := repository.NewSQLiteStore(db)
+	ctx := context.Background()
+
+	albumID, _ := store.AddAlbum(ctx, models.Album{Title: "Blue Train", Artist: "John Coltrane", Price: 1000, Stock: 10})
+	addBuyer := func(name string) int64 {
+		t.Helper()
+		id, _ := store.AddUser(ctx, models.User{Username: name, Email: name + "@example.com"})
+		if _, _, err := store.AddPurchase(ctx, models.Purchase{UserID: id, AlbumID: albumID, Quantity: 1}); err != nil {
+			t.Fatalf("Purchase failed: %v", err)
+		}
+		return id
+	}
+
+	blocked := addBuyer("blocked")
+	if _, err := store.DeleteUser(ctx, blocked, repository.BlockIfPurchased); !errors.Is(err, repository.ErrInUse) {
+		t.Errorf("Expected ErrInUse deleting a user with purchases, got %v", err)
+	}
+
+	anonymized := addBuyer("anonymized")
+	purchases, err := store.DeleteUser(ctx, anonymized, repository.AnonymizePurchaser)
+	if err != nil || purchases != 1 {
+		t.Fatalf("Expected anonymize to keep 1 purchase, got %d (err: %v)", purchases, err)
+	}
+	if _, err := store.GetUserByID(ctx, anonymized); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected anonymized user to be hidden, got %v", err)
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM purchase WHERE user_id = ?", anonymized).Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected the anonymized user's purchase to be kept, %d remain", count)
+	}
+	if _, err := store.GetUserPurchaseSummary(ctx, anonymized); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for the anonymized user's summary, got %v", err)
+	}
+	conn := dialTestServer(t, store)
+	if err := conn.WriteJSON(models.WSMessage{Action: "getUserPurchaseSummary", Data: anonymized}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var response models.WSResponse
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if response.Success || response.Error == nil || response.Error.Code != "NOT_FOUND" {
+		t.Errorf("Expected NOT_FOUND for the anonymized user's summary, got %+v", response)
+	}
+	if _, _, err := store.AddPurchase(ctx, models.Purchase{UserID: anonymized, AlbumID: albumID, Quantity: 1}); !errors.Is(err, repository.ErrInvalidReference) {
+		t.Errorf("Expected ErrInvalidReference purchasing as an anonymized user, got %v", err)
+	}
+
+	cascaded := addBuyer("cascaded")
+	if _, err := store.DeleteUser(ctx, cascaded, repository.CascadePurchases); err != nil {
+		t.Fatalf("Cascade delete failed: %v", err)
+	}
+	db.QueryRow("SELECT COUNT(*) FROM purchase WHERE user_id = ?", cascaded).Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected cascade to delete the user's purchases, %d remain", count)
+	}
+
+	users, _ := store.GetAllUsers(ctx)
+	if len(users) != 1 || users[0].ID != blocked {
+		t.Errorf("Expected only the blocked user to remain visible, got %+v", users)
+	}
+	summaries, err := store.GetAllUsersPurchaseSummary(ctx)
+	if err != nil {
+		t.Fatalf("GetAllUsersPurchaseSummary failed: %v", err)
+	}
+	if len(summaries) != 1 || summaries[0].UserID != blocked {
+		t.Errorf("Expected only the blocked user in the summaries, got %+v", summaries)
+	}
+	page, _, err := store.ListUsersPurchaseSummary(ctx, models.PageRequest{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListUsersPurchaseSummary failed: %v", err)
+	}
+	if len(page) != 1 || page[0].UserID != blocked {
+		t.Errorf("Expected only the blocked user in the summary page, got %+v", page)
+	}
+}
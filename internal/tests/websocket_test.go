@@ -0,0 +1,313 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"example/data-access/internal/models"
+	"example/data-access/internal/repository"
+	"example/data-access/internal/server"
+
+	"github.com/gorilla/websocket"
+)
+
+// stubStore implements repository.Store for handler tests; methods that are
+// not overridden panic through the nil embedded interface
+type stubStore struct {
+	repository.Store
+	albums []models.Album
+	err    error
+}
+
+func (s *stubStore) ListAlbums(ctx context.Context, page models.PageRequest) ([]models.Album, bool, error) {
+	return s.albums, false, s.err
+}
+
+// dialTestServer starts the WebSocket handler backed by store and connects a client
+func dialTestServer(t *testing.T, store repository.Store) *websocket.Conn {
+	return dialServer(t, server.NewServer(), store)
+}
+
+// dialServer serves ws over HTTP with store and connects a WebSocket client to it
+func dialServer(t *testing.T, ws *server.Server, store repository.Store) *websocket.Conn {
+	server.SetStore(store)
+
+	srv := httptest.NewServer(http.HandlerFunc(ws.HandleWebSocket))
+	t.Cleanup(srv.Close)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial test server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// TestHandleGetAlbumsWithStubStore tests that handlers read through the configured store
+func TestHandleGetAlbumsWithStubStore(t *testing.T) {
+	store := &stubStore{albums: []models.Album{{ID: 1, Title: "Blue Train", Artist: "John Coltrane", Price: 5699, Stock: 3}}}
+	conn := dialTestServer(t, store)
+
+	if err := conn.WriteJSON(models.WSMessage{Action: "getAlbums"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var response struct {
+		Success bool
+		Data    models.Page[models.Album]
+	}
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if !response.Success {
+		t.Fatalf("Expected success response")
+	}
+	if len(response.Data.Items) != 1 || response.Data.Items[0].Title != "Blue Train" || response.Data.HasMore {
+		t.Errorf("Expected stub album in response, got %+v", response.Data)
+	}
+}
+
+// TestHandleGetAlbumsStoreError tests that store errors are reported as failed
+// responses without exposing the driver error
+func TestHandleGetAlbumsStoreError(t *testing.T) {
+	conn := dialTestServer(t, &stubStore{err: errors.New("getAllAlbums: dial tcp 10.0.0.5:3306: connection refused")})
+
+	if err := conn.WriteJSON(models.WSMessage{Action: "getAlbums"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var response models.WSResponse
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if response.Success {
+		t.Error("Expected failed response when store returns an error")
+	}
+	if response.Error == nil || response.Error.Code != "INTERNAL" || response.Error.Message != "internal server error" {
+		t.Errorf("Expected sanitized internal error, got %+v", response.Error)
+	}
+}
+
+// TestHandleUnknownAction tests that unknown actions do not reach the store
+func TestHandleUnknownAction(t *testing.T) {
+	conn := dialTestServer(t, &stubStore{})
+
+	if err := conn.WriteJSON(models.WSMessage{Action: "dropTables"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var response models.WSResponse
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if response.Success || response.Error == nil || response.Error.Code != "UNKNOWN_ACTION" || response.Error.Message != "unknown action" {
+		t.Errorf("Expected unknown action error, got %+v", response)
+	}
+}
+
+// TestHandleValidationErrorFields tests that validation failures name the offending field
+func TestHandleValidationErrorFields(t *testing.T) {
+	conn := dialTestServer(t, &stubStore{})
+
+	msg := models.WSMessage{Action: "addAlbum", Data: map[string]interface{}{"title": "Giant Steps", "artist": "John Coltrane", "price": -1, "stock": 2}}
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var response models.WSResponse
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if response.Success || response.Error == nil {
+		t.Fatalf("Expected validation error, got %+v", response)
+	}
+	if response.Error.Code != "VALIDATION_FAILED" {
+		t.Errorf("Expected VALIDATION_FAILED, got %q", response.Error.Code)
+	}
+	if len(response.Error.Fields) != 1 || response.Error.Fields[0] != "price" {
+		t.Errorf("Expected price field, got %v", response.Error.Fields)
+	}
+	if response.Error.Details["value"] != float64(-1) {
+		t.Errorf("Expected rejected value in details, got %v", response.Error.Details)
+	}
+}
+
+// TestHandlePriceRange tests that prices past the DECIMAL(10, 2) column are
+// rejected as a validation error and the largest one is stored intact
+func TestHandlePriceRange(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	conn := dialTestServer(t, repository.NewSQLiteStore(db))
+
+	response := request(t, conn, models.WSMessage{Action: "addAlbum", Data: map[string]interface{}{"title": "Giant Steps", "artist": "John Coltrane", "price": 100000000, "stock": 2}})
+	if response.Success || response.Error == nil || response.Error.Code != "VALIDATION_FAILED" || response.Error.Fields[0] != "price" {
+		t.Fatalf("Expected price out of range to be rejected, got %+v", response)
+	}
+
+	response = request(t, conn, models.WSMessage{Action: "addAlbum", Data: map[string]interface{}{"title": "Giant Steps", "artist": "John Coltrane", "price": 99999999.99, "stock": 2}})
+	if !response.Success {
+		t.Fatalf("Expected the largest price to be accepted, got %+v", response)
+	}
+	var cents int64
+	db.QueryRow("SELECT price FROM album").Scan(&cents)
+	if cents != 9999999999 {
+		t.Errorf("Expected 9999999999 cents stored, got %d", cents)
+	}
+}
+
+// TestHandleFractionalCountsRejected tests that stock and quantities with a
+// fraction are rejected instead of being truncated
+func TestHandleFractionalCountsRejected(t *testing.T) {
+	conn := dialTestServer(t, &stubStore{})
+
+	for _, tc := range []struct {
+		msg   models.WSMessage
+		field string
+	}{
+		{models.WSMessage{Action: "addAlbum", Data: map[string]interface{}{"title": "Giant Steps", "artist": "John Coltrane", "price": 9.99, "stock": 2.5}}, "stock"},
+		{models.WSMessage{Action: "updateAlbum", Data: map[string]interface{}{"id": 1, "stock": 0.5}}, "stock"},
+		{models.WSMessage{Action: "restockAlbum", Data: map[string]interface{}{"id": 1, "quantity": 1.5, "reason": "delivery"}}, "quantity"},
+		{models.WSMessage{Action: "addPurchase", Data: map[string]interface{}{"user_id": 1, "album_id": 1, "quantity": 1.5}}, "quantity"},
+		{models.WSMessage{Action: "cancelPurchase", Data: map[string]interface{}{"id": 1, "quantity": 1.5}}, "quantity"},
+		{models.WSMessage{Action: "placeOrder", Data: map[string]interface{}{"user_id": 1, "lines": []interface{}{map[string]interface{}{"album_id": 1, "quantity": 0.5}}}}, "lines[0].quantity"},
+	} {
+		response := request(t, conn, tc.msg)
+		if response.Success || response.Error == nil || response.Error.Code != "VALIDATION_FAILED" || response.Error.Fields[0] != tc.field {
+			t.Errorf("Expected %s to be rejected on %s, got %+v", tc.msg.Action, tc.field, response)
+		}
+	}
+}
+
+// blockingStore blocks ListAlbums until its context is cancelled
+type blockingStore struct {
+	stubStore
+	started   chan struct{}
+	cancelled chan error
+}
+
+func (s *blockingStore) ListAlbums(ctx context.Context, page models.PageRequest) ([]models.Album, bool, error) {
+	close(s.started)
+	<-ctx.Done()
+	s.cancelled <- ctx.Err()
+	return nil, false, ctx.Err()
+}
+
+// TestClientDisconnectCancelsStoreContext tests that closing the connection
+// cancels the context of a store call that is still running
+func TestClientDisconnectCancelsStoreContext(t *testing.T) {
+	store := &blockingStore{started: make(chan struct{}), cancelled: make(chan error, 1)}
+	conn := dialTestServer(t, store)
+
+	if err := conn.WriteJSON(models.WSMessage{Action: "getAlbums"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	<-store.started
+	conn.Close()
+
+	select {
+	case err := <-store.cancelled:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Store context was not cancelled after disconnect")
+	}
+}
+
+// TestRequestIDEchoed tests that the client's request ID comes back on
+// single, batch and error responses
+func TestRequestIDEchoed(t *testing.T) {
+	conn := dialTestServer(t, &stubStore{albums: []models.Album{{ID: 1, Title: "Blue Train"}}})
+
+	if err := conn.WriteJSON(models.WSMessage{ID: json.RawMessage(`"req-1"`), Action: "getAlbums"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var response models.WSResponse
+	if err := conn.ReadJSON(&response); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(response.ID) != `"req-1"` {
+		t.Errorf("Expected id \"req-1\", got %s", response.ID)
+	}
+
+	batch := []models.WSMessage{
+		{ID: json.RawMessage(`7`), Action: "getAlbums"},
+		{ID: json.RawMessage(`"bad"`), Action: "dropTables"},
+	}
+	if err := conn.WriteJSON(batch); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var responses []models.WSResponse
+	if err := conn.ReadJSON(&responses); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(responses) != 2 || string(responses[0].ID) != "7" || string(responses[1].ID) != `"bad"` {
+		t.Fatalf("Expected batch ids 7 and \"bad\", got %+v", responses)
+	}
+	if responses[1].Error == nil || responses[1].Error.Code != "UNKNOWN_ACTION" {
+		t.Errorf("Expected unknown action error with id, got %+v", responses[1])
+	}
+}
+
+// slowAlbumsStore answers ListAlbums only after ListUsers has been called
+type slowAlbumsStore struct {
+	stubStore
+	usersServed chan struct{}
+}
+
+func (s *slowAlbumsStore) ListAlbums(ctx context.Context, page models.PageRequest) ([]models.Album, bool, error) {
+	select {
+	case <-s.usersServed:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+	return s.stubStore.ListAlbums(ctx, page)
+}
+
+func (s *slowAlbumsStore) ListUsers(ctx context.Context, page models.PageRequest) ([]models.User, bool, error) {
+	close(s.usersServed)
+	return []models.User{{ID: 1, Username: "buyer"}}, false, nil
+}
+
+// TestSlowMessageDoesNotBlockConnection tests that messages on one connection
+// run concurrently and responses arrive in completion order
+func TestSlowMessageDoesNotBlockConnection(t *testing.T) {
+	conn := dialTestServer(t, &slowAlbumsStore{usersServed: make(chan struct{})})
+
+	if err := conn.WriteJSON(models.WSMessage{ID: json.RawMessage(`1`), Action: "getAlbums"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := conn.WriteJSON(models.WSMessage{ID: json.RawMessage(`2`), Action: "getUsers"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var ids []string
+	for range 2 {
+		var response models.WSResponse
+		if err := conn.ReadJSON(&response); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if !response.Success {
+			t.Errorf("Expected success, got %+v", response)
+		}
+		ids = append(ids, string(response.ID))
+	}
+
+	if ids[0] != "2" || ids[1] != "1" {
+		t.Errorf("Expected getUsers response before getAlbums, got ids %v", ids)
+	}
+}
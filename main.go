@@ -1,44 +1,106 @@
-package main
-
-import (
-	"fmt"
-	"net/http"
-
-	"example/data-access/internal/logger"
-	"example/data-access/internal/server"
-
-	"github.com/joho/godotenv"
-)
-
-func main() {
-	// Initialize logger
-	logger.InitLoggerDev()
-	defer logger.Sync()
-
-	logger.Log.Info("Starting WebSocket API Server")
-
-	// Load .env file
-	if err := godotenv.Load(); err != nil {
-		logger.Log.Warnw("No .env file found, using existing environment variables", "error", err)
-	}
-
-	// Initialize database
-	if err := server.InitDatabase(); err != nil {
-		logger.Log.Fatalw("Failed to initialize database", "error", err)
-	}
-	defer server.CloseDatabase()
-
-	// Set up HTTP routes
-	http.HandleFunc("/ws", server.HandleWebSocket)
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/plain")
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "WebSocket API Server\nConnect to ws://localhost:8080/ws\n")
-	})
-
-	// Start server
-	logger.Log.Infow("WebSocket server starting", "port", "8080", "endpoint", "ws://localhost:8080/ws")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		logger.Log.Fatalw("Server error", "error", err)
-	}
-}
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"example/data-access/internal/config"
+	"example/data-access/internal/logger"
+	"example/data-access/internal/server"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Initialize logger
+	logger.InitLoggerDev()
+	defer logger.Sync()
+
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		logger.Log.Warnw("No .env file found, using existing environment variables", "error", err)
+	}
+
+	// Load configuration from config file, environment and flags
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		logger.Log.Fatalw("Invalid configuration", "error", err)
+	}
+
+	// Run migrations instead of the server when invoked as "migrate ..."
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(cfg, args[1:]); err != nil {
+			logger.Log.Fatalw("Migration failed", "error", err)
+		}
+		return
+	}
+
+	logger.Log.Info("Starting WebSocket API Server")
+
+	// Initialize database
+	if err := server.InitDatabase(cfg.Database); err != nil {
+		logger.Log.Fatalw("Failed to initialize database", "error", err)
+	}
+
+	server.Configure(cfg.Server)
+	endpoint := server.Endpoint(cfg.Server)
+
+	// Set up HTTP routes
+	ws := server.NewServer()
+	http.HandleFunc("/ws", ws.HandleWebSocket)
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "WebSocket API Server\nConnect to %s\n", endpoint)
+	})
+
+	// Open listener (TCP or Unix socket, optionally TLS)
+	ln, err := server.Listen(cfg.Server)
+	if err != nil {
+		logger.Log.Fatalw("Failed to open listener", "error", err)
+	}
+
+	// Start server
+	srv := &http.Server{ReadHeaderTimeout: 10 * time.Second}
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Log.Infow("WebSocket server starting", "addr", ln.Addr().String(), "tls", cfg.Server.TLSEnabled(), "endpoint", endpoint)
+		serveErr <- srv.Serve(ln)
+	}()
+
+	// Wait for SIGINT/SIGTERM or a server failure
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case err := <-serveErr:
+		logger.Log.Fatalw("Server error", "error", err)
+	case <-ctx.Done():
+		stop()
+		logger.Log.Infow("Shutdown signal received", "grace_period", cfg.Server.ShutdownGrace.String())
+	}
+
+	// Stop accepting connections, then drain WebSocket clients within the grace period
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownGrace.Duration)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Log.Warnw("HTTP server shutdown incomplete", "error", err)
+	}
+	if err := ws.Shutdown(shutdownCtx); err != nil {
+		logger.Log.Warnw("WebSocket shutdown incomplete", "error", err)
+	}
+	server.CloseDatabase()
+
+	logger.Log.Info("Server stopped")
+}
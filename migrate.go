@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"example/data-access/internal/config"
+	"example/data-access/internal/migrations"
+	"example/data-access/internal/server"
+)
+
+const migrateUsage = "usage: migrate up | down [steps] | status"
+
+// runMigrate implements the "migrate up|down|status" subcommand
+func runMigrate(cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	if err := server.OpenDatabase(cfg.Database); err != nil {
+		return err
+	}
+	defer server.CloseDatabase()
+
+	db, driver := server.GetDB(), server.GetDriver()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(db, driver)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid number of steps %q: must be a positive integer", args[1])
+			}
+			steps = n
+		}
+		rolledBack, err := migrations.Down(db, driver, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", rolledBack)
+
+	case "status":
+		statuses, err := migrations.GetStatus(db, driver)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		w.Flush()
+
+	default:
+		return fmt.Errorf(migrateUsage)
+	}
+
+	return nil
+}